@@ -0,0 +1,208 @@
+// Copyright 2014 Gyepi Sam. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Package aspnet verifies the password hashes of ASP.NET Identity, as stored in the PasswordHash column
+of the AspNetUsers table, for the mcf framework.
+
+The hashes are base64 encoded blobs whose first byte is a version:
+
+	version 2:  0x00 | salt (16 bytes) | key (32 bytes)
+	            PBKDF2 with HMAC-SHA1 and 1000 iterations.
+	version 3:  0x01 | prf | iterations | salt length | salt | key
+	            The prf (0: HMAC-SHA1, 1: HMAC-SHA256, 2: HMAC-SHA512), iterations and salt length
+	            are big endian 32 bit integers, and the key fills the rest of the blob.
+
+for example
+
+	AQAAAAEAACcQAAAAEAABAgMEBQYHCAkKCwwNDg/rbIFTVZIgPAkrFY+NOQlnI2Km9dvQDZgoBEy6qLJS6Q==
+
+The keys are computed by the pbkdf2 package, whose limits apply, so importing this package
+also registers the pbkdf2 encoder.
+
+The hashes have no $id$ prefix, so the package registers a detector with mcf.RegisterDetector,
+which lets mcf.Verify recognize them. The encoder is registered with mcf.RegisterVerifier.
+*/
+package aspnet
+
+import (
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/gyepisam/mcf"
+	"github.com/gyepisam/mcf/encoder"
+	"github.com/gyepisam/mcf/pbkdf2"
+)
+
+// ErrCreateDisabled is returned by the Create method of the registered encoder.
+var ErrCreateDisabled = fmt.Errorf("%w: aspnet", encoder.ErrCreateDisabled)
+
+// Versions of the hash format, which are the first byte of a hash.
+const (
+	V2 = 0x00
+	V3 = 0x01
+)
+
+// The fixed parameters of version 2 hashes.
+const (
+	v2Iterations = 1000
+	v2SaltLen    = 16
+	v2KeyLen     = 32
+)
+
+// minLen is the minimum length in bytes of the salt and key of version 3 hashes, as required by ASP.NET Identity.
+const minLen = 16
+
+// v3HeaderLen is the length of the version, prf, iterations and salt length of version 3 hashes.
+const v3HeaderLen = 13
+
+// prfs are the pseudorandom functions of version 3 hashes, by number.
+var prfs = []pbkdf2.Hash{pbkdf2.SHA1, pbkdf2.SHA256, pbkdf2.SHA512}
+
+// A blob is a decoded hash.
+type blob struct {
+	version    byte
+	hash       pbkdf2.Hash
+	iterations int
+	salt, key  []byte
+}
+
+func malformed(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: aspnet: "+format, append([]interface{}{encoder.ErrMalformedHash}, args...)...)
+}
+
+// parse decodes and checks a hash.
+func parse(encoded []byte) (*blob, error) {
+	b := make([]byte, base64.StdEncoding.DecodedLen(len(encoded)))
+	n, err := base64.StdEncoding.Decode(b, encoded)
+	if err != nil {
+		return nil, malformed("%s", err)
+	}
+	b = b[:n]
+	if len(b) == 0 {
+		return nil, malformed("empty hash")
+	}
+
+	switch b[0] {
+	case V2:
+		if len(b) != 1+v2SaltLen+v2KeyLen {
+			return nil, malformed("version 2 hash is %d bytes, expected %d", len(b), 1+v2SaltLen+v2KeyLen)
+		}
+		return &blob{
+			version:    V2,
+			hash:       pbkdf2.SHA1,
+			iterations: v2Iterations,
+			salt:       b[1 : 1+v2SaltLen],
+			key:        b[1+v2SaltLen:],
+		}, nil
+
+	case V3:
+		if len(b) < v3HeaderLen {
+			return nil, malformed("version 3 hash is too short")
+		}
+		prf := binary.BigEndian.Uint32(b[1:])
+		iterations := binary.BigEndian.Uint32(b[5:])
+		saltLen := binary.BigEndian.Uint32(b[9:])
+
+		if prf >= uint32(len(prfs)) {
+			return nil, fmt.Errorf("%w: aspnet: unknown prf %d", encoder.ErrUnsupportedVersion, prf)
+		}
+		if iterations < 1 || iterations > uint32(pbkdf2.MaxIterations) {
+			return nil, fmt.Errorf("%w: aspnet: iterations %d is not between 1 and %d", encoder.ErrInvalidParams, iterations, pbkdf2.MaxIterations)
+		}
+		rest := b[v3HeaderLen:]
+		if saltLen < minLen || saltLen > uint32(len(rest)) || saltLen > uint32(pbkdf2.MaxSaltLen) {
+			return nil, malformed("invalid salt length %d", saltLen)
+		}
+		key := rest[saltLen:]
+		if len(key) < minLen || len(key) > pbkdf2.MaxKeyLen {
+			return nil, malformed("invalid key length %d", len(key))
+		}
+		return &blob{
+			version:    V3,
+			hash:       prfs[prf],
+			iterations: int(iterations),
+			salt:       rest[:saltLen],
+			key:        key,
+		}, nil
+	}
+
+	return nil, fmt.Errorf("%w: aspnet: version %d", encoder.ErrUnsupportedVersion, b[0])
+}
+
+// Detect returns true if encoded is an ASP.NET Identity hash of a supported version,
+// even if its iteration count exceeds the limits, so that Verify reports the problem.
+func Detect(encoded []byte) bool {
+	_, err := parse(encoded)
+	return err == nil || errors.Is(err, encoder.ErrInvalidParams)
+}
+
+// detect is the mcf.Detector of the package.
+func detect(encoded []byte) (encoder.Encoder, bool) {
+	if !Detect(encoded) {
+		return nil, false
+	}
+	return mcf.Registered(mcf.ASPNET), true
+}
+
+type aspnet struct{}
+
+func init() {
+	if err := mcf.RegisterVerifier(mcf.ASPNET, aspnet{}); err != nil {
+		panic(err)
+	}
+	if err := mcf.RegisterDetector(detect); err != nil {
+		panic(err)
+	}
+}
+
+// Id returns "aspnet", the name of the encoding. Hashes have no id and are recognized by Detect.
+func (aspnet) Id() []byte {
+	return []byte("aspnet")
+}
+
+// Create returns ErrCreateDisabled.
+func (aspnet) Create(plaintext []byte) (encoded []byte, err error) {
+	return nil, ErrCreateDisabled
+}
+
+// Verify returns true if the plaintext password produces the key of the hash.
+func (aspnet) Verify(plaintext, encoded []byte) (isValid bool, err error) {
+	b, err := parse(encoded)
+	if err != nil {
+		return false, err
+	}
+
+	c := pbkdf2.Config{Hash: b.hash, Iterations: b.iterations, KeyLen: len(b.key)}
+	testKey, err := c.Key(plaintext, b.salt)
+	if err != nil {
+		return false, err
+	}
+	return subtle.ConstantTimeCompare(b.key, testKey) == 1, nil
+}
+
+// IsCurrent returns false for a valid hash, since ASP.NET Identity hashes should be replaced.
+func (aspnet) IsCurrent(encoded []byte) (isCurrent bool, err error) {
+	_, err = parse(encoded)
+	return false, err
+}
+
+// ParseParams implements encoder.ParamsParser.
+// The parameters are "version", "hmac", "iterations" and "saltlen".
+func (aspnet) ParseParams(encoded []byte) (map[string]string, error) {
+	b, err := parse(encoded)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{
+		"version":    strconv.Itoa(int(b.version) + 2),
+		"hmac":       b.hash.String(),
+		"iterations": strconv.Itoa(b.iterations),
+		"saltlen":    strconv.Itoa(len(b.salt)),
+	}, nil
+}
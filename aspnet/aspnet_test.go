@@ -0,0 +1,133 @@
+// Copyright 2014 Gyepi Sam. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package aspnet
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/gyepisam/mcf"
+	"github.com/gyepisam/mcf/encoder"
+)
+
+// Hashes computed with Python's hashlib, following the layout of ASP.NET Identity's PasswordHasher.
+var testVectors = []struct {
+	plaintext string
+	encoded   string
+	params    map[string]string
+}{
+	{
+		"password", "AAABAgMEBQYHCAkKCwwNDg8DCeL+Tgvf59D+SCjUHCNEFuLZv7Yc3Y9kOhHPv9/BGQ==",
+		map[string]string{"version": "2", "hmac": "SHA1", "iterations": "1000", "saltlen": "16"},
+	},
+	{
+		"Pa$$w0rd", "AHNhbHRzYWx0c2FsdHNhbHSytYUg2HHj6UIpeD/1t7tMevWnjdYsN6ak5Sa6EyXOxw==",
+		map[string]string{"version": "2", "hmac": "SHA1", "iterations": "1000", "saltlen": "16"},
+	},
+	{
+		"password", "AQAAAAEAACcQAAAAEAABAgMEBQYHCAkKCwwNDg/rbIFTVZIgPAkrFY+NOQlnI2Km9dvQDZgoBEy6qLJS6Q==",
+		map[string]string{"version": "3", "hmac": "SHA256", "iterations": "10000", "saltlen": "16"},
+	},
+	{
+		"password", "AQAAAAIAAYagAAAAEAABAgMEBQYHCAkKCwwNDg/73hTTOMxvghBX8/SnisILxwGxHjepOzeQw1EOAZRz8w==",
+		map[string]string{"version": "3", "hmac": "SHA512", "iterations": "100000", "saltlen": "16"},
+	},
+	{
+		"hunter2", "AQAAAAAAAAPoAAAAFDAxMjM0NTY3ODlhYmNkZWYwMTIzZdZBhW/AHxGEQX9CWjMsLNBZsVc=",
+		map[string]string{"version": "3", "hmac": "SHA1", "iterations": "1000", "saltlen": "20"},
+	},
+}
+
+func TestVectors(t *testing.T) {
+	enc := mcf.Registered(mcf.ASPNET)
+
+	for i, v := range testVectors {
+		if !Detect([]byte(v.encoded)) {
+			t.Errorf("%d: Detect: got false, expected true", i)
+		}
+
+		isValid, err := mcf.Verify(v.plaintext, v.encoded)
+		if err != nil || !isValid {
+			t.Errorf("%d: Verify: got (%t, %v), expected (true, nil)", i, isValid, err)
+		}
+		isValid, err = mcf.Verify(v.plaintext+"x", v.encoded)
+		if err != nil || isValid {
+			t.Errorf("%d: Verify wrong password: got (%t, %v), expected (false, nil)", i, isValid, err)
+		}
+
+		if isCurrent, err := mcf.IsCurrent(v.encoded); err != nil || isCurrent {
+			t.Errorf("%d: IsCurrent: got (%t, %v), expected (false, nil)", i, isCurrent, err)
+		}
+
+		params, err := enc.(encoder.ParamsParser).ParseParams([]byte(v.encoded))
+		if err != nil {
+			t.Errorf("%d: ParseParams: %s", i, err)
+			continue
+		}
+		for name, want := range v.params {
+			if params[name] != want {
+				t.Errorf("%d: ParseParams: got %v, expected %v", i, params, v.params)
+				break
+			}
+		}
+	}
+}
+
+func TestNotDefault(t *testing.T) {
+	if mcf.Default() == mcf.ASPNET {
+		t.Errorf("aspnet is the default encoding")
+	}
+	if _, err := mcf.Registered(mcf.ASPNET).Create([]byte("password")); !errors.Is(err, mcf.ErrCreateDisabled) {
+		t.Errorf("Create: got %v, expected %v", err, mcf.ErrCreateDisabled)
+	}
+}
+
+func TestMalformed(t *testing.T) {
+	enc := mcf.Registered(mcf.ASPNET)
+
+	for _, tt := range []struct {
+		encoded string
+		want    error
+	}{
+		{"", encoder.ErrMalformedHash},
+		{"not base64!", encoder.ErrMalformedHash},
+		{"AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=", encoder.ErrMalformedHash},                                  // v2, short
+		{"AQAAAAEAACcQAAAACAABAgMEBQYHAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=", encoder.ErrMalformedHash},                  // short salt
+		{"AQAAAAEAACcQAAAAEAABAgMEBQYHCAkKCwwNDg8AAAAAAAAAAA==", encoder.ErrMalformedHash},                                      // short key
+		{"AQAAAAEAACcQ", encoder.ErrMalformedHash},                                                                              // short header
+		{"AQAAAAMAACcQAAAAEAABAgMEBQYHCAkKCwwNDg8AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA==", encoder.ErrUnsupportedVersion}, // unknown prf
+		{"AgAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA==", encoder.ErrUnsupportedVersion},                 // version 4
+		{"AQAAAAEAAAAAAAAAEAABAgMEBQYHCAkKCwwNDg8AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA==", encoder.ErrInvalidParams},      // zero iterations
+	} {
+		if isValid, err := enc.Verify([]byte("password"), []byte(tt.encoded)); isValid || !errors.Is(err, tt.want) {
+			t.Errorf("Verify %q: got (%t, %v), expected (false, %v)", tt.encoded, isValid, err, tt.want)
+		}
+		if _, err := enc.IsCurrent([]byte(tt.encoded)); !errors.Is(err, tt.want) {
+			t.Errorf("IsCurrent %q: got %v, expected %v", tt.encoded, err, tt.want)
+		}
+	}
+}
+
+func TestDetect(t *testing.T) {
+	// Hashes with excessive parameters are detected, so that mcf.Verify reports them.
+	zero := "AQAAAAEAAAAAAAAAEAABAgMEBQYHCAkKCwwNDg8AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=="
+	if _, err := mcf.Verify("password", zero); !errors.Is(err, mcf.ErrInvalidParams) {
+		t.Errorf("Verify %q: got %v, expected %v", zero, err, mcf.ErrInvalidParams)
+	}
+
+	for _, encoded := range []string{
+		"password",
+		"5f4dcc3b5aa765d61d8327deb882cf99",
+		"AQAAAAEAACcQ",
+		"$2a$10$",
+	} {
+		if Detect([]byte(encoded)) {
+			t.Errorf("Detect %q: got true, expected false", encoded)
+		}
+		if _, err := mcf.Verify("password", encoded); !errors.Is(err, mcf.ErrUnknownScheme) {
+			t.Errorf("Verify %q: got %v, expected %v", encoded, err, mcf.ErrUnknownScheme)
+		}
+	}
+}
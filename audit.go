@@ -0,0 +1,176 @@
+// Copyright 2014 Gyepi Sam. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mcf
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gyepisam/mcf/encoder"
+)
+
+// A Report summarizes the encoded passwords examined by Audit.
+// It contains record ids, but never encoded passwords.
+type Report struct {
+	Total   int // Number of records examined.
+	Current int // Records that IsCurrent would accept.
+	Stale   int // Records that IsCurrent would reject, and which should be replaced.
+
+	// States counts the records in each lifecycle state, malformed records excepted.
+	States map[State]int
+
+	// Schemes holds a report for each encoding found.
+	Schemes map[Encoding]*SchemeReport
+
+	// Malformed lists the ids of records that belong to a registered encoder,
+	// but which it could not parse.
+	Malformed []string
+
+	// Unrecognized lists the ids of records that do not belong to any registered encoder.
+	Unrecognized []string
+}
+
+// A SchemeReport summarizes the encoded passwords of a single encoding.
+type SchemeReport struct {
+	Count     int
+	Current   int
+	Stale     int
+	Malformed int
+
+	// Params is a histogram of parameter values: Params[name][value] is the number of records
+	// in which the named parameter has that value, for instance Params["N"]["65536"].
+	// It is only populated for encoders that implement encoder.ParamsParser.
+	Params map[string]map[string]int
+}
+
+// Audit classifies the encoded passwords produced by next, which returns the id and encoded password
+// of each record in turn and false when there are no more. The id is only used to identify records
+// in the Report and may be anything, such as a user name or a row number.
+//
+// Audit only parses encoded passwords and never computes a key, so it is fast enough to examine
+// every record in a large database. It does not notify observers.
+// Use it to find out how many passwords a policy change would affect:
+// change the configuration, then audit.
+func Audit(next func() (id, encoded string, ok bool)) Report {
+	r := Report{Schemes: map[Encoding]*SchemeReport{}, States: map[State]int{}}
+	now := time.Now()
+
+	for {
+		id, encoded, ok := next()
+		if !ok {
+			break
+		}
+		r.add(id, []byte(encoded), now)
+	}
+
+	return r
+}
+
+func (r *Report) add(id string, encoded []byte, now time.Time) {
+	r.Total++
+
+	encoding, enc, _ := findInstance(encoded)
+	if enc == nil {
+		r.Unrecognized = append(r.Unrecognized, id)
+		return
+	}
+
+	s := r.Schemes[encoding]
+	if s == nil {
+		s = &SchemeReport{Params: map[string]map[string]int{}}
+		r.Schemes[encoding] = s
+	}
+	s.Count++
+
+	isCurrent, err := currentFor(encoding, enc, encoded, defaultEncoding, enc)
+	if err != nil {
+		s.Malformed++
+		r.Malformed = append(r.Malformed, id)
+		return
+	}
+
+	state, _ := stateFor(encoding, enc, encoded, now)
+	r.States[state]++
+
+	if isCurrent {
+		s.Current++
+		r.Current++
+	} else {
+		s.Stale++
+		r.Stale++
+	}
+
+	if p, ok := enc.Encoder.(encoder.ParamsParser); ok {
+		params, err := p.ParseParams(encoded)
+		if err != nil {
+			return
+		}
+		for name, value := range params {
+			h := s.Params[name]
+			if h == nil {
+				h = map[string]int{}
+				s.Params[name] = h
+			}
+			h[value]++
+		}
+	}
+}
+
+// WriteTo writes a readable summary of the report to w.
+func (r *Report) WriteTo(w io.Writer) (n int64, err error) {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "total %d, current %d, stale %d, malformed %d, unrecognized %d\n",
+		r.Total, r.Current, r.Stale, len(r.Malformed), len(r.Unrecognized))
+
+	for st := StatePreferred; st <= StateRejected; st++ {
+		if n := r.States[st]; n > 0 {
+			fmt.Fprintf(&b, "%s %d\n", st, n)
+		}
+	}
+
+	var encodings []Encoding
+	for e := range r.Schemes {
+		encodings = append(encodings, e)
+	}
+	sort.Slice(encodings, func(i, j int) bool { return encodings[i] < encodings[j] })
+
+	for _, e := range encodings {
+		s := r.Schemes[e]
+		fmt.Fprintf(&b, "%s: count %d, current %d, stale %d, malformed %d\n",
+			e, s.Count, s.Current, s.Stale, s.Malformed)
+
+		for _, name := range sortedKeys(s.Params) {
+			h := s.Params[name]
+			for _, value := range sortedKeys(h) {
+				fmt.Fprintf(&b, "\t%s=%s\t%d\n", name, value, h[value])
+			}
+		}
+	}
+
+	m, err := io.WriteString(w, b.String())
+	return int64(m), err
+}
+
+// sortedKeys returns the keys of m in order, numerically if they are numbers.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, aerr := strconv.Atoi(keys[i])
+		b, berr := strconv.Atoi(keys[j])
+		if aerr == nil && berr == nil {
+			return a < b
+		}
+		return keys[i] < keys[j]
+	})
+	return keys
+}
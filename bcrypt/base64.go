@@ -1,4 +1,4 @@
-// Copied from crypto/bcrypt for testing.
+// Copied from crypto/bcrypt.
 
 // Copyright 2011 The Go Authors. All rights reserved.
 // Use of this source code is governed by a BSD-style
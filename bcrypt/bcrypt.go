@@ -6,7 +6,11 @@
 package bcrypt
 
 import (
+	"fmt"
+	"strconv"
+
 	"github.com/gyepisam/mcf"
+	"github.com/gyepisam/mcf/encoder"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -15,8 +19,24 @@ import (
 // Use SetCost() to change it.
 const DefaultCost = 12
 
+// MaxCost is the highest cost that is accepted, both by SetCost and in encoded passwords.
+// It defaults to the highest cost that crypto/bcrypt accepts, so that all stored passwords verify.
+// Since each increment doubles the work, it may be lowered to guard against the exhaustion of time
+// by a corrupt or malicious encoded password; stored passwords with a higher cost are then
+// rejected with ErrInvalidParams.
+var MaxCost = bcrypt.MaxCost
+
+// Config contains the parameters of the Bcrypt algorithm.
+// Use it with SetConfig, or to define mcf profiles; see mcf.DefineProfile.
+type Config struct {
+	Cost int // The base 2 logarithm of the work factor.
+
+	SaltMine mcf.SaltMiner // Source of the 16 bytes of salt; see mcf.SaltMiner.
+}
+
 type config struct {
-	Cost int
+	Cost     int
+	saltMine mcf.SaltMiner
 }
 
 func register(c config) error {
@@ -24,21 +44,44 @@ func register(c config) error {
 }
 
 func init() {
-	err := register(config{DefaultCost})
+	err := register(config{Cost: DefaultCost})
 	if err != nil {
 		panic(err)
 	}
+	mcf.RegisterFactory(mcf.BCRYPT, mcf.NewFactory((*Config).validate, newEncoder))
+}
+
+func newEncoder(c Config) encoder.Encoder {
+	return &config{c.Cost, c.SaltMine}
+}
+
+func (c *Config) validate() error {
+	return checkCost(c.Cost)
+}
+
+func checkCost(cost int) error {
+	if cost > MaxCost {
+		return fmt.Errorf("%w: bcrypt: cost %d exceeds MaxCost %d", encoder.ErrInvalidParams, cost, MaxCost)
+	}
+	// A cost below bcrypt.MinCost is accepted; crypto/bcrypt replaces it with its own default.
+	return nil
 }
 
 // SetCost sets the cost parameter of the Bcrypt algorithm.
 // The value is the base 2 logarithm of the work factor.
 func SetCost(cost int) error {
-	// punt and see if the underlying algorithm likes the new value!
-	_, err := bcrypt.GenerateFromPassword([]byte("password"), cost)
-	if err != nil {
+	if err := checkCost(cost); err != nil {
 		return err
 	}
-	return register(config{cost})
+	return register(config{Cost: cost})
+}
+
+// SetConfig sets the cost parameter and salt source of the Bcrypt algorithm.
+func SetConfig(c Config) error {
+	if err := c.validate(); err != nil {
+		return err
+	}
+	return register(config{c.Cost, c.SaltMine})
 }
 
 func (c *config) Id() []byte {
@@ -46,22 +89,75 @@ func (c *config) Id() []byte {
 }
 
 func (c *config) Create(plaintext []byte) (encoded []byte, err error) {
-	return bcrypt.GenerateFromPassword(plaintext, c.Cost)
+	if c.saltMine == nil {
+		return bcrypt.GenerateFromPassword(plaintext, c.Cost)
+	}
+	salt, err := mcf.Salt(saltLen, c.saltMine)
+	if err != nil {
+		return nil, fmt.Errorf("bcrypt: salt: %w", err)
+	}
+	return generate(plaintext, c.Cost, salt)
 }
 
+// encodedLen is the length of an encoded password.
+// crypto/bcrypt ignores anything that follows, so it is checked here.
+const encodedLen = 60
+
+var errLength = fmt.Errorf("%w: bcrypt: encoded password must be %d bytes", encoder.ErrMalformedHash, encodedLen)
+
 func (c *config) Verify(plaintext, encoded []byte) (isValid bool, err error) {
+	if len(encoded) != encodedLen {
+		return false, errLength
+	}
+	if cost, err := bcrypt.Cost(encoded); err != nil {
+		return false, wrapError(err)
+	} else if cost > MaxCost {
+		return false, fmt.Errorf("%w: bcrypt: cost %d exceeds MaxCost %d", encoder.ErrInvalidParams, cost, MaxCost)
+	}
 	err = bcrypt.CompareHashAndPassword(encoded, plaintext)
 	isValid = err == nil
 	if err == bcrypt.ErrMismatchedHashAndPassword {
 		err = nil
 	}
-	return
+	return isValid, wrapError(err)
 }
 
 func (c *config) IsCurrent(encoded []byte) (isCurrent bool, err error) {
+	if len(encoded) != encodedLen {
+		return false, errLength
+	}
 	cost, err := bcrypt.Cost(encoded)
 	if err == nil {
 		isCurrent = cost >= c.Cost
 	}
-	return
+	return isCurrent, wrapError(err)
+}
+
+// wrapError classifies the errors returned by crypto/bcrypt for an encoded password.
+func wrapError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	kind := encoder.ErrMalformedHash
+	switch err.(type) {
+	case bcrypt.HashVersionTooNewError:
+		kind = encoder.ErrUnsupportedVersion
+	case bcrypt.InvalidCostError:
+		kind = encoder.ErrInvalidParams
+	}
+
+	return fmt.Errorf("%w: %s", kind, err)
+}
+
+// ParseParams implements encoder.ParamsParser. The only parameter is "cost".
+func (c *config) ParseParams(encoded []byte) (params map[string]string, err error) {
+	if len(encoded) != encodedLen {
+		return nil, errLength
+	}
+	cost, err := bcrypt.Cost(encoded)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	return map[string]string{"cost": strconv.Itoa(cost)}, nil
 }
@@ -10,7 +10,9 @@ package bcrypt
 import (
 	"bytes"
 	"crypto/rand"
+	"errors"
 	"fmt"
+	"strconv"
 	"strings"
 
 	"github.com/gyepisam/mcf"
@@ -152,7 +154,7 @@ func TestVectors(t *testing.T) {
 
 			isCurrent, err := mcf.IsCurrent(encoded)
 			if err != nil {
-				t.Errorf("%d-%d: IsCurrent: unexpected failure: %", i, j, err)
+				t.Errorf("%d-%d: IsCurrent: unexpected failure: %s", i, j, err)
 				continue
 			}
 			if isCurrent != pair.answer {
@@ -162,3 +164,74 @@ func TestVectors(t *testing.T) {
 		}
 	}
 }
+
+// Config.SaltMine makes the output deterministic without hijacking rand.Reader.
+func TestSaltMine(t *testing.T) {
+	defer SetCost(DefaultCost)
+
+	for i, v := range testVectors {
+		x := strings.Split(v.salt[1:], "$")
+		if x[1] > "08" {
+			continue // too slow
+		}
+		salt, err := base64Decode([]byte(x[2]))
+		if err != nil {
+			t.Fatalf("%d: error decoding salt: %s", i, err)
+		}
+		cost, err := strconv.Atoi(x[1])
+		if err != nil {
+			t.Fatalf("%d: error decoding cost: %s", i, err)
+		}
+
+		err = SetConfig(Config{Cost: cost, SaltMine: func(n int) ([]byte, error) {
+			if n != len(salt) {
+				t.Fatalf("%d: salt length: want %d, got %d", i, len(salt), n)
+			}
+			return salt, nil
+		}})
+		if err != nil {
+			t.Fatalf("%d: SetConfig: unexpected error: %s", i, err)
+		}
+
+		encoded, err := mcf.Create(v.plain)
+		if err != nil {
+			t.Errorf("%d: unexpected error: %s", i, err)
+			continue
+		}
+		if want, got := v.passwd, encoded; want != got {
+			t.Errorf("%d: output mismatch. want: %s, got %s", i, want, got)
+		}
+	}
+
+	failing := func(n int) ([]byte, error) { return nil, errors.New("no salt") }
+	if err := SetConfig(Config{Cost: 4, SaltMine: failing}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mcf.Create("password"); err == nil {
+		t.Errorf("Create with a failing SaltMine: expected an error")
+	}
+}
+
+// Stored passwords with any cost that crypto/bcrypt accepts verify unless MaxCost is lowered.
+func TestMaxCost(t *testing.T) {
+	encoded := []byte(testVectors[0].passwd) // cost 6
+
+	if MaxCost != 31 {
+		t.Errorf("MaxCost: expected 31, got %d", MaxCost)
+	}
+	enc := mcf.Registered(mcf.BCRYPT)
+
+	defer func(cost int) { MaxCost = cost }(MaxCost)
+	MaxCost = 5
+	if _, err := enc.Verify([]byte(testVectors[0].plain), encoded); !errors.Is(err, mcf.ErrInvalidParams) {
+		t.Errorf("Verify with cost above MaxCost: got %v, expected %v", err, mcf.ErrInvalidParams)
+	}
+	if err := SetCost(6); !errors.Is(err, mcf.ErrInvalidParams) {
+		t.Errorf("SetCost above MaxCost: got %v, expected %v", err, mcf.ErrInvalidParams)
+	}
+
+	MaxCost = 6
+	if isValid, err := enc.Verify([]byte(testVectors[0].plain), encoded); err != nil || !isValid {
+		t.Errorf("Verify: got (%t, %v), expected (true, nil)", isValid, err)
+	}
+}
@@ -0,0 +1,59 @@
+// Adapted from crypto/bcrypt, which does not accept a salt.
+
+// Copyright 2011 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bcrypt
+
+import (
+	"fmt"
+
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/blowfish"
+)
+
+// saltLen is the length of a bcrypt salt in bytes.
+const saltLen = 16
+
+// magicCipherData is an IV for the 64 Blowfish encryption calls in
+// bcrypt(). It's the string "OrpheanBeholderScryDoubt" in big-endian bytes.
+var magicCipherData = []byte("OrpheanBeholderScryDoubt")
+
+// generate is bcrypt.GenerateFromPassword with the given salt, which must be saltLen bytes.
+func generate(password []byte, cost int, salt []byte) ([]byte, error) {
+	if len(password) > 72 {
+		return nil, bcrypt.ErrPasswordTooLong
+	}
+	if cost < bcrypt.MinCost {
+		cost = bcrypt.DefaultCost
+	}
+
+	// Bug compatibility with C bcrypt implementations. They use the trailing
+	// NULL in the key string during expansion.
+	ckey := append(password[:len(password):len(password)], 0)
+
+	c, err := blowfish.NewSaltedCipher(ckey, salt)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, rounds := uint64(0), uint64(1)<<uint(cost); i < rounds; i++ {
+		blowfish.ExpandKey(ckey, c)
+		blowfish.ExpandKey(salt, c)
+	}
+
+	cipherData := append([]byte(nil), magicCipherData...)
+	for i := 0; i < 24; i += 8 {
+		for j := 0; j < 64; j++ {
+			c.Encrypt(cipherData[i:i+8], cipherData[i:i+8])
+		}
+	}
+
+	// Bug compatibility with C bcrypt implementations. We only encode 23 of
+	// the 24 bytes encrypted.
+	encoded := fmt.Appendf(nil, "$2a$%02d$", cost)
+	encoded = append(encoded, base64Encode(salt)...)
+	encoded = append(encoded, base64Encode(cipherData[:23])...)
+	return encoded, nil
+}
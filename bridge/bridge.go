@@ -10,7 +10,10 @@
 package bridge
 
 import (
+	"bytes"
 	"crypto/subtle"
+	"fmt"
+	"strings"
 
 	"github.com/gyepisam/mcf/encoder"
 	"github.com/gyepisam/mcf/password"
@@ -36,6 +39,13 @@ type Implementer interface {
 	AtLeast(Implementer) bool
 }
 
+// A KeySizer is an Implementer whose key length can be changed.
+// Encoder.DeriveKey requires it when a key length is requested.
+type KeySizer interface {
+	// SetKeyLen sets the key length in bytes, and validates it.
+	SetKeyLen(int) error
+}
+
 // Encoder implements the encoder.Encoder interface using an Implementer to
 // abstract implementation specific parts.
 type Encoder struct {
@@ -120,3 +130,97 @@ func (enc *Encoder) IsCurrent(encoded []byte) (isCurrent bool, err error) {
 
 	return imp.AtLeast(enc.implementer()), nil
 }
+
+// ParseParams implements encoder.ParamsParser for Implementers whose Params are
+// comma separated name=value pairs, as are those of scrypt and pbkdf2.
+// The parameters are validated by SetParams.
+func (enc *Encoder) ParseParams(encoded []byte) (params map[string]string, err error) {
+
+	passwd := password.New(enc.name)
+
+	err = passwd.Parse(encoded)
+	if err != nil {
+		return
+	}
+
+	err = enc.implementer().SetParams(string(passwd.Params))
+	if err != nil {
+		return
+	}
+
+	params = make(map[string]string)
+	for _, field := range strings.Split(string(passwd.Params), ",") {
+		name, value, _ := strings.Cut(field, "=")
+		params[name] = value
+	}
+
+	return params, nil
+}
+
+// DeriveKey implements encoder.KeyDeriver.
+// The record is validated by SetParams, and the key length by the Implementer's SetKeyLen.
+func (enc *Encoder) DeriveKey(record, passphrase, salt []byte, keyLen int) (key, out []byte, err error) {
+
+	imp := enc.implementer()
+	passwd := password.New(enc.name)
+
+	if len(record) > 0 {
+		// A record lacks the key field, and possibly the salt field, of an encoded password.
+		switch bytes.Count(record, []byte{'$'}) {
+		case 2:
+			record = append(record[:len(record):len(record)], "$$"...)
+		case 3:
+			record = append(record[:len(record):len(record)], '$')
+		default:
+			return nil, nil, password.ErrorInputPassword{
+				Msg:      fmt.Sprintf("%s: record must have the form $id$params or $id$params$salt", enc.name),
+				Password: string(record),
+			}
+		}
+
+		err = passwd.Parse(record)
+		if err != nil {
+			return
+		}
+
+		err = imp.SetParams(string(passwd.Params))
+		if err != nil {
+			return
+		}
+
+		if salt == nil && len(passwd.Salt) > 0 {
+			salt = passwd.Salt
+		}
+	}
+
+	if keyLen > 0 {
+		sizer, ok := imp.(KeySizer)
+		if !ok {
+			return nil, nil, fmt.Errorf("%w: %s: the key length cannot be changed", encoder.ErrInvalidParams, enc.name)
+		}
+		err = sizer.SetKeyLen(keyLen)
+		if err != nil {
+			return
+		}
+	}
+
+	if salt == nil {
+		salt, err = imp.Salt()
+		if err != nil {
+			return
+		}
+	}
+
+	key, err = imp.Key(passphrase, salt)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	passwd.Params = []byte(imp.Params())
+	passwd.Salt = salt
+	passwd.Key = nil
+
+	// Drop the empty key field.
+	out = passwd.Bytes()
+	return key, out[:len(out)-1], nil
+}
@@ -0,0 +1,68 @@
+// Copyright 2014 Gyepi Sam. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mcf
+
+import (
+	"fmt"
+
+	"github.com/gyepisam/mcf/encoder"
+)
+
+// DeriveKey derives a key of the given length, in bytes, from a passphrase and salt,
+// for uses other than password verification, such as file encryption.
+// It uses the same parameters, validation and limits as password encoding.
+//
+// The params argument selects the encoder and its parameters, and is one of:
+//
+//   - an Encoding, to use the encoder's current configuration,
+//   - a Profile, to use an encoder specific configuration, such as a scrypt.Config,
+//   - a string holding a record returned by an earlier call, to derive the same key again.
+//
+// If salt is nil, the salt in the record is used or, failing that, new salt is produced.
+// If length is 0, the length given by the parameters is used.
+//
+// The returned record, in the form $id$params$salt, describes the derivation and should be stored
+// with the encrypted data. It is not an encoded password and does not contain the key. For instance:
+//
+//	key, record, err := mcf.DeriveKey(mcf.Profile{Encoding: mcf.SCRYPT, Config: config}, passphrase, nil, 32)
+//	...
+//	key, _, err = mcf.DeriveKey(record, passphrase, nil, 0)
+//
+// Only encoders that implement encoder.KeyDeriver, such as scrypt and pbkdf2, can derive keys.
+// DeriveKey does not run hooks or notify observers.
+func DeriveKey(params interface{}, passphrase, salt []byte, length int) (key []byte, record string, err error) {
+	if length < 0 {
+		return nil, "", fmt.Errorf("%w: mcf: negative key length %d", ErrInvalidParams, length)
+	}
+
+	var inst *instance
+	var in []byte
+
+	switch p := params.(type) {
+	case Encoding:
+		inst, err = Profile{Encoding: p}.instance()
+	case Profile:
+		inst, err = p.instance()
+	case string:
+		in = []byte(p)
+		_, inst, err = findInstance(in)
+	default:
+		err = fmt.Errorf("mcf: DeriveKey: unsupported params type %T", params)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	kd, ok := inst.Encoder.(encoder.KeyDeriver)
+	if !ok {
+		return nil, "", fmt.Errorf("%w: mcf: %s cannot derive keys", ErrUnknownScheme, inst.id)
+	}
+
+	key, out, err := kd.DeriveKey(in, passphrase, salt, length)
+	if err != nil {
+		return nil, "", err
+	}
+	return key, string(out), nil
+}
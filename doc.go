@@ -3,7 +3,7 @@
 // license that can be found in the LICENSE file.
 
 /*
-Package mcf is a Go library for creating, verifying, upgrading and managing bcrypt, scrypt, and pbkdf2 password hashes.
+Package mcf is a Go library for creating, verifying, upgrading and managing bcrypt, scrypt, pbkdf2 and yescrypt password hashes.
 
 mcf provides a simple API for applications to use a variety of
 password hashing schemes as well a management mechanism to easily and
@@ -22,6 +22,10 @@ There's an API to set the default encoder.
 Note that once an encoder has been superceded (is no longer the first imported encoding)
 it must not be removed from the import lists until all existing instances of
 that encoding have either been converted to a newer encoding or invalidated.
+Encoders of legacy schemes, such as phpass, only verify and never become the default.
+Those whose passwords have no $id$ prefix, such as aspnet, ldap and the Django passwords of pbkdf2,
+are found by detectors; see RegisterDetectorPrecedence. Unsalted hex digests are found
+only after a call to legacy.Enable.
 
   import (
     "github.com/gyepisam/mcf"
@@ -25,3 +25,34 @@ type Encoder interface {
 	// the application should call mcf.Create() to produce a new encoding to replace the current one.
 	IsCurrent(encoded []byte) (isCurrent bool, err error)
 }
+
+// A ParamsParser is an Encoder that can report the parameters of an encoded password,
+// such as work factors, without computing a key. It is used by mcf.Audit.
+// Implementing it is optional.
+type ParamsParser interface {
+	// ParseParams returns the parameters of the encoded password, by name.
+	ParseParams(encoded []byte) (params map[string]string, err error)
+}
+
+// An Aliaser is an Encoder that also handles encoded passwords with ids other than its own,
+// such as those produced by other implementations of the same algorithm.
+// Implementing it is optional.
+type Aliaser interface {
+	// Aliases returns the other ids, which, like Id, do not include separators.
+	Aliases() [][]byte
+}
+
+// A KeyDeriver is an Encoder that can also derive raw keys, such as encryption keys, from a passphrase.
+// It is used by mcf.DeriveKey. Implementing it is optional.
+type KeyDeriver interface {
+	// DeriveKey derives a key from passphrase and salt.
+	//
+	// If record is not empty, it holds the parameters to use, in the form $id$params or $id$params$salt,
+	// as returned by an earlier call. Otherwise the encoder's current configuration is used.
+	// If salt is nil, the salt in record is used or, failing that, new salt is produced.
+	// If keyLen is positive, it overrides the key length in the parameters.
+	//
+	// It returns the key and a record, in the form $id$params$salt, from which the same key can be
+	// derived again. The record does not contain the key and does not verify the passphrase.
+	DeriveKey(record, passphrase, salt []byte, keyLen int) (key, out []byte, err error)
+}
@@ -0,0 +1,18 @@
+// Copyright 2014 Gyepi Sam. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package encoder
+
+import "errors"
+
+// Errors that encoders wrap, so that callers can classify failures with errors.Is,
+// regardless of the encoder that produced them.
+// The mcf package exports the same values; see the documentation there.
+var (
+	ErrMalformedHash      = errors.New("malformed hash")
+	ErrUnknownScheme      = errors.New("unknown password scheme")
+	ErrInvalidParams      = errors.New("invalid hash parameters")
+	ErrUnsupportedVersion = errors.New("unsupported hash version")
+	ErrCreateDisabled     = errors.New("create is disabled")
+)
@@ -10,9 +10,17 @@ type Encoding uint8
 
 // List of known encodings.
 const (
-	BCRYPT Encoding = iota // import "github.com/gyepisam/mcf/bcrypt"
-	SCRYPT                 // import "github.com/gyepisam/mcf/scrypt"
-	PBKDF2                 // import "github.com/gyepisam/mcf/pbkdf2"
+	BCRYPT   Encoding = iota // import "github.com/gyepisam/mcf/bcrypt"
+	SCRYPT                   // import "github.com/gyepisam/mcf/scrypt"
+	PBKDF2                   // import "github.com/gyepisam/mcf/pbkdf2"
+	MCFTEST                  // import "github.com/gyepisam/mcf/mcftest". For tests only.
+	YESCRYPT                 // import "github.com/gyepisam/mcf/yescrypt"
+	PHPASS                   // import "github.com/gyepisam/mcf/phpass". Verifies only, by default.
+	SCRAM                    // import "github.com/gyepisam/mcf/scram"
+	SRP                      // import "github.com/gyepisam/mcf/srp"
+	ASPNET                   // import "github.com/gyepisam/mcf/aspnet". Verifies only.
+	LDAP                     // import "github.com/gyepisam/mcf/ldap". Verifies only.
+	LEGACY                   // import "github.com/gyepisam/mcf/legacy" and call legacy.Enable(). Verifies only.
 	//CRYPT                       // Not implemented yet
 
 	maxEncoding
@@ -28,6 +36,22 @@ func (e Encoding) String() string {
 		return "scrypt"
 	case PBKDF2:
 		return "pbkdf2"
+	case MCFTEST:
+		return "mcftest"
+	case YESCRYPT:
+		return "yescrypt"
+	case PHPASS:
+		return "phpass"
+	case SCRAM:
+		return "scram"
+	case SRP:
+		return "srp"
+	case ASPNET:
+		return "aspnet"
+	case LDAP:
+		return "ldap"
+	case LEGACY:
+		return "legacy"
 		/*	case CRYPT:
 			return "crypt" */
 	}
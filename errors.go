@@ -0,0 +1,40 @@
+// Copyright 2014 Gyepi Sam. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mcf
+
+import "github.com/gyepisam/mcf/encoder"
+
+/*
+Errors returned by Verify and IsCurrent wrap one of the following values,
+so callers can classify them with errors.Is, whichever encoder produced them.
+
+A password that does not match is not an error: Verify returns false and a nil error.
+An error always means that the stored record, or the configuration, is at fault,
+not the password offered, and should be logged and investigated rather than reported
+to the user as a failed login.
+
+No error message includes a plaintext password, nor an encoded password.
+*/
+var (
+	// ErrMalformedHash means that the encoded password is corrupt:
+	// it has the wrong structure or its fields cannot be decoded.
+	ErrMalformedHash = encoder.ErrMalformedHash
+
+	// ErrUnknownScheme means that the encoded password does not belong to any registered encoder.
+	// Perhaps an encoder has not been imported.
+	ErrUnknownScheme = encoder.ErrUnknownScheme
+
+	// ErrInvalidParams means that the parameters of the encoded password, or of a configuration,
+	// are unparseable or out of range.
+	ErrInvalidParams = encoder.ErrInvalidParams
+
+	// ErrUnsupportedVersion means that the encoded password was produced by a newer
+	// or otherwise unsupported version of its scheme.
+	ErrUnsupportedVersion = encoder.ErrUnsupportedVersion
+
+	// ErrCreateDisabled means that the encoder only verifies passwords; see RegisterVerifier.
+	// It is returned by the Create method of such encoders.
+	ErrCreateDisabled = encoder.ErrCreateDisabled
+)
@@ -0,0 +1,106 @@
+// Copyright 2014 Gyepi Sam. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mcf
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// A Hash is an encoded password, as produced by Create.
+// It is a drop in replacement for a string field in a database model:
+// it implements sql.Scanner and driver.Valuer for storage, and wraps Verify and IsCurrent.
+//
+// Since encoded passwords should not be disclosed, a Hash redacts itself when it is
+// printed, logged, or marshaled to JSON or text. Only the scheme and parameters are shown,
+// which is enough to tell what kind of hash it is.
+// Use string(h) where the encoded value is actually needed.
+type Hash string
+
+// redacted replaces the hash fields of an encoded password.
+const redacted = "[REDACTED]"
+
+// NewHash returns a Hash of plaintext, created with Create.
+func NewHash(plaintext string) (Hash, error) {
+	encoded, err := Create(plaintext)
+	return Hash(encoded), err
+}
+
+// Verify returns true if plaintext matches the hash. See Verify.
+func (h Hash) Verify(plaintext string) (isValid bool, err error) {
+	return Verify(plaintext, string(h))
+}
+
+// IsCurrent returns true if the hash is up to date with the current policy. See IsCurrent.
+func (h Hash) IsCurrent() (isCurrent bool, err error) {
+	return IsCurrent(string(h))
+}
+
+// String returns the scheme and parameters of the hash, with the remainder redacted.
+// An empty Hash produces an empty string.
+func (h Hash) String() string {
+	s := string(h)
+	if s == "" {
+		return ""
+	}
+
+	if s[0] != '$' {
+		return redacted
+	}
+
+	// "", id, params, ...
+	parts := strings.SplitN(s, "$", 4)
+	switch len(parts) {
+	case 4:
+		return strings.Join(parts[:3], "$") + "$" + redacted
+	case 3:
+		return strings.Join(parts[:2], "$") + "$" + redacted
+	}
+	return redacted
+}
+
+// GoString redacts the hash for the %#v verb.
+func (h Hash) GoString() string {
+	return fmt.Sprintf("mcf.Hash(%q)", h.String())
+}
+
+// MarshalJSON produces the redacted form of the hash as a JSON string.
+func (h Hash) MarshalJSON() ([]byte, error) {
+	return json.Marshal(h.String())
+}
+
+// MarshalText produces the redacted form of the hash.
+func (h Hash) MarshalText() ([]byte, error) {
+	return []byte(h.String()), nil
+}
+
+// LogValue implements slog.LogValuer and logs the redacted form of the hash.
+func (h Hash) LogValue() slog.Value {
+	return slog.StringValue(h.String())
+}
+
+// Scan implements the sql.Scanner interface.
+// A NULL value produces an empty Hash.
+func (h *Hash) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*h = ""
+	case string:
+		*h = Hash(v)
+	case []byte:
+		*h = Hash(v)
+	default:
+		return fmt.Errorf("mcf: cannot scan %T into Hash", src)
+	}
+	return nil
+}
+
+// Value implements the driver.Valuer interface and stores the complete encoded password.
+func (h Hash) Value() (driver.Value, error) {
+	return string(h), nil
+}
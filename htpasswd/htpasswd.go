@@ -0,0 +1,411 @@
+// Copyright 2014 Gyepi Sam. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Package htpasswd reads, verifies and updates Apache style htpasswd files.
+
+Each line of an htpasswd file has the form user:hash. The hash may be any of
+
+	$2y$..., $2a$..., $2b$...  bcrypt
+	$apr1$...                  Apache MD5
+	{SHA}...                   base64 encoded, unsalted SHA-1
+	13 characters              traditional crypt(3)
+
+or any other format that mcf understands. bcrypt and other Modular Crypt Format
+entries are verified by the registered mcf encoders, so the appropriate encoders must be imported.
+New entries are created with mcf.Create. Note that Apache only understands bcrypt,
+so mcf.SetDefault(mcf.BCRYPT) is advised if the file is shared with Apache.
+
+A File keeps an htpasswd file in memory, reloads it whenever it changes on disk,
+and rewrites it atomically after any modification.
+*/
+package htpasswd
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gyepisam/mcf"
+)
+
+const shaPrefix = "{SHA}"
+
+// ErrNoUser is returned when an operation refers to a user that is not in the file.
+var ErrNoUser = errors.New("htpasswd: no such user")
+
+// ErrInvalidUser is returned for user names that cannot be stored in an htpasswd file.
+var ErrInvalidUser = errors.New("htpasswd: invalid user name")
+
+// Verify returns true if plaintext matches hash, which may be in any of the supported formats.
+func Verify(plaintext, hash string) (isValid bool, err error) {
+	b := []byte(plaintext)
+	switch {
+	case strings.HasPrefix(hash, apr1Magic):
+		return verifyAPR1(b, hash), nil
+	case strings.HasPrefix(hash, shaPrefix):
+		sum := sha1.Sum(b)
+		want := shaPrefix + base64.StdEncoding.EncodeToString(sum[:])
+		return subtle.ConstantTimeCompare([]byte(want), []byte(hash)) == 1, nil
+	case strings.HasPrefix(hash, "$"):
+		return mcf.Verify(plaintext, normalize(hash))
+	case isDESCrypt(hash):
+		return verifyDESCrypt(b, hash), nil
+	}
+	return false, fmt.Errorf("htpasswd: %w", mcf.ErrUnknownScheme)
+}
+
+// IsCurrent returns false if hash should be replaced.
+// Entries that mcf cannot create are never current.
+func IsCurrent(hash string) (isCurrent bool, err error) {
+	if strings.HasPrefix(hash, apr1Magic) || !strings.HasPrefix(hash, "$") {
+		return false, nil
+	}
+	return mcf.IsCurrent(normalize(hash))
+}
+
+// normalize rewrites the bcrypt variants used by Apache and others into the form mcf recognizes.
+// The variants only differ in the bugs of the C implementations that produced them,
+// none of which affect the Go implementation.
+func normalize(hash string) string {
+	if strings.HasPrefix(hash, "$2y$") || strings.HasPrefix(hash, "$2b$") {
+		return "$2a$" + hash[4:]
+	}
+	return hash
+}
+
+// A line is a single line of an htpasswd file.
+// Blank lines and comments have an empty user and are preserved as is.
+type line struct {
+	user string
+	hash string
+}
+
+func (l line) String() string {
+	if l.user == "" {
+		return l.hash
+	}
+	return l.user + ":" + l.hash
+}
+
+func parse(r io.Reader) (lines []line, err error) {
+	s := bufio.NewScanner(r)
+	for n := 1; s.Scan(); n++ {
+		text := strings.TrimRight(s.Text(), "\r")
+		if t := strings.TrimSpace(text); t == "" || t[0] == '#' {
+			lines = append(lines, line{hash: text})
+			continue
+		}
+		i := strings.IndexByte(text, ':')
+		if i <= 0 {
+			return nil, fmt.Errorf("htpasswd: line %d: expected user:hash", n)
+		}
+		lines = append(lines, line{user: text[:i], hash: text[i+1:]})
+	}
+	return lines, s.Err()
+}
+
+// A File is an htpasswd file. It is safe for concurrent use.
+type File struct {
+	// Upgrade enables the replacement of stale entries with mcf.Create
+	// when they are successfully verified.
+	Upgrade bool
+
+	// UpgradeError, if set, is called with the error if a stale entry cannot be upgraded.
+	// The password is valid all the same, so Verify returns true and a nil error.
+	UpgradeError func(user string, err error)
+
+	path    string
+	mu      sync.Mutex
+	lines   []line
+	modTime time.Time
+	size    int64
+
+	once     sync.Once
+	dummy    string
+	dummyErr error
+}
+
+// Open reads the named htpasswd file. If the file does not exist, it is created by the first update.
+func Open(path string) (*File, error) {
+	f := &File{path: path}
+	return f, f.Reload()
+}
+
+// Reload rereads the file from disk.
+func (f *File) Reload() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.load()
+}
+
+func (f *File) load() error {
+	fh, err := os.Open(f.path)
+	if os.IsNotExist(err) {
+		f.lines, f.modTime, f.size = nil, time.Time{}, 0
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+
+	fi, err := fh.Stat()
+	if err != nil {
+		return err
+	}
+
+	lines, err := parse(fh)
+	if err != nil {
+		return fmt.Errorf("%s: %w", f.path, err)
+	}
+
+	f.lines, f.modTime, f.size = lines, fi.ModTime(), fi.Size()
+	return nil
+}
+
+// refresh reloads the file if it has changed since it was last read.
+func (f *File) refresh() error {
+	fi, err := os.Stat(f.path)
+	if os.IsNotExist(err) {
+		if f.size == 0 && f.modTime.IsZero() {
+			return nil
+		}
+		return f.load()
+	}
+	if err != nil {
+		return err
+	}
+	if fi.ModTime().Equal(f.modTime) && fi.Size() == f.size {
+		return nil
+	}
+	return f.load()
+}
+
+func (f *File) find(user string) int {
+	for i, l := range f.lines {
+		if l.user != "" && l.user == user {
+			return i
+		}
+	}
+	return -1
+}
+
+// Users returns the users in the file, in file order.
+func (f *File) Users() ([]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.refresh(); err != nil {
+		return nil, err
+	}
+
+	var users []string
+	for _, l := range f.lines {
+		if l.user != "" {
+			users = append(users, l.user)
+		}
+	}
+	return users, nil
+}
+
+// Verify returns true if plaintext is the password for user.
+// An unknown user produces false and a nil error, after as long as a known user would take;
+// see mcf.Dummy.
+// If the password is valid, the entry is stale, and Upgrade is set,
+// the entry is replaced by a new mcf encoding and the file is rewritten.
+// A failure to do so does not change the result; see UpgradeError.
+// The File is not locked while the password is verified, so logins do not wait for each other.
+func (f *File) Verify(user, plaintext string) (isValid bool, err error) {
+	hash, ok, err := f.lookup(user)
+	if err != nil {
+		return
+	}
+
+	if !ok {
+		f.once.Do(func() { f.dummy, f.dummyErr = mcf.Dummy() })
+		if f.dummyErr != nil {
+			return false, f.dummyErr
+		}
+		// Spend the time anyway, but fail regardless.
+		mcf.Verify(plaintext, f.dummy)
+		return false, nil
+	}
+
+	isValid, err = Verify(plaintext, hash)
+	if err != nil || !isValid || !f.Upgrade {
+		return
+	}
+
+	if err := f.upgrade(user, hash, plaintext); err != nil && f.UpgradeError != nil {
+		f.UpgradeError(user, err)
+	}
+	return true, nil
+}
+
+// lookup returns the hash of user, and whether there is one.
+func (f *File) lookup(user string) (hash string, ok bool, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err = f.refresh(); err != nil {
+		return
+	}
+	if i := f.find(user); i >= 0 {
+		return f.lines[i].hash, true, nil
+	}
+	return
+}
+
+// upgrade replaces the entry of user with a new mcf encoding of plaintext if hash, which was verified, is stale.
+// The entry is left alone if it no longer has that hash, since it was changed in the meantime.
+func (f *File) upgrade(user, hash, plaintext string) error {
+	isCurrent, err := IsCurrent(hash)
+	if err != nil || isCurrent {
+		return err
+	}
+
+	newHash, err := mcf.Create(plaintext)
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.refresh(); err != nil {
+		return err
+	}
+	i := f.find(user)
+	if i < 0 || f.lines[i].hash != hash {
+		return nil
+	}
+	return f.replace(i, line{user: user, hash: newHash})
+}
+
+// Set adds user with the password plaintext, or changes the password of an existing user,
+// and rewrites the file.
+func (f *File) Set(user, plaintext string) error {
+	if user == "" || strings.ContainsAny(user, ":\r\n") || user[0] == '#' {
+		return ErrInvalidUser
+	}
+
+	hash, err := mcf.Create(plaintext)
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.refresh(); err != nil {
+		return err
+	}
+
+	return f.replace(f.find(user), line{user: user, hash: hash})
+}
+
+// Remove deletes user and rewrites the file.
+func (f *File) Remove(user string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.refresh(); err != nil {
+		return err
+	}
+
+	i := f.find(user)
+	if i < 0 {
+		return ErrNoUser
+	}
+
+	lines := append(append([]line(nil), f.lines[:i]...), f.lines[i+1:]...)
+	if err := f.save(lines); err != nil {
+		return err
+	}
+	f.lines = lines
+	return nil
+}
+
+// replace saves the file with l in place of line i, or appended if i is negative.
+// The lines are only changed in memory if the file is saved.
+func (f *File) replace(i int, l line) error {
+	lines := append([]line(nil), f.lines...)
+	if i < 0 {
+		lines = append(lines, l)
+	} else {
+		lines[i] = l
+	}
+
+	if err := f.save(lines); err != nil {
+		return err
+	}
+	f.lines = lines
+	return nil
+}
+
+// save writes lines to a temporary file in the same directory and renames it into place,
+// so readers never see a partially written file. The original permissions are retained.
+func (f *File) save(lines []line) error {
+	var buf bytes.Buffer
+	for _, l := range lines {
+		buf.WriteString(l.String())
+		buf.WriteByte('\n')
+	}
+
+	mode := os.FileMode(0640)
+	if fi, err := os.Stat(f.path); err == nil {
+		mode = fi.Mode().Perm()
+	}
+
+	dir, base := filepath.Split(f.path)
+	if dir == "" {
+		dir = "."
+	}
+
+	tmp, err := os.CreateTemp(dir, "."+base+".tmp")
+	if err != nil {
+		return err
+	}
+
+	err = writeSync(tmp, buf.Bytes(), mode)
+	if err == nil {
+		err = os.Rename(tmp.Name(), f.path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	fi, err := os.Stat(f.path)
+	if err != nil {
+		return err
+	}
+	f.modTime, f.size = fi.ModTime(), fi.Size()
+	return nil
+}
+
+func writeSync(fh *os.File, b []byte, mode os.FileMode) error {
+	_, err := fh.Write(b)
+	if err == nil {
+		err = fh.Chmod(mode)
+	}
+	if err == nil {
+		err = fh.Sync()
+	}
+	if cerr := fh.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,311 @@
+package htpasswd
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gyepisam/mcf"
+	_ "github.com/gyepisam/mcf/bcrypt"
+	_ "github.com/gyepisam/mcf/pbkdf2"
+)
+
+// Reference values were produced by crypt(3) and openssl passwd -apr1.
+var testVectors = []struct {
+	plain string
+	hash  string
+}{
+	{"abc", "$2y$06$If6bvum7DFjUnE9p2uDeDu0YHzrHM6tf.iqN8.yx.jNN1ILEf7h0i"},
+	{"abc", "$2b$06$If6bvum7DFjUnE9p2uDeDu0YHzrHM6tf.iqN8.yx.jNN1ILEf7h0i"},
+	{"abc", "$2a$06$If6bvum7DFjUnE9p2uDeDu0YHzrHM6tf.iqN8.yx.jNN1ILEf7h0i"},
+	{"password", "$apr1$Xy7/.9ab$HPqdtmrqAwhY67sUIvxSW."},
+	{"", "$apr1$Xy7/.9ab$FwKxoh5h4Qg9En86YNm00/"},
+	{"hello world, this is a long password", "$apr1$Xy7/.9ab$FYBED1WdYGz9RNrQCR42U/"},
+	{"secret", "$apr1$short$bDQT7XGfPJVWXqOzdjpBq."},
+	{"password", "{SHA}W6ph5Mm5Pz8GgiULbPgzG37mj9g="},
+	{"test", "abgOeLfPimXQo"},
+	{"password", "Xy2PtsPf0839Y"},
+	{"", "./Una9Fi.seRo"},
+	{"longerthan8chars", "zz6XwEAsbAWcE"},
+	{"longerth", "zz6XwEAsbAWcE"}, // only 8 characters are significant.
+	{"pa55w0rd", "9.WT9LU0OCelE"},
+}
+
+func TestVerify(t *testing.T) {
+	for i, v := range testVectors {
+		isValid, err := Verify(v.plain, v.hash)
+		if err != nil {
+			t.Errorf("%d: %s: unexpected error: %s", i, v.hash, err)
+			continue
+		}
+		if !isValid {
+			t.Errorf("%d: %s: expected true, got false", i, v.hash)
+		}
+
+		isValid, err = Verify(v.plain+"x", v.hash)
+		if err != nil {
+			t.Errorf("%d: %s: unexpected error: %s", i, v.hash, err)
+			continue
+		}
+		// crypt ignores characters beyond the eighth.
+		if isValid && !(isDESCrypt(v.hash) && len(v.plain) >= 8) {
+			t.Errorf("%d: %s: expected false for wrong password, got true", i, v.hash)
+		}
+	}
+
+	for i, hash := range []string{"", "plaintext", "{SSHA}abc", "$nosuch$x$y$z"} {
+		if _, err := Verify("password", hash); err == nil {
+			t.Errorf("%d: %q: expected error, got nil", i, hash)
+		}
+	}
+}
+
+func TestIsCurrent(t *testing.T) {
+	for i, v := range testVectors {
+		isCurrent, err := IsCurrent(v.hash)
+		if err != nil {
+			t.Errorf("%d: unexpected error: %s", i, err)
+		}
+		if isCurrent {
+			t.Errorf("%d: %s: expected stale entry", i, v.hash)
+		}
+	}
+}
+
+func tempFile(t *testing.T) string {
+	b, err := os.ReadFile("testdata/htpasswd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "htpasswd")
+	if err := os.WriteFile(path, b, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func users(t *testing.T, f *File) string {
+	list, err := f.Users()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Join(list, ",")
+}
+
+func TestFile(t *testing.T) {
+	if err := mcf.SetDefault(mcf.PBKDF2); err != nil {
+		t.Fatal(err)
+	}
+
+	path := tempFile(t)
+	f, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want, got := "alice,bob,carol,dave", users(t, f); want != got {
+		t.Errorf("Users: expected %s, got %s", want, got)
+	}
+
+	for _, v := range []struct {
+		user, plain string
+		valid       bool
+	}{
+		{"alice", "abc", true},
+		{"bob", "password", true},
+		{"carol", "password", true},
+		{"dave", "password", true},
+		{"dave", "wrong", false},
+		{"nobody", "password", false},
+	} {
+		isValid, err := f.Verify(v.user, v.plain)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", v.user, err)
+		}
+		if isValid != v.valid {
+			t.Errorf("%s: expected %t, got %t", v.user, v.valid, isValid)
+		}
+	}
+
+	if err := f.Set("erin", "s3cr3t"); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Set("bob", "n3w"); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Remove("carol"); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Remove("carol"); err != ErrNoUser {
+		t.Errorf("Remove: expected ErrNoUser, got %v", err)
+	}
+
+	for _, user := range []string{"", "a:b", "#x", "a\nb"} {
+		if err := f.Set(user, "x"); err != ErrInvalidUser {
+			t.Errorf("Set(%q): expected ErrInvalidUser, got %v", user, err)
+		}
+	}
+
+	// A fresh reader sees the changes.
+	g, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, got := "alice,bob,dave,erin", users(t, g); want != got {
+		t.Errorf("Users: expected %s, got %s", want, got)
+	}
+	for user, plain := range map[string]string{"bob": "n3w", "erin": "s3cr3t", "dave": "password"} {
+		if isValid, err := g.Verify(user, plain); err != nil || !isValid {
+			t.Errorf("%s: expected true, nil; got %t, %v", user, isValid, err)
+		}
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(b), "# Entries of each supported type.\n") {
+		t.Errorf("comment was not preserved:\n%s", b)
+	}
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := fi.Mode().Perm(); mode != 0600 {
+		t.Errorf("expected mode 0600, got %o", mode)
+	}
+}
+
+func TestUpgrade(t *testing.T) {
+	if err := mcf.SetDefault(mcf.PBKDF2); err != nil {
+		t.Fatal(err)
+	}
+
+	path := tempFile(t)
+	f, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Upgrade = true
+
+	for user, plain := range map[string]string{"alice": "abc", "bob": "password", "carol": "password", "dave": "password"} {
+		if isValid, err := f.Verify(user, plain); err != nil || !isValid {
+			t.Errorf("%s: expected true, nil; got %t, %v", user, isValid, err)
+		}
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(b), ":$pbkdf2$"); n != 4 {
+		t.Errorf("expected 4 upgraded entries, got %d:\n%s", n, b)
+	}
+
+	for user, plain := range map[string]string{"alice": "abc", "bob": "password", "carol": "password", "dave": "password"} {
+		if isValid, err := f.Verify(user, plain); err != nil || !isValid {
+			t.Errorf("%s: after upgrade: expected true, nil; got %t, %v", user, isValid, err)
+		}
+	}
+}
+
+// A valid password is valid even if its entry cannot be upgraded.
+func TestUpgradeError(t *testing.T) {
+	if err := mcf.SetDefault(mcf.PBKDF2); err != nil {
+		t.Fatal(err)
+	}
+	refused := errors.New("refused")
+	defer mcf.AddPreCreateHook(func(plaintext []byte) error { return refused })()
+
+	f, err := Open(tempFile(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var upgradeErr error
+	f.Upgrade = true
+	f.UpgradeError = func(user string, err error) { upgradeErr = err }
+
+	if isValid, err := f.Verify("bob", "password"); err != nil || !isValid {
+		t.Errorf("expected true, nil; got %t, %v", isValid, err)
+	}
+	if upgradeErr != refused {
+		t.Errorf("UpgradeError: expected %v, got %v", refused, upgradeErr)
+	}
+}
+
+// An entry that changes while its old password is verified is not upgraded.
+func TestUpgradeChanged(t *testing.T) {
+	if err := mcf.SetDefault(mcf.PBKDF2); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := Open(tempFile(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	old, _, err := f.lookup("bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Set("bob", "changed"); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.upgrade("bob", old, "password"); err != nil {
+		t.Fatal(err)
+	}
+
+	if isValid, err := f.Verify("bob", "changed"); err != nil || !isValid {
+		t.Errorf("new password: expected true, nil; got %t, %v", isValid, err)
+	}
+	if isValid, err := f.Verify("bob", "password"); err != nil || isValid {
+		t.Errorf("old password: expected false, nil; got %t, %v", isValid, err)
+	}
+}
+
+func TestReload(t *testing.T) {
+	path := tempFile(t)
+	f, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = os.WriteFile(path, []byte("zed:{SHA}W6ph5Mm5Pz8GgiULbPgzG37mj9g=\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want, got := "zed", users(t, f); want != got {
+		t.Errorf("Users: expected %s, got %s", want, got)
+	}
+
+	if isValid, err := f.Verify("zed", "password"); err != nil || !isValid {
+		t.Errorf("expected true, nil; got %t, %v", isValid, err)
+	}
+}
+
+// A change that cannot be saved is not made in memory either.
+func TestFailedSave(t *testing.T) {
+	f, err := Open(tempFile(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, i := len(f.lines), f.find("bob")
+	hash := f.lines[i].hash
+
+	f.path = filepath.Join(t.TempDir(), "missing", "htpasswd")
+	for _, j := range []int{i, -1} {
+		if err := f.replace(j, line{user: "bob", hash: "{SHA}x"}); err == nil {
+			t.Errorf("replace %d: expected an error", j)
+		}
+	}
+
+	if len(f.lines) != n {
+		t.Errorf("expected %d lines, got %d", n, len(f.lines))
+	}
+	if got := f.lines[i].hash; got != hash {
+		t.Errorf("hash: expected %s, got %s", hash, got)
+	}
+}
@@ -0,0 +1,95 @@
+// Copyright 2014 Gyepi Sam. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package htpasswd
+
+import (
+	"crypto/md5"
+	"crypto/subtle"
+	"strings"
+
+	"github.com/gyepisam/mcf/internal/crypt64"
+)
+
+// Apache's variant of the FreeBSD MD5 crypt algorithm.
+const apr1Magic = "$apr1$"
+
+// md5Crypt computes the MD5 crypt of password using magic and the salt in setting,
+// which may be a complete hash or just magic followed by salt.
+func md5Crypt(password []byte, magic, setting string) string {
+	salt := strings.TrimPrefix(setting, magic)
+	if i := strings.IndexByte(salt, '$'); i >= 0 {
+		salt = salt[:i]
+	}
+	if len(salt) > 8 {
+		salt = salt[:8]
+	}
+
+	h := md5.New()
+	h.Write(password)
+	h.Write([]byte(salt))
+	h.Write(password)
+	final := h.Sum(nil)
+
+	h.Reset()
+	h.Write(password)
+	h.Write([]byte(magic))
+	h.Write([]byte(salt))
+	for n := len(password); n > 0; n -= md5.Size {
+		if n > md5.Size {
+			h.Write(final)
+		} else {
+			h.Write(final[:n])
+		}
+	}
+	for i := len(password); i != 0; i >>= 1 {
+		if i&1 != 0 {
+			h.Write([]byte{0})
+		} else {
+			h.Write(password[:1])
+		}
+	}
+	final = h.Sum(nil)
+
+	// Slow things down.
+	for i := 0; i < 1000; i++ {
+		h.Reset()
+		if i&1 != 0 {
+			h.Write(password)
+		} else {
+			h.Write(final)
+		}
+		if i%3 != 0 {
+			h.Write([]byte(salt))
+		}
+		if i%7 != 0 {
+			h.Write(password)
+		}
+		if i&1 != 0 {
+			h.Write(final)
+		} else {
+			h.Write(password)
+		}
+		final = h.Sum(final[:0])
+	}
+
+	out := make([]byte, 0, len(magic)+len(salt)+1+22)
+	out = append(out, magic...)
+	out = append(out, salt...)
+	out = append(out, '$')
+
+	f := final
+	out = crypt64.AppendUint32(out, uint32(f[0])<<16|uint32(f[6])<<8|uint32(f[12]), 4)
+	out = crypt64.AppendUint32(out, uint32(f[1])<<16|uint32(f[7])<<8|uint32(f[13]), 4)
+	out = crypt64.AppendUint32(out, uint32(f[2])<<16|uint32(f[8])<<8|uint32(f[14]), 4)
+	out = crypt64.AppendUint32(out, uint32(f[3])<<16|uint32(f[9])<<8|uint32(f[15]), 4)
+	out = crypt64.AppendUint32(out, uint32(f[4])<<16|uint32(f[10])<<8|uint32(f[5]), 4)
+	out = crypt64.AppendUint32(out, uint32(f[11]), 2)
+
+	return string(out)
+}
+
+func verifyAPR1(plaintext []byte, hash string) bool {
+	return subtle.ConstantTimeCompare([]byte(md5Crypt(plaintext, apr1Magic, hash)), []byte(hash)) == 1
+}
@@ -0,0 +1,78 @@
+// Copyright 2014 Gyepi Sam. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package crypt64 implements the base64 encoding of crypt(3), which, unlike RFC 4648,
+// uses the alphabet ./0-9A-Za-z and encodes bytes in little endian groups of 24 bits.
+package crypt64
+
+// Alphabet is the crypt(3) base64 alphabet. The value of a character is its index.
+const Alphabet = "./0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+var values [256]int8
+
+func init() {
+	for i := range values {
+		values[i] = -1
+	}
+	for i := 0; i < len(Alphabet); i++ {
+		values[Alphabet[i]] = int8(i)
+	}
+}
+
+// Index returns the value of the character c, or -1 if c is not in Alphabet.
+func Index(c byte) int {
+	return int(values[c])
+}
+
+// AppendUint32 appends n characters encoding the low order bits of v, least significant first.
+func AppendUint32(dst []byte, v uint32, n int) []byte {
+	for ; n > 0; n-- {
+		dst = append(dst, Alphabet[v&0x3f])
+		v >>= 6
+	}
+	return dst
+}
+
+// Encode encodes src in little endian groups of 24 bits.
+func Encode(src []byte) []byte {
+	dst := make([]byte, 0, (len(src)*8+5)/6)
+	for i := 0; i < len(src); {
+		value, bits := uint32(0), 0
+		for bits < 24 && i < len(src) {
+			value |= uint32(src[i]) << bits
+			bits += 8
+			i++
+		}
+		dst = AppendUint32(dst, value, (bits+5)/6)
+	}
+	return dst
+}
+
+// Decode reverses Encode. It returns false if src is not a valid encoding.
+func Decode(src []byte) ([]byte, bool) {
+	dst := make([]byte, 0, len(src)*6/8)
+	for len(src) > 0 {
+		value, bits := uint32(0), 0
+		for len(src) > 0 && bits < 24 {
+			c := Index(src[0])
+			if c < 0 {
+				return nil, false
+			}
+			src = src[1:]
+			value |= uint32(c) << bits
+			bits += 6
+		}
+		if bits < 12 { // must have at least one full byte
+			return nil, false
+		}
+		for ; bits >= 8; bits -= 8 {
+			dst = append(dst, byte(value))
+			value >>= 8
+		}
+		if value != 0 { // the remaining 2 or 4 bits must be 0
+			return nil, false
+		}
+	}
+	return dst, true
+}
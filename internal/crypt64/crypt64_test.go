@@ -0,0 +1,54 @@
+// Copyright 2014 Gyepi Sam. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package crypt64
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestEncode(t *testing.T) {
+	for _, tt := range []struct {
+		src     string
+		encoded string
+	}{
+		{"", ""},
+		{"\x00", ".."},
+		{"\xff", "z1"},
+		{"\x00\x00\x00", "...."},
+		{"\xff\xff\xff", "zzzz"},
+		{"saltsalt", "n34PoBLMgF5"},
+	} {
+		encoded := Encode([]byte(tt.src))
+		if string(encoded) != tt.encoded {
+			t.Errorf("Encode %q: got %q, expected %q", tt.src, encoded, tt.encoded)
+		}
+		decoded, ok := Decode(encoded)
+		if !ok || !bytes.Equal(decoded, []byte(tt.src)) {
+			t.Errorf("Decode %q: got (%q, %t), expected (%q, true)", encoded, decoded, ok, tt.src)
+		}
+	}
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	for _, s := range []string{".", "z2", "....!", "$$"} {
+		if b, ok := Decode([]byte(s)); ok {
+			t.Errorf("Decode %q: got (%q, true), expected failure", s, b)
+		}
+	}
+}
+
+func TestIndex(t *testing.T) {
+	for i := 0; i < len(Alphabet); i++ {
+		if got := Index(Alphabet[i]); got != i {
+			t.Errorf("Index %q: got %d, expected %d", Alphabet[i], got, i)
+		}
+	}
+	for _, c := range []byte{'$', '+', '=', 0, 0xff} {
+		if got := Index(c); got != -1 {
+			t.Errorf("Index %q: got %d, expected -1", c, got)
+		}
+	}
+}
@@ -0,0 +1,325 @@
+// Copyright 2014 Gyepi Sam. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Package ldap verifies and creates LDAP userPassword values, which have the RFC 2307 form {SCHEME}value.
+The schemes are
+
+	{SHA}, {SHA256}, {SHA384}, {SHA512}, {MD5}          base64 encoded, unsalted digests
+	{SSHA}, {SSHA256}, {SSHA384}, {SSHA512}, {SMD5}     base64 encoded digests, followed by the salt
+	{PBKDF2}, {PBKDF2-SHA1}, {PBKDF2-SHA256},           iterations$salt$key, as used by OpenLDAP's
+	{PBKDF2-SHA512}                                     pw-pbkdf2 module and passlib
+	{ARGON2}                                            $argon2i$ or $argon2id$ PHC strings, as used by
+	                                                    OpenLDAP's pw-argon2 module
+	{CRYPT}                                             anything mcf understands
+
+The digests, PBKDF2 and Argon2 are verified by this package. {CRYPT} values are verified by the
+registered mcf encoders, so the appropriate encoders must be imported.
+PBKDF2 keys are computed by the pbkdf2 package, whose limits apply, so importing this package also
+registers the pbkdf2 encoder.
+Scheme names are not case sensitive.
+
+New values are created with mcf.Create and have the form {CRYPT}$...; see Create and Crypt.
+Only {CRYPT} values can be current.
+
+Importing the package also registers an encoder that only verifies, and a detector for values with
+a supported scheme, so that mcf.Verify and mcf.IsCurrent recognize them along with other passwords.
+*/
+package ldap
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"strconv"
+	"strings"
+
+	"github.com/gyepisam/mcf"
+	"github.com/gyepisam/mcf/encoder"
+	"github.com/gyepisam/mcf/pbkdf2"
+	"golang.org/x/crypto/argon2"
+)
+
+// CryptScheme is the scheme of values that hold Modular Crypt Format passwords.
+const CryptScheme = "CRYPT"
+
+// ErrCreateDisabled is returned by the Create method of the registered encoder, which only verifies.
+// Use Create to create values.
+var ErrCreateDisabled = fmt.Errorf("%w: ldap: use ldap.Create", encoder.ErrCreateDisabled)
+
+// Limits on the parameters of Argon2 values, which guard against the exhaustion of memory or time
+// by a corrupt or malicious value. They may be raised if necessary.
+// PBKDF2 values are subject to the limits of the pbkdf2 package.
+var (
+	MaxPasses  uint32 = 1 << 24 // Maximum Argon2 passes.
+	MaxMemory  uint32 = 1 << 20 // Maximum Argon2 memory in KiB.
+	MaxThreads uint8  = 64      // Maximum Argon2 parallelism.
+	MaxWork    int64  = 1 << 22 // Maximum value of memory * passes * parallelism, which bounds running time.
+	MaxKeyLen         = 1024    // Maximum Argon2 key length in bytes.
+)
+
+// A digest is a scheme that stores a digest of the password, possibly salted.
+type digest struct {
+	hash   func() hash.Hash
+	salted bool
+}
+
+var digests = map[string]digest{
+	"MD5":     {md5.New, false},
+	"SMD5":    {md5.New, true},
+	"SHA":     {sha1.New, false},
+	"SSHA":    {sha1.New, true},
+	"SHA256":  {sha256.New, false},
+	"SSHA256": {sha256.New, true},
+	"SHA384":  {sha512.New384, false},
+	"SSHA384": {sha512.New384, true},
+	"SHA512":  {sha512.New, false},
+	"SSHA512": {sha512.New, true},
+}
+
+var pbkdf2Hashes = map[string]pbkdf2.Hash{
+	"PBKDF2":        pbkdf2.SHA1,
+	"PBKDF2-SHA1":   pbkdf2.SHA1,
+	"PBKDF2-SHA256": pbkdf2.SHA256,
+	"PBKDF2-SHA512": pbkdf2.SHA512,
+}
+
+// Scheme splits an LDAP userPassword value into its scheme, in upper case, and the rest.
+// It returns false if the value does not begin with a scheme.
+func Scheme(value string) (scheme, rest string, ok bool) {
+	if !strings.HasPrefix(value, "{") {
+		return "", "", false
+	}
+	i := strings.IndexByte(value, '}')
+	if i < 2 {
+		return "", "", false
+	}
+	return strings.ToUpper(value[1:i]), value[i+1:], true
+}
+
+func malformed(scheme, format string, args ...interface{}) error {
+	return fmt.Errorf("%w: ldap: {%s}: "+format, append([]interface{}{encoder.ErrMalformedHash, scheme}, args...)...)
+}
+
+func invalid(scheme, format string, args ...interface{}) error {
+	return fmt.Errorf("%w: ldap: {%s}: "+format, append([]interface{}{encoder.ErrInvalidParams, scheme}, args...)...)
+}
+
+// Verify returns true if plaintext matches value, which may have any of the supported schemes.
+// An unsupported scheme, or a value without one, produces an error that wraps mcf.ErrUnknownScheme.
+func Verify(plaintext, value string) (isValid bool, err error) {
+	scheme, rest, ok := Scheme(value)
+	if !ok {
+		return false, fmt.Errorf("ldap: missing scheme: %w", mcf.ErrUnknownScheme)
+	}
+
+	if scheme == CryptScheme {
+		return mcf.Verify(plaintext, rest)
+	}
+
+	if d, ok := digests[scheme]; ok {
+		return d.verify(scheme, []byte(plaintext), rest)
+	}
+
+	if h, ok := pbkdf2Hashes[scheme]; ok {
+		return verifyPBKDF2(scheme, h, []byte(plaintext), rest)
+	}
+
+	if scheme == "ARGON2" {
+		return verifyArgon2(scheme, []byte(plaintext), rest)
+	}
+
+	return false, fmt.Errorf("ldap: {%s}: %w", scheme, mcf.ErrUnknownScheme)
+}
+
+// IsCurrent returns true if value is a {CRYPT} value that mcf considers current.
+// Values with any other supported scheme are never current.
+func IsCurrent(value string) (isCurrent bool, err error) {
+	scheme, rest, ok := Scheme(value)
+	if !ok {
+		return false, fmt.Errorf("ldap: missing scheme: %w", mcf.ErrUnknownScheme)
+	}
+
+	if scheme == CryptScheme {
+		return mcf.IsCurrent(rest)
+	}
+
+	if !supported(scheme) {
+		return false, fmt.Errorf("ldap: {%s}: %w", scheme, mcf.ErrUnknownScheme)
+	}
+	return false, nil
+}
+
+// supported returns true if the scheme, in upper case, is one of those of the package.
+func supported(scheme string) bool {
+	_, isDigest := digests[scheme]
+	_, isPBKDF2 := pbkdf2Hashes[scheme]
+	return isDigest || isPBKDF2 || scheme == "ARGON2" || scheme == CryptScheme
+}
+
+// Create encodes plaintext with mcf.Create and returns it as a {CRYPT} value.
+func Create(plaintext string) (value string, err error) {
+	encoded, err := mcf.Create(plaintext)
+	if err != nil {
+		return "", err
+	}
+	return Crypt(encoded), nil
+}
+
+// Crypt returns an encoded password, such as one produced by mcf.Create, as a {CRYPT} value.
+func Crypt(encoded string) string {
+	return "{" + CryptScheme + "}" + encoded
+}
+
+type ldap struct{}
+
+func init() {
+	if err := mcf.RegisterVerifier(mcf.LDAP, ldap{}); err != nil {
+		panic(err)
+	}
+	if err := mcf.RegisterDetectorPrecedence(mcf.PrecedencePrefix, detect); err != nil {
+		panic(err)
+	}
+}
+
+// detect recognizes values with a supported scheme for mcf.
+func detect(encoded []byte) (encoder.Encoder, bool) {
+	scheme, _, ok := Scheme(string(encoded))
+	if !ok || !supported(scheme) {
+		return nil, false
+	}
+	return mcf.Registered(mcf.LDAP), true
+}
+
+// Id returns "ldap", the name of the encoding. Values have no id and are recognized by their scheme.
+func (ldap) Id() []byte {
+	return []byte("ldap")
+}
+
+// Create returns ErrCreateDisabled.
+func (ldap) Create(plaintext []byte) (encoded []byte, err error) {
+	return nil, ErrCreateDisabled
+}
+
+// Verify returns true if plaintext matches the value. See Verify.
+func (ldap) Verify(plaintext, encoded []byte) (isValid bool, err error) {
+	return Verify(string(plaintext), string(encoded))
+}
+
+// IsCurrent returns true if the value is a current {CRYPT} value. See IsCurrent.
+func (ldap) IsCurrent(encoded []byte) (isCurrent bool, err error) {
+	return IsCurrent(string(encoded))
+}
+
+// ParseParams implements encoder.ParamsParser. The only parameter is "scheme", in upper case.
+func (ldap) ParseParams(encoded []byte) (map[string]string, error) {
+	scheme, _, ok := Scheme(string(encoded))
+	if !ok || !supported(scheme) {
+		return nil, fmt.Errorf("ldap: %w", mcf.ErrUnknownScheme)
+	}
+	return map[string]string{"scheme": scheme}, nil
+}
+
+func (d digest) verify(scheme string, plaintext []byte, rest string) (bool, error) {
+	b, err := base64.StdEncoding.DecodeString(rest)
+	if err != nil {
+		return false, malformed(scheme, "%s", err)
+	}
+
+	h := d.hash()
+	size := h.Size()
+	if len(b) < size || !d.salted && len(b) != size {
+		return false, malformed(scheme, "wrong length")
+	}
+
+	h.Write(plaintext)
+	h.Write(b[size:])
+	return subtle.ConstantTimeCompare(h.Sum(nil), b[:size]) == 1, nil
+}
+
+// ab64 is the base64 alphabet of OpenLDAP's pw-pbkdf2 module, which uses '.' in place of '+'.
+var ab64 = base64.NewEncoding("ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789./").WithPadding(base64.NoPadding)
+
+func verifyPBKDF2(scheme string, h pbkdf2.Hash, plaintext []byte, rest string) (bool, error) {
+	fields := strings.Split(rest, "$")
+	if len(fields) != 3 {
+		return false, malformed(scheme, "expected iterations$salt$key")
+	}
+
+	iter, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return false, malformed(scheme, "invalid iterations %q", fields[0])
+	}
+	if iter < 1 || iter > pbkdf2.MaxIterations {
+		return false, invalid(scheme, "iterations %d is not between 1 and pbkdf2.MaxIterations %d", iter, pbkdf2.MaxIterations)
+	}
+
+	salt, err := ab64.DecodeString(strings.TrimRight(fields[1], "="))
+	if err != nil {
+		return false, malformed(scheme, "invalid salt: %s", err)
+	}
+	key, err := ab64.DecodeString(strings.TrimRight(fields[2], "="))
+	if err != nil || len(key) == 0 || len(key) > pbkdf2.MaxKeyLen {
+		return false, malformed(scheme, "invalid key")
+	}
+
+	c := pbkdf2.Config{Hash: h, Iterations: iter, KeyLen: len(key)}
+	testKey, err := c.Key(plaintext, salt)
+	if err != nil {
+		return false, err
+	}
+	return subtle.ConstantTimeCompare(key, testKey) == 1, nil
+}
+
+func verifyArgon2(scheme string, plaintext []byte, rest string) (bool, error) {
+	// $argon2id$v=19$m=65536,t=2,p=1$salt$key
+	fields := strings.Split(rest, "$")
+	if len(fields) != 6 || fields[0] != "" {
+		return false, malformed(scheme, "expected $type$v=version$m=memory,t=passes,p=threads$salt$key")
+	}
+
+	var kdf func(password, salt []byte, time, memory uint32, threads uint8, keyLen uint32) []byte
+	switch fields[1] {
+	case "argon2i":
+		kdf = argon2.Key
+	case "argon2id":
+		kdf = argon2.IDKey
+	default:
+		return false, fmt.Errorf("%w: ldap: {%s}: type %q", encoder.ErrUnsupportedVersion, scheme, fields[1])
+	}
+
+	if fields[2] != "v=19" {
+		return false, fmt.Errorf("%w: ldap: {%s}: version %q", encoder.ErrUnsupportedVersion, scheme, fields[2])
+	}
+
+	var m, t uint32
+	var p uint8
+	if _, err := fmt.Sscanf(fields[3], "m=%d,t=%d,p=%d", &m, &t, &p); err != nil ||
+		fmt.Sprintf("m=%d,t=%d,p=%d", m, t, p) != fields[3] {
+		return false, malformed(scheme, "invalid parameters %q", fields[3])
+	}
+	if t < 1 || t > MaxPasses || p < 1 || p > MaxThreads || m < 8*uint32(p) || m > MaxMemory {
+		return false, invalid(scheme, "parameters %q exceed limits", fields[3])
+	}
+	if work := int64(m) * int64(t) * int64(p); work > MaxWork {
+		return false, invalid(scheme, "work %d exceeds MaxWork %d", work, MaxWork)
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(fields[4])
+	if err != nil {
+		return false, malformed(scheme, "invalid salt: %s", err)
+	}
+	key, err := base64.RawStdEncoding.DecodeString(fields[5])
+	if err != nil || len(key) == 0 || len(key) > MaxKeyLen {
+		return false, malformed(scheme, "invalid key")
+	}
+
+	testKey := kdf(plaintext, salt, t, m, p, uint32(len(key)))
+	return subtle.ConstantTimeCompare(key, testKey) == 1, nil
+}
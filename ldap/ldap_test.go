@@ -0,0 +1,168 @@
+// Copyright 2014 Gyepi Sam. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ldap
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/gyepisam/mcf"
+	_ "github.com/gyepisam/mcf/pbkdf2"
+)
+
+// The Argon2 values are from the test suite of the reference implementation, for "password";
+// the others, for "secret", were produced with Python's hashlib.
+var testVectors = []struct {
+	plaintext string
+	value     string
+}{
+	{"secret", "{SHA}5en6G6MezRroT3XKqkdPOmY/BfQ="},
+	{"secret", "{sha}5en6G6MezRroT3XKqkdPOmY/BfQ="},
+	{"secret", "{SSHA}lHFzXul4wnzRItssVcTnvXWRjNgBAgMEBQYHCA=="},
+	{"secret", "{SSHA256}A7N1lAy5bBb4T6qH9e85zAvHBmzNPhRFbZ105DjjWDIBAgMEBQYHCA=="},
+	{"secret", "{SSHA512}KO8EsMPQTwZrxxbOkDAOOXEeVCc2grMQg1pnZwZhC1bBQLby8zCmFn7qTZRvoTd+yQdROQQNYHWpTUST4zjTdQECAwQFBgcI"},
+	{"secret", "{SMD5}yeWhvSFtvhMX4jDO9I847gECAwQFBgcI"},
+	{"secret", "{PBKDF2}1000$c2FsdHNhbHRzYWx0c2FsdA$Gl3hdYiKRqzegI7vLGvFG.A89N0"},
+	{"secret", "{PBKDF2-SHA256}1000$c2FsdHNhbHRzYWx0c2FsdA$dClvKSmj66n6MdMWNv3Go4mvH1Ym2WIGiJvquqa.mfE"},
+	{"secret", "{PBKDF2-SHA512}1000$c2FsdHNhbHRzYWx0c2FsdA$2IVLzDcUS76iY9.wBooZzkS5Z0mI6ikZPfMieFfR1eV/GJiiRzyvvz4ne7tBn8OJ1hxZyCz95QPNeLP3ykzMiQ"},
+	{"password", "{ARGON2}$argon2i$v=19$m=65536,t=2,p=1$c29tZXNhbHQ$wWKIMhR9lyDFvRz9YTZweHKfbftvj+qf+YFY4NeBbtA"},
+	{"password", "{ARGON2}$argon2id$v=19$m=65536,t=2,p=1$c29tZXNhbHQ$CTFhFdXPJO1aFaMaO6Mm5c8y7cJHAph8ArZWb2GRPPc"},
+	{"password", "{CRYPT}$pbkdf2$keylen=20,iterations=1,hmac=SHA1$c2FsdA==$DGDID5YfDnHzqbUkr2ASBi/gN6Y="},
+}
+
+func TestVerify(t *testing.T) {
+	for i, v := range testVectors {
+		isValid, err := Verify(v.plaintext, v.value)
+		if err != nil || !isValid {
+			t.Errorf("%d: Verify %s: got (%t, %v), expected (true, nil)", i, v.value, isValid, err)
+		}
+
+		isValid, err = Verify(v.plaintext+"x", v.value)
+		if err != nil || isValid {
+			t.Errorf("%d: Verify wrong password %s: got (%t, %v), expected (false, nil)", i, v.value, isValid, err)
+		}
+
+		if !strings.HasPrefix(v.value, "{CRYPT}") {
+			if isCurrent, err := IsCurrent(v.value); err != nil || isCurrent {
+				t.Errorf("%d: IsCurrent %s: got (%t, %v), expected (false, nil)", i, v.value, isCurrent, err)
+			}
+		}
+	}
+}
+
+func TestCreate(t *testing.T) {
+	value, err := Create("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(value, "{CRYPT}$pbkdf2$") {
+		t.Errorf("Create: got %s, expected a {CRYPT}$pbkdf2$ value", value)
+	}
+	if isValid, err := Verify("secret", value); err != nil || !isValid {
+		t.Errorf("Verify %s: got (%t, %v), expected (true, nil)", value, isValid, err)
+	}
+	if isCurrent, err := IsCurrent(value); err != nil || !isCurrent {
+		t.Errorf("IsCurrent %s: got (%t, %v), expected (true, nil)", value, isCurrent, err)
+	}
+	if isCurrent, err := mcf.IsCurrent(value); err != nil || !isCurrent {
+		t.Errorf("mcf.IsCurrent %s: got (%t, %v), expected (true, nil)", value, isCurrent, err)
+	}
+
+	encoded, _ := mcf.Create("secret")
+	if got := Crypt(encoded); got != "{CRYPT}"+encoded {
+		t.Errorf("Crypt: got %s", got)
+	}
+}
+
+func TestMCF(t *testing.T) {
+	if mcf.Default() == mcf.LDAP {
+		t.Errorf("ldap is the default encoding")
+	}
+	if _, err := mcf.Registered(mcf.LDAP).Create([]byte("secret")); !errors.Is(err, mcf.ErrCreateDisabled) {
+		t.Errorf("Create: got %v, expected %v", err, mcf.ErrCreateDisabled)
+	}
+
+	// Restoring the encoder, as mcftest.Restore does, keeps it a verifier.
+	if err := mcf.Register(mcf.LDAP, mcf.Registered(mcf.LDAP)); err != nil {
+		t.Fatal(err)
+	}
+	value, err := Create("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if isCurrent, err := mcf.IsCurrent(value); err != nil || !isCurrent {
+		t.Errorf("mcf.IsCurrent %s after Register: got (%t, %v), expected (true, nil)", value, isCurrent, err)
+	}
+
+	for i, v := range testVectors {
+		isValid, err := mcf.Verify(v.plaintext, v.value)
+		if err != nil || !isValid {
+			t.Errorf("%d: mcf.Verify %s: got (%t, %v), expected (true, nil)", i, v.value, isValid, err)
+		}
+		if isCurrent, err := mcf.IsCurrent(v.value); err != nil || isCurrent {
+			t.Errorf("%d: mcf.IsCurrent %s: got (%t, %v), expected (false, nil)", i, v.value, isCurrent, err)
+		}
+	}
+
+	for _, value := range []string{"{RC4}5en6G6MezRroT3XKqkdPOmY/BfQ=", "{SHA"} {
+		if _, err := mcf.Verify("secret", value); !errors.Is(err, mcf.ErrUnknownScheme) {
+			t.Errorf("mcf.Verify %q: got %v, expected %v", value, err, mcf.ErrUnknownScheme)
+		}
+	}
+}
+
+func TestScheme(t *testing.T) {
+	for _, tt := range []struct {
+		value, scheme, rest string
+		ok                  bool
+	}{
+		{"{ssha}abc", "SSHA", "abc", true},
+		{"{CRYPT}$2a$...", "CRYPT", "$2a$...", true},
+		{"{}abc", "", "", false},
+		{"{SSHA", "", "", false},
+		{"$2a$...", "", "", false},
+	} {
+		scheme, rest, ok := Scheme(tt.value)
+		if scheme != tt.scheme || rest != tt.rest || ok != tt.ok {
+			t.Errorf("Scheme %q: got (%q, %q, %t), expected (%q, %q, %t)", tt.value, scheme, rest, ok, tt.scheme, tt.rest, tt.ok)
+		}
+	}
+}
+
+func TestErrors(t *testing.T) {
+	for _, tt := range []struct {
+		value string
+		want  error
+	}{
+		{"5en6G6MezRroT3XKqkdPOmY/BfQ=", mcf.ErrUnknownScheme},
+		{"{RC4}5en6G6MezRroT3XKqkdPOmY/BfQ=", mcf.ErrUnknownScheme},
+		{"{CRYPT}$unknown$", mcf.ErrUnknownScheme},
+		{"{SHA}5en6G6MezRroT3XKqkdPOmY/BfQ", mcf.ErrMalformedHash},
+		{"{SHA}5en6G6MezRroT3XKqkdPOmY/BfQAAAA=", mcf.ErrMalformedHash},
+		{"{SSHA}lHFzXul4wnzRItssVcTnvXWR", mcf.ErrMalformedHash},
+		{"{PBKDF2-SHA256}1000$c2FsdHNhbHRzYWx0c2FsdA", mcf.ErrMalformedHash},
+		{"{PBKDF2-SHA256}x$c2FsdHNhbHRzYWx0c2FsdA$dClvKSmj66n6MdMWNv3Go4mvH1Ym2WIGiJvquqa.mfE", mcf.ErrMalformedHash},
+		{"{PBKDF2-SHA256}0$c2FsdHNhbHRzYWx0c2FsdA$dClvKSmj66n6MdMWNv3Go4mvH1Ym2WIGiJvquqa.mfE", mcf.ErrInvalidParams},
+		{"{PBKDF2-SHA256}1000$c2FsdHNhbHRzYWx0c2FsdA$", mcf.ErrMalformedHash},
+		{"{ARGON2}$argon2d$v=19$m=65536,t=2,p=1$c29tZXNhbHQ$wWKIMhR9lyDFvRz9YTZweHKfbftvj+qf+YFY4NeBbtA", mcf.ErrUnsupportedVersion},
+		{"{ARGON2}$argon2i$v=16$m=65536,t=2,p=1$c29tZXNhbHQ$wWKIMhR9lyDFvRz9YTZweHKfbftvj+qf+YFY4NeBbtA", mcf.ErrUnsupportedVersion},
+		{"{ARGON2}$argon2i$v=19$m=65536,t=2$c29tZXNhbHQ$wWKIMhR9lyDFvRz9YTZweHKfbftvj+qf+YFY4NeBbtA", mcf.ErrMalformedHash},
+		{"{ARGON2}$argon2i$v=19$m=4294967295,t=2,p=1$c29tZXNhbHQ$wWKIMhR9lyDFvRz9YTZweHKfbftvj+qf+YFY4NeBbtA", mcf.ErrInvalidParams},
+		{"{ARGON2}$argon2i$v=19$m=1048576,t=64,p=1$c29tZXNhbHQ$wWKIMhR9lyDFvRz9YTZweHKfbftvj+qf+YFY4NeBbtA", mcf.ErrInvalidParams},
+		{"{ARGON2}$argon2i$v=19$m=65536,t=0,p=1$c29tZXNhbHQ$wWKIMhR9lyDFvRz9YTZweHKfbftvj+qf+YFY4NeBbtA", mcf.ErrInvalidParams},
+		{"{ARGON2}argon2i$v=19$m=65536,t=2,p=1$c29tZXNhbHQ$wWKIMhR9lyDFvRz9YTZweHKfbftvj+qf+YFY4NeBbtA", mcf.ErrMalformedHash},
+	} {
+		if isValid, err := Verify("secret", tt.value); isValid || !errors.Is(err, tt.want) {
+			t.Errorf("Verify %q: got (%t, %v), expected (false, %v)", tt.value, isValid, err, tt.want)
+		}
+	}
+
+	for _, value := range []string{"secret", "{RC4}abc"} {
+		if _, err := IsCurrent(value); !errors.Is(err, mcf.ErrUnknownScheme) {
+			t.Errorf("IsCurrent %q: got %v, expected %v", value, err, mcf.ErrUnknownScheme)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+// Copyright 2014 Gyepi Sam. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Package legacy verifies unsalted hex digests of passwords, as stored by the oldest applications:
+
+	5f4dcc3b5aa765d61d8327deb882cf99                                    MD5
+	5baa61e4c9b93f3f0682250b6cf8331b7ee68fd8                            SHA-1
+	5e884898da28047151d0e56f8dc6292773603d0d6aabbdd62a11ef721d1542d8    SHA-256
+
+A digest has no prefix or salt, so its hash function is known only by its length, and either case of hex
+digits is accepted. Any hex string of those lengths is taken for a digest, so importing the package does
+nothing. The application must call Enable to register the encoder, with mcf.RegisterVerifier, and its detector:
+
+	import "github.com/gyepisam/mcf/legacy"
+
+	func init() {
+		legacy.Enable()
+	}
+*/
+package legacy
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"sync"
+
+	"github.com/gyepisam/mcf"
+	"github.com/gyepisam/mcf/encoder"
+)
+
+// ErrCreateDisabled is returned by the Create method of the registered encoder.
+var ErrCreateDisabled = fmt.Errorf("%w: legacy", encoder.ErrCreateDisabled)
+
+// A digest is a hash function, which is recognized by the length of its hex digests.
+type digest struct {
+	name string
+	hash func() hash.Hash
+}
+
+// digests are the hash functions, by the length of their hex digests.
+var digests = map[int]digest{
+	2 * md5.Size:    {"md5", md5.New},
+	2 * sha1.Size:   {"sha1", sha1.New},
+	2 * sha256.Size: {"sha256", sha256.New},
+}
+
+// parse returns the digest function and the decoded digest of an encoded password.
+func parse(encoded []byte) (d digest, sum []byte, err error) {
+	d, ok := digests[len(encoded)]
+	if !ok {
+		return d, nil, fmt.Errorf("%w: legacy: %d is not the length of a known hex digest", encoder.ErrMalformedHash, len(encoded))
+	}
+	sum = make([]byte, hex.DecodedLen(len(encoded)))
+	if _, err := hex.Decode(sum, encoded); err != nil {
+		return d, nil, fmt.Errorf("%w: legacy: %s", encoder.ErrMalformedHash, err)
+	}
+	return d, sum, nil
+}
+
+var enable sync.Once
+
+// Enable registers the encoder and its detector with mcf, so that mcf.Verify recognizes hex digests.
+// It must be called before any digests are verified, and may be called more than once.
+func Enable() {
+	enable.Do(func() {
+		if err := mcf.RegisterVerifier(mcf.LEGACY, legacy{}); err != nil {
+			panic(err)
+		}
+		if err := mcf.RegisterDetector(detect); err != nil {
+			panic(err)
+		}
+	})
+}
+
+// detect recognizes hex digests of known lengths for mcf.
+func detect(encoded []byte) (encoder.Encoder, bool) {
+	if _, _, err := parse(encoded); err != nil {
+		return nil, false
+	}
+	return mcf.Registered(mcf.LEGACY), true
+}
+
+type legacy struct{}
+
+// Id returns "legacy", the name of the encoding. Digests have no id and are recognized by their length.
+func (legacy) Id() []byte {
+	return []byte("legacy")
+}
+
+// Create returns ErrCreateDisabled.
+func (legacy) Create(plaintext []byte) (encoded []byte, err error) {
+	return nil, ErrCreateDisabled
+}
+
+// Verify returns true if the digest of the plaintext password matches the encoded digest.
+// The digests are compared in constant time.
+func (legacy) Verify(plaintext, encoded []byte) (isValid bool, err error) {
+	d, sum, err := parse(encoded)
+	if err != nil {
+		return false, err
+	}
+	h := d.hash()
+	h.Write(plaintext)
+	return subtle.ConstantTimeCompare(h.Sum(nil), sum) == 1, nil
+}
+
+// IsCurrent returns false for a valid digest, since unsalted digests should be replaced.
+func (legacy) IsCurrent(encoded []byte) (isCurrent bool, err error) {
+	_, _, err = parse(encoded)
+	return false, err
+}
+
+// ParseParams implements encoder.ParamsParser. The only parameter is "hash": md5, sha1 or sha256.
+func (legacy) ParseParams(encoded []byte) (map[string]string, error) {
+	d, _, err := parse(encoded)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{"hash": d.name}, nil
+}
@@ -0,0 +1,104 @@
+// Copyright 2014 Gyepi Sam. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package legacy
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gyepisam/mcf"
+	"github.com/gyepisam/mcf/encoder"
+	"github.com/gyepisam/mcf/mcftest"
+)
+
+// Digests of "password", by each hash function.
+var hashes = []struct {
+	encoded string
+	hash    string
+}{
+	{"5f4dcc3b5aa765d61d8327deb882cf99", "md5"},
+	{"5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8", "sha1"},
+	{"5e884898da28047151d0e56f8dc6292773603d0d6aabbdd62a11ef721d1542d8", "sha256"},
+}
+
+// notEnabled describes any sign that the package is enabled before Enable is called.
+// Enable cannot be undone, so TestMain calls it before Enable and the tests.
+func notEnabled() string {
+	if mcf.Registered(mcf.LEGACY) != nil {
+		return "legacy is registered before Enable"
+	}
+	if _, err := mcf.Verify("password", hashes[0].encoded); !errors.Is(err, mcf.ErrUnknownScheme) {
+		return fmt.Sprintf("Verify before Enable: got %v, expected %v", err, mcf.ErrUnknownScheme)
+	}
+	return ""
+}
+
+func TestMain(m *testing.M) {
+	if msg := notEnabled(); msg != "" {
+		fmt.Fprintln(os.Stderr, msg)
+		os.Exit(1)
+	}
+	Enable()
+	os.Exit(m.Run())
+}
+
+func TestEnable(t *testing.T) {
+	Enable() // Again.
+	if mcf.Registered(mcf.LEGACY) == nil {
+		t.Fatal("legacy is not registered after Enable")
+	}
+	if mcf.Default() == mcf.LEGACY {
+		t.Errorf("legacy is the default encoding")
+	}
+}
+
+func TestVectors(t *testing.T) {
+	vectors, err := mcftest.LoadVectors("../test/vectors/legacy.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mcftest.RunVectors(t, vectors)
+}
+
+// Digests are never current, and their hash function is known by their length.
+func TestDigest(t *testing.T) {
+	enc := mcf.Registered(mcf.LEGACY)
+
+	for i, v := range hashes {
+		if isCurrent, err := mcf.IsCurrent(v.encoded); err != nil || isCurrent {
+			t.Errorf("%d: IsCurrent: got (%t, %v), expected (false, nil)", i, isCurrent, err)
+		}
+		params, err := enc.(encoder.ParamsParser).ParseParams([]byte(v.encoded))
+		if err != nil || params["hash"] != v.hash {
+			t.Errorf("%d: ParseParams: got (%v, %v), expected hash %s", i, params, err, v.hash)
+		}
+	}
+
+	if _, err := enc.Create([]byte("password")); !errors.Is(err, mcf.ErrCreateDisabled) {
+		t.Errorf("Create: got %v, expected %v", err, mcf.ErrCreateDisabled)
+	}
+}
+
+func TestMalformed(t *testing.T) {
+	enc := mcf.Registered(mcf.LEGACY)
+
+	for _, encoded := range []string{
+		"",
+		"password",
+		strings.Repeat("0", 31),
+		strings.Repeat("0", 48),
+		"5f4dcc3b5aa765d61d8327deb882cfzz",
+	} {
+		if isValid, err := enc.Verify([]byte("password"), []byte(encoded)); isValid || !errors.Is(err, encoder.ErrMalformedHash) {
+			t.Errorf("Verify %q: got (%t, %v), expected (false, %v)", encoded, isValid, err, encoder.ErrMalformedHash)
+		}
+		if _, err := mcf.Verify("password", encoded); !errors.Is(err, mcf.ErrUnknownScheme) {
+			t.Errorf("mcf.Verify %q: got %v, expected %v", encoded, err, mcf.ErrUnknownScheme)
+		}
+	}
+}
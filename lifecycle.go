@@ -0,0 +1,186 @@
+// Copyright 2014 Gyepi Sam. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mcf
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/gyepisam/mcf/encoder"
+)
+
+// A State is the standing of a password scheme under the lifecycle policy.
+type State uint8
+
+// States, in order of decreasing standing.
+const (
+	StatePreferred  State = iota // Used for new passwords. By default, the state of the default encoding.
+	StateAccepted                // Verified, and current if the encoder says so. By default, the state of other encodings.
+	StateDeprecated              // Verified, but never current, so passwords are replaced at the next login.
+	StateRejected                // Never verified. Verify returns an *ErrSchemeRejected without computing a key.
+)
+
+func (s State) String() string {
+	switch s {
+	case StatePreferred:
+		return "preferred"
+	case StateAccepted:
+		return "accepted"
+	case StateDeprecated:
+		return "deprecated"
+	case StateRejected:
+		return "rejected"
+	}
+	return "unknown"
+}
+
+// A Stage is a State that takes effect at a given time.
+type Stage struct {
+	State State
+	From  time.Time // The zero value means that the stage is always in effect.
+}
+
+// A Lifecycle assigns states to the passwords of an encoding over time.
+// For instance, this lifecycle deprecates pbkdf2 passwords that use SHA1 now,
+// and rejects them from 2027:
+//
+//	mcf.SetLifecycle(mcf.Lifecycle{
+//		Encoding: mcf.PBKDF2,
+//		Params:   map[string]string{"hmac": "SHA1"},
+//		Stages: []mcf.Stage{
+//			{State: mcf.StateDeprecated},
+//			{State: mcf.StateRejected, From: time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)},
+//		},
+//	})
+type Lifecycle struct {
+	Encoding Encoding
+
+	// Params, if set, restricts the lifecycle to passwords with these parameter values,
+	// as reported by an encoder that implements encoder.ParamsParser.
+	// A lifecycle with Params takes precedence over one without.
+	Params map[string]string
+
+	// Stages lists the states of the encoding. The state at any time is that of the latest
+	// stage that has taken effect. Before the first stage, the default state applies.
+	Stages []Stage
+}
+
+var lifecycles []Lifecycle
+
+// ErrSchemeRejected is returned by Verify for a password whose scheme has been rejected by a Lifecycle.
+// Use errors.As to detect it.
+type ErrSchemeRejected struct {
+	Encoding Encoding
+	Since    time.Time // The time the rejection took effect, zero if always.
+}
+
+func (e *ErrSchemeRejected) Error() string {
+	if e.Since.IsZero() {
+		return fmt.Sprintf("password scheme %s is rejected by policy", e.Encoding)
+	}
+	return fmt.Sprintf("password scheme %s is rejected by policy since %s", e.Encoding, e.Since.Format(time.DateOnly))
+}
+
+// SetLifecycle adds a lifecycle, replacing any other for the same encoding and parameters.
+// A lifecycle without stages removes the existing one.
+// Like Register, it is meant to be called during initialization.
+func SetLifecycle(l Lifecycle) error {
+	if !l.Encoding.IsValid() {
+		return l.Encoding.errInvalid()
+	}
+
+	stages := append([]Stage(nil), l.Stages...)
+	sort.SliceStable(stages, func(i, j int) bool { return stages[i].From.Before(stages[j].From) })
+
+	params := make(map[string]string, len(l.Params))
+	for k, v := range l.Params {
+		params[k] = v
+	}
+
+	l = Lifecycle{Encoding: l.Encoding, Params: params, Stages: stages}
+
+	list := lifecycles[:0:0]
+	for _, x := range lifecycles {
+		if x.Encoding != l.Encoding || !sameParams(x.Params, l.Params) {
+			list = append(list, x)
+		}
+	}
+	if len(l.Stages) > 0 {
+		list = append(list, l)
+	}
+
+	// More specific lifecycles first.
+	sort.SliceStable(list, func(i, j int) bool { return len(list[i].Params) > len(list[j].Params) })
+
+	lifecycles = list
+	return nil
+}
+
+func sameParams(a, b map[string]string) bool {
+	return len(a) == len(b) && matchParams(a, b)
+}
+
+// Lifecycles returns the lifecycles set by SetLifecycle.
+func Lifecycles() []Lifecycle {
+	return append([]Lifecycle(nil), lifecycles...)
+}
+
+// StateOf returns the state of an encoded password at the given time.
+// It parses the password but does not compute a key.
+func StateOf(encoded string, at time.Time) (State, error) {
+	b := []byte(encoded)
+	encoding, enc, err := findInstance(b)
+	if enc == nil {
+		return StateRejected, err
+	}
+	state, _ := stateFor(encoding, enc, b, at)
+	return state, nil
+}
+
+// stateFor returns the state of an encoded password and the time that state took effect.
+func stateFor(encoding Encoding, enc *instance, encoded []byte, at time.Time) (State, time.Time) {
+	var params map[string]string
+	parsed := false
+
+	for _, l := range lifecycles {
+		if l.Encoding != encoding {
+			continue
+		}
+
+		if len(l.Params) > 0 {
+			if !parsed {
+				parsed = true
+				if p, ok := enc.Encoder.(encoder.ParamsParser); ok {
+					params, _ = p.ParseParams(encoded)
+				}
+			}
+			if !matchParams(params, l.Params) {
+				continue
+			}
+		}
+
+		for i := len(l.Stages) - 1; i >= 0; i-- {
+			if s := l.Stages[i]; !s.From.After(at) {
+				return s.State, s.From
+			}
+		}
+	}
+
+	if encoding == defaultEncoding {
+		return StatePreferred, time.Time{}
+	}
+	return StateAccepted, time.Time{}
+}
+
+// matchParams returns true if have contains every entry in want.
+func matchParams(have, want map[string]string) bool {
+	for k, v := range want {
+		if w, ok := have[k]; !ok || v != w {
+			return false
+		}
+	}
+	return true
+}
@@ -7,42 +7,110 @@ package mcf
 import (
 	"bytes"
 	"crypto/rand"
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/gyepisam/mcf/encoder"
 )
 
 type instance struct {
-	id []byte
+	id       []byte
+	verifier bool // Registered with RegisterVerifier.
 	encoder.Encoder
 }
 
 var (
 	encoders        [maxEncoding]*instance
+	detectors       []detector
 	defaultEncoding = maxEncoding
+	preCreateHooks  []*PreCreateHook
 )
 
 // ErrNoEncoder is returned if an encoded password does not match any known encoders.
-// The encoded password is appended to the error message to aid in resolving the problem.
+// The scheme of the encoded password, but not the password itself, is appended to the
+// error message to aid in resolving the problem.
+// It wraps ErrUnknownScheme.
 type ErrNoEncoder struct {
 	encoded string
 }
 
 func (e *ErrNoEncoder) Error() string {
-	return fmt.Sprintf("No matching encoder found for: %q", e.encoded)
+	return fmt.Sprintf("No matching encoder found for: %q", Hash(e.encoded).String())
 }
 
+// Unwrap returns ErrUnknownScheme.
+func (e *ErrNoEncoder) Unwrap() error { return ErrUnknownScheme }
+
+// ErrAmbiguousScheme is returned if detectors of the same precedence recognize an encoded password
+// as belonging to different encoders, whose ids are listed in the error message.
+// It wraps ErrUnknownScheme. See RegisterDetectorPrecedence.
+type ErrAmbiguousScheme struct {
+	encoded string
+	Ids     []string
+}
+
+func (e *ErrAmbiguousScheme) Error() string {
+	return fmt.Sprintf("Ambiguous scheme for: %q, which could be any of: %s", Hash(e.encoded).String(), strings.Join(e.Ids, ", "))
+}
+
+// Unwrap returns ErrUnknownScheme.
+func (e *ErrAmbiguousScheme) Unwrap() error { return ErrUnknownScheme }
+
 // A SaltMiner is function that takes an int and produces that many random bytes.
 // It exists to allow variation in the source of salt.
+// Each encoder's configuration has a SaltMine field that takes one. If it is nil, salt is read
+// from crypto/rand. Set it to use a different source, such as ReaderMiner(r), or a fixed salt for testing.
 type SaltMiner func(int) ([]byte, error)
 
+// MinSaltLen is the shortest salt, in bytes, that encoders with a configurable salt length accept
+// for new passwords. Stored passwords with shorter salts, or none, are still verified.
+const MinSaltLen = 8
+
+// ReaderMiner returns a SaltMiner that reads salt from r, which must be safe for concurrent use
+// if the encoder is used concurrently.
+func ReaderMiner(r io.Reader) SaltMiner {
+	return func(n int) ([]byte, error) {
+		salt := make([]byte, n)
+		_, err := io.ReadFull(r, salt)
+		return salt, err
+	}
+}
+
+// A PreCreateHook examines a plaintext password before Create encodes it.
+// A non-nil error rejects the password and is returned by Create unchanged.
+// Hooks must not retain or log the plaintext.
+type PreCreateHook func(plaintext []byte) error
+
+// AddPreCreateHook adds a hook to the list that Create runs, in order of addition,
+// before encoding a new password. This is the place to enforce password policy,
+// such as rejecting known compromised passwords.
+// See github.com/gyepisam/mcf/pwned for an example.
+// The returned function removes the hook, and is mostly of use in tests.
+func AddPreCreateHook(hook PreCreateHook) (remove func()) {
+	h := &hook
+	preCreateHooks = append(preCreateHooks, h)
+	return func() {
+		for i, e := range preCreateHooks {
+			if e == h {
+				preCreateHooks = append(preCreateHooks[:i:i], preCreateHooks[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
 // Register adds an encoder implementation to the list.
 // It is expected that each encoder will call Register from an init() function.
 // The first encoder imported becomes the default and is used to create new passwords.
 // Subsequent imported encoders, if any, are used for decoding, where necessary.
 // See SetDefault() to set the default encoder manually.
+// An encoding registered with RegisterVerifier remains a verifier, so that Register can restore its encoder.
 func Register(encoding Encoding, enc encoder.Encoder) error {
 	if !encoding.IsValid() {
 		return encoding.errInvalid()
@@ -53,16 +121,83 @@ func Register(encoding Encoding, enc encoder.Encoder) error {
 		return fmt.Errorf("empty id: encoding=%s", encoding)
 	}
 
-	encoders[encoding] = &instance{id: id, Encoder: enc}
+	verifier := encoders[encoding] != nil && encoders[encoding].verifier
+	encoders[encoding] = &instance{id: id, verifier: verifier, Encoder: enc}
 
 	// default to first registered encoder.
-	if !defaultEncoding.IsValid() {
+	if !defaultEncoding.IsValid() && !verifier {
 		defaultEncoding = encoding
 	}
 
 	return nil
 }
 
+// RegisterVerifier is like Register, but enc never becomes the default, whatever the order of imports.
+// It is intended for encoders of legacy schemes, such as those of the aspnet, phpass and legacy packages.
+// Their passwords are verified so that users can log in and have them replaced by the default encoder:
+// the IsCurrent method of enc should return false, and its Create method an error that wraps ErrCreateDisabled.
+//
+// Since the passwords of enc are never those of the default encoding, IsCurrent leaves them to enc to judge,
+// so that an encoder that wraps passwords of other schemes, such as LDAP's {CRYPT}, may find them current.
+func RegisterVerifier(encoding Encoding, enc encoder.Encoder) error {
+	def := defaultEncoding
+	if err := Register(encoding, enc); err != nil {
+		return err
+	}
+	encoders[encoding].verifier = true
+	defaultEncoding = def
+	return nil
+}
+
+// A Detector recognizes encoded passwords that lack the $id$ prefix of Modular Crypt Format,
+// such as Django's pbkdf2_sha256$..., LDAP's {SSHA}... or the base64 blobs of ASP.NET Identity.
+// It returns the encoder of a password it recognizes, and true.
+// The encoder should be registered, and is best found with Registered, so that lifecycles,
+// observers and Audit know its encoding.
+type Detector func(encoded []byte) (enc encoder.Encoder, ok bool)
+
+// Precedences of detectors. See RegisterDetectorPrecedence.
+const (
+	// PrecedenceContent is the precedence of detectors that recognize passwords by their length
+	// and alphabet alone, such as base64 blobs or bare hex digests. RegisterDetector uses it.
+	PrecedenceContent = 0
+
+	// PrecedencePrefix is the precedence of detectors that recognize a distinctive prefix,
+	// such as {SSHA} or pbkdf2_sha256$.
+	PrecedencePrefix = 10
+)
+
+type detector struct {
+	precedence int
+	detect     Detector
+}
+
+// RegisterDetector adds a Detector with PrecedenceContent. See RegisterDetectorPrecedence.
+func RegisterDetector(detect Detector) error {
+	return RegisterDetectorPrecedence(PrecedenceContent, detect)
+}
+
+// RegisterDetectorPrecedence adds a Detector so that Verify, IsCurrent and the other functions
+// that take encoded passwords find encoders for passwords that have no id.
+// It is expected that such an encoder will call it from an init() function, after Register or RegisterVerifier.
+//
+// Ids are matched first, and detectors are consulted only for passwords that no id matches,
+// in order of decreasing precedence. If detectors of the same precedence recognize a password
+// as belonging to different encoders, the password is ambiguous and produces an *ErrAmbiguousScheme,
+// rather than being given to either.
+func RegisterDetectorPrecedence(precedence int, detect Detector) error {
+	if detect == nil {
+		return errors.New("nil detector")
+	}
+
+	detectors = append(detectors, detector{precedence, detect})
+	sort.SliceStable(detectors, func(i, j int) bool {
+		return detectors[i].precedence > detectors[j].precedence
+	})
+
+	return nil
+}
+
 // SetDefault sets the default encoding used to create passwords.
 // Since the first registered encoder is used as the default encoder,
 // it is not necessary to call this routine unless you have multiple encoders
@@ -81,10 +216,26 @@ func SetDefault(encoding Encoding) error {
 	return nil
 }
 
+// Default returns the default encoding, which is not valid if no encoders are registered.
+func Default() Encoding {
+	return defaultEncoding
+}
+
+// Registered returns the encoder registered for encoding, or nil if there is none.
+// It allows an encoder to be saved and later restored with Register.
+func Registered(encoding Encoding) encoder.Encoder {
+	if !encoding.IsValid() || encoders[encoding] == nil {
+		return nil
+	}
+	return encoders[encoding].Encoder
+}
+
 // Create takes a plaintext password and uses the default encoder to
 // create an encoded password in Modular Crypt Format, which it returns.
 // The application is expected to store this password in order to subsequently
 // verify the plaintext password.
+// The plaintext is first passed to any hooks added with AddPreCreateHook
+// and the first error, if any, is returned.
 func Create(plaintext string) (encoded string, err error) {
 
 	if !defaultEncoding.IsValid() {
@@ -98,63 +249,232 @@ func Create(plaintext string) (encoded string, err error) {
 		panic(fmt.Sprintf("missing implementation for encoding [%s]", defaultEncoding))
 	}
 
+	return create(defaultEncoding, enc, plaintext)
+}
+
+// create runs the pre-create hooks and then encodes plaintext with enc.
+func create(encoding Encoding, enc *instance, plaintext string) (encoded string, err error) {
+	start := time.Now()
+
+	for _, hook := range preCreateHooks {
+		if err = (*hook)([]byte(plaintext)); err != nil {
+			observe(OpCreate, encoding, enc, start, OutcomeRefused, false)
+			return
+		}
+	}
+
 	b, err := enc.Create([]byte(plaintext))
 	if err != nil {
+		observe(OpCreate, encoding, enc, start, errorOutcome(err), false)
 		return
 	}
 
+	observe(OpCreate, encoding, enc, start, OutcomeOK, false)
 	return string(b), nil
 }
 
-func findInstance(encoded []byte) (Encoding, *instance) {
+// Dummy returns an encoded password that no one knows, to verify against when a user does not exist.
+// The time taken to reject an unknown user is then similar to that taken for a known user,
+// which avoids disclosing which user names exist.
+// It uses the default encoder directly, since pre-create hooks have no business refusing it.
+func Dummy() (encoded string, err error) {
+	enc := Registered(Default())
+	if enc == nil {
+		return "", errors.New("mcf: no default encoder for the dummy password")
+	}
+	b := make([]byte, 18)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("mcf: dummy password: %w", err)
+	}
+	e, err := enc.Create([]byte(base64.StdEncoding.EncodeToString(b)))
+	if err != nil {
+		return "", fmt.Errorf("mcf: dummy password: %w", err)
+	}
+	return string(e), nil
+}
+
+// findInstance returns the encoder of an encoded password, found by its id or by a detector.
+// If there is none, the error is an *ErrNoEncoder or an *ErrAmbiguousScheme.
+func findInstance(encoded []byte) (Encoding, *instance, error) {
 	for i, e := range encoders {
 		if e == nil {
 			continue
 		}
 
-		if len(encoded) > 0 && bytes.HasPrefix(encoded[1:], e.id) {
-			return Encoding(i), e
+		if e.matches(encoded) {
+			return Encoding(i), e, nil
+		}
+	}
+
+	return detect(encoded)
+}
+
+// detect consults the detectors, in order of precedence, for an encoded password that no id matches.
+func detect(encoded []byte) (Encoding, *instance, error) {
+	for i := 0; i < len(detectors); {
+		// The detectors of one precedence.
+		j := i + 1
+		for j < len(detectors) && detectors[j].precedence == detectors[i].precedence {
+			j++
+		}
+
+		found, inst := maxEncoding, (*instance)(nil)
+		var ids []string
+		for _, d := range detectors[i:j] {
+			enc, ok := d.detect(encoded)
+			if !ok || enc == nil {
+				continue
+			}
+			encoding, e := instanceOf(enc)
+			if inst != nil && (encoding != found || !encoding.IsValid() && !bytes.Equal(e.id, inst.id)) {
+				if len(ids) == 0 {
+					ids = append(ids, string(inst.id))
+				}
+				ids = append(ids, string(e.id))
+				continue
+			}
+			found, inst = encoding, e
+		}
+
+		if len(ids) > 0 {
+			return maxEncoding, nil, &ErrAmbiguousScheme{encoded: string(encoded), Ids: ids}
+		}
+		if inst != nil {
+			return found, inst, nil
+		}
+		i = j
+	}
+
+	return maxEncoding, nil, &ErrNoEncoder{string(encoded)}
+}
+
+// instanceOf returns the registered instance of a detected encoder, and its encoding.
+// An encoder that is not registered gets a new instance and an invalid encoding.
+func instanceOf(enc encoder.Encoder) (Encoding, *instance) {
+	if reflect.TypeOf(enc).Comparable() {
+		for i, e := range encoders {
+			if e != nil && e.Encoder == enc {
+				return Encoding(i), e
+			}
+		}
+	}
+	return maxEncoding, &instance{id: enc.Id(), Encoder: enc}
+}
+
+// matches returns true if the id of the encoded password is that of the instance, or one of its aliases.
+func (inst *instance) matches(encoded []byte) bool {
+	if hasID(encoded, inst.id) {
+		return true
+	}
+	if a, ok := inst.Encoder.(encoder.Aliaser); ok {
+		for _, id := range a.Aliases() {
+			if hasID(encoded, id) {
+				return true
+			}
 		}
 	}
-	return maxEncoding, nil
+	return false
+}
+
+// hasID returns true if the encoded password begins with a separator and id, followed by a separator
+// or nothing. An id must not be confused with a longer one that it prefixes.
+func hasID(encoded, id []byte) bool {
+	if len(encoded) == 0 || encoded[0] != '$' || !bytes.HasPrefix(encoded[1:], id) {
+		return false
+	}
+	rest := encoded[1+len(id):]
+	return len(rest) == 0 || rest[0] == '$'
 }
 
 // Verify takes a plaintext password and a encoded password and returns true
 // if the password, when encoded by the same encoder, using the same parameters,
 // matches the encoded password.
+// A password that does not match produces false and a nil error;
+// an error indicates a problem with the encoded password. See ErrMalformedHash.
+// A password whose scheme is rejected by a Lifecycle is not verified and produces an *ErrSchemeRejected.
 func Verify(plaintext, encoded string) (isValid bool, err error) {
+	start := time.Now()
 	b := []byte(encoded)
-	_, enc := findInstance(b)
+	encoding, enc, err := findInstance(b)
 	if enc == nil {
-		return false, &ErrNoEncoder{encoded}
+		observe(OpVerify, encoding, nil, start, OutcomeUnknownScheme, false)
+		return false, err
+	}
+
+	if state, since := stateFor(encoding, enc, b, time.Now()); state == StateRejected {
+		observe(OpVerify, encoding, enc, start, OutcomeRejected, false)
+		return false, &ErrSchemeRejected{Encoding: encoding, Since: since}
+	}
+
+	isValid, err = enc.Verify([]byte(plaintext), b)
+
+	outcome := OutcomeMismatch
+	if err != nil {
+		outcome = errorOutcome(err)
+	} else if isValid {
+		outcome = OutcomeMatch
 	}
-	return enc.Verify([]byte(plaintext), b)
+	observe(OpVerify, encoding, enc, start, outcome, false)
+
+	return
 }
 
 // IsCurrent returns true if the encoded password was generated by the current encoder with the current parameters.
 // If it returns false, then the encoded password should be regenerated and replaced.
 // Assuming that policy changes are always to increase security by using stronger hashes or increasing work factors,
 // IsCurrent presents a mechanism to query an encoded password and determine whether it needs to be re-created.
+// Passwords whose scheme is deprecated or rejected by a Lifecycle are never current.
 func IsCurrent(encoded string) (isCurrent bool, err error) {
+	return checkCurrent(encoded, defaultEncoding, nil)
+}
+
+// checkCurrent determines whether encoded is current with respect to the encoding want.
+// The parameters of the encoded password are judged by judge, or if it is nil,
+// by the registered encoder.
+func checkCurrent(encoded string, want Encoding, judge *instance) (isCurrent bool, err error) {
+	start := time.Now()
 	b := []byte(encoded)
-	encoding, enc := findInstance(b)
+	encoding, enc, err := findInstance(b)
 	if enc == nil {
-		err = &ErrNoEncoder{encoded}
+		observe(OpIsCurrent, encoding, nil, start, OutcomeUnknownScheme, false)
 	} else {
-		isCurrent, err = enc.IsCurrent(b)
+		if judge == nil {
+			judge = enc
+		}
+		isCurrent, err = currentFor(encoding, enc, b, want, judge)
 
-		if err == nil && isCurrent {
-			// if the encoded password's scheme is not the default,
-			// then it is out of date.
-			isCurrent = encoding == defaultEncoding
+		if err != nil {
+			observe(OpIsCurrent, encoding, enc, start, errorOutcome(err), false)
+		} else {
+			observe(OpIsCurrent, encoding, enc, start, OutcomeOK, !isCurrent)
 		}
 	}
 	return
 }
 
+// currentFor asks judge whether the encoded password, which belongs to enc, is current.
+// If the encoded password's scheme is not the one wanted, then it is out of date,
+// unless enc is a verifier, which judges its own passwords. One whose scheme is deprecated
+// or rejected by a Lifecycle is always out of date.
+func currentFor(encoding Encoding, enc *instance, encoded []byte, want Encoding, judge *instance) (isCurrent bool, err error) {
+	if encoding != want {
+		judge = enc
+	}
+	isCurrent, err = judge.IsCurrent(encoded)
+	if err == nil && isCurrent && !enc.verifier {
+		isCurrent = encoding == want
+	}
+	if err == nil && isCurrent {
+		state, _ := stateFor(encoding, enc, encoded, time.Now())
+		isCurrent = state < StateDeprecated
+	}
+	return
+}
+
 // Salt produces the specified number of random bytes.
 // If minerFn is nil, the function generates bytes from rand.Reader.
 // Otherwise minerFn is called and its results validated and returned.
+// Errors do not identify the encoder, so callers should add that.
 func Salt(size int, minerFn SaltMiner) (salt []byte, err error) {
 
 	if minerFn == nil {
@@ -166,7 +486,7 @@ func Salt(size int, minerFn SaltMiner) (salt []byte, err error) {
 	salt, err = minerFn(size)
 	if err == nil {
 		if m, n := size, len(salt); m != n {
-			err = fmt.Errorf("%s: short salt read. want: %d, got %d", defaultEncoding, m, n)
+			err = fmt.Errorf("short salt read. want: %d, got %d", m, n)
 		}
 	}
 	return
@@ -0,0 +1,76 @@
+// Copyright 2014 Gyepi Sam. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Package mcfexpvar publishes mcf operation metrics with the expvar package.
+
+	mcf.AddObserver(mcfexpvar.New("mcf"))
+
+Metrics are published in a map with the given name, which is served at /debug/vars
+along with other expvar variables. The map has one entry per operation and encoding,
+such as "verify.scrypt", whose value is a map of counters:
+
+	calls          number of calls
+	seconds        total time spent, in seconds
+	<outcome>      number of calls with each outcome, such as "match" or "mismatch"
+	upgrade        number of IsCurrent calls that reported an out of date password
+
+Average latency is seconds/calls.
+*/
+package mcfexpvar
+
+import (
+	"expvar"
+	"sync"
+
+	"github.com/gyepisam/mcf"
+)
+
+// An Observer records mcf events in an expvar.Map. It implements mcf.Observer.
+type Observer struct {
+	vars *expvar.Map
+
+	mu   sync.Mutex
+	keys map[key]*expvar.Map
+}
+
+type key struct {
+	op       mcf.Op
+	encoding mcf.Encoding
+}
+
+// New creates an Observer and publishes its metrics under name.
+// Like expvar.Publish, it panics if name is already in use.
+func New(name string) *Observer {
+	return &Observer{vars: expvar.NewMap(name), keys: map[key]*expvar.Map{}}
+}
+
+// Vars returns the published map.
+func (o *Observer) Vars() *expvar.Map { return o.vars }
+
+func (o *Observer) counters(e mcf.Event) *expvar.Map {
+	k := key{e.Op, e.Encoding}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	m, ok := o.keys[k]
+	if !ok {
+		m = new(expvar.Map).Init()
+		o.keys[k] = m
+		o.vars.Set(e.Op.String()+"."+e.Encoding.String(), m)
+	}
+	return m
+}
+
+// Observe records an event.
+func (o *Observer) Observe(e mcf.Event) {
+	m := o.counters(e)
+	m.Add("calls", 1)
+	m.AddFloat("seconds", e.Duration.Seconds())
+	m.Add(e.Outcome.String(), 1)
+	if e.Upgrade {
+		m.Add("upgrade", 1)
+	}
+}
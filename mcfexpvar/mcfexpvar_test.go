@@ -0,0 +1,45 @@
+package mcfexpvar
+
+import (
+	"encoding/json"
+	"expvar"
+	"testing"
+	"time"
+
+	"github.com/gyepisam/mcf"
+)
+
+func TestObserve(t *testing.T) {
+	o := New("mcftest")
+
+	for _, e := range []mcf.Event{
+		{Op: mcf.OpVerify, Encoding: mcf.SCRYPT, Outcome: mcf.OutcomeMatch, Duration: time.Second},
+		{Op: mcf.OpVerify, Encoding: mcf.SCRYPT, Outcome: mcf.OutcomeMismatch, Duration: 2 * time.Second},
+		{Op: mcf.OpVerify, Encoding: mcf.SCRYPT, Outcome: mcf.OutcomeMatch, Duration: time.Second},
+		{Op: mcf.OpIsCurrent, Encoding: mcf.PBKDF2, Outcome: mcf.OutcomeOK, Upgrade: true},
+		{Op: mcf.OpIsCurrent, Encoding: mcf.PBKDF2, Outcome: mcf.OutcomeOK},
+	} {
+		o.Observe(e)
+	}
+
+	var got map[string]map[string]float64
+	if err := json.Unmarshal([]byte(expvar.Get("mcftest").String()), &got); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]map[string]float64{
+		"verify.scrypt":    {"calls": 3, "seconds": 4, "match": 2, "mismatch": 1},
+		"iscurrent.pbkdf2": {"calls": 2, "seconds": 0, "ok": 2, "upgrade": 1},
+	}
+
+	if len(got) != len(want) {
+		t.Errorf("expected %d entries, got %d: %v", len(want), len(got), got)
+	}
+	for name, counters := range want {
+		for k, v := range counters {
+			if got[name][k] != v {
+				t.Errorf("%s.%s: expected %v, got %v", name, k, v, got[name][k])
+			}
+		}
+	}
+}
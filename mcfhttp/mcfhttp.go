@@ -0,0 +1,200 @@
+// Copyright 2014 Gyepi Sam. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Package mcfhttp provides HTTP Basic authentication middleware backed by mcf.
+
+The application supplies a function that returns the stored, encoded, password for a user:
+
+	auth := &mcfhttp.Authenticator{
+		Realm: "internal",
+		Lookup: func(user string) (string, error) {
+			u, err := db.FindUser(user)
+			if err == db.ErrNotFound {
+				return "", mcfhttp.ErrNoUser
+			}
+			return u.Password, err
+		},
+		Rehash: func(user, encoded string) { db.UpdatePassword(user, encoded) },
+		MaxConcurrent: 4,
+	}
+	http.Handle("/", auth.Wrap(handler))
+
+Requests without valid credentials receive a 401 response with a WWW-Authenticate challenge,
+as do those whose stored password has a scheme that a Lifecycle rejects.
+If the default encoder cannot create the password used to reject unknown users in the time taken
+to verify known ones, every request fails with a 500 status instead.
+*/
+package mcfhttp
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gyepisam/mcf"
+)
+
+// ErrNoUser should be returned by a LookupFunc for an unknown user.
+var ErrNoUser = errors.New("mcfhttp: no such user")
+
+// A LookupFunc returns the encoded password for user, or ErrNoUser, possibly wrapped, if there is no such user.
+// Any other error causes the request to fail with a 500 status.
+type LookupFunc func(user string) (encoded string, err error)
+
+// An Authenticator wraps HTTP handlers with Basic authentication.
+// The zero value is not usable; Lookup must be set.
+// An Authenticator must not be modified after its first use.
+type Authenticator struct {
+	// Realm is sent to clients in the WWW-Authenticate header.
+	Realm string
+
+	// Lookup finds the encoded password for a user.
+	Lookup LookupFunc
+
+	// Rehash, if set, is called after a successful authentication if the stored password is not current.
+	// The new encoded password should be stored in place of the old one.
+	Rehash func(user, encoded string)
+
+	// RehashError, if set, is called with the error if a password that is not current cannot be rehashed.
+	// The user is authentic all the same, and the request proceeds.
+	RehashError func(user string, err error)
+
+	// MaxConcurrent limits the number of password verifications in progress at a time.
+	// Since password hashes are designed to be expensive, this bounds the resources
+	// that can be consumed by a flood of requests. Requests wait for their turn.
+	// Zero means no limit.
+	MaxConcurrent int
+
+	once     sync.Once
+	slots    chan struct{}
+	dummy    string
+	dummyErr error
+}
+
+type contextKey struct{}
+
+// User returns the authenticated user name for a request handled by a wrapped handler.
+func User(r *http.Request) string {
+	user, _ := r.Context().Value(contextKey{}).(string)
+	return user
+}
+
+// BasicAuth is a shortcut for wrapping a handler with an Authenticator that has the given realm and lookup function.
+func BasicAuth(realm string, lookup LookupFunc, next http.Handler) http.Handler {
+	return (&Authenticator{Realm: realm, Lookup: lookup}).Wrap(next)
+}
+
+func (a *Authenticator) init() {
+	if a.MaxConcurrent > 0 {
+		a.slots = make(chan struct{}, a.MaxConcurrent)
+	}
+	a.dummy, a.dummyErr = mcf.Dummy()
+}
+
+// acquire waits for a verification slot. It fails if the request is canceled first.
+func (a *Authenticator) acquire(ctx context.Context) bool {
+	if a.slots == nil {
+		return true
+	}
+	select {
+	case a.slots <- struct{}{}:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (a *Authenticator) release() {
+	if a.slots != nil {
+		<-a.slots
+	}
+}
+
+func (a *Authenticator) challenge(w http.ResponseWriter) {
+	realm := strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(a.Realm)
+	w.Header().Set("WWW-Authenticate", `Basic realm="`+realm+`", charset="UTF-8"`)
+	http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+}
+
+// Wrap returns a handler that authenticates each request before passing it to next.
+func (a *Authenticator) Wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, plaintext, ok := r.BasicAuth()
+		if !ok {
+			a.challenge(w)
+			return
+		}
+
+		a.once.Do(a.init)
+		if a.dummyErr != nil {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		if !a.acquire(r.Context()) {
+			http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+			return
+		}
+		isValid, err := a.authenticate(user, plaintext)
+		a.release()
+
+		// A password whose scheme is rejected by policy is no better than a wrong one.
+		var rejected *mcf.ErrSchemeRejected
+		if errors.As(err, &rejected) {
+			a.challenge(w)
+			return
+		}
+		if err != nil {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		if !isValid {
+			a.challenge(w)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), contextKey{}, user)))
+	})
+}
+
+func (a *Authenticator) authenticate(user, plaintext string) (isValid bool, err error) {
+	encoded, err := a.Lookup(user)
+	if errors.Is(err, ErrNoUser) {
+		// Spend the time anyway, but fail regardless.
+		mcf.Verify(plaintext, a.dummy)
+		return false, nil
+	}
+	if err != nil {
+		return
+	}
+
+	isValid, err = mcf.Verify(plaintext, encoded)
+	if err != nil || !isValid || a.Rehash == nil {
+		return
+	}
+
+	// The user is authentic; a failure to rehash is not their problem.
+	if err := a.rehash(user, plaintext, encoded); err != nil && a.RehashError != nil {
+		a.RehashError(user, err)
+	}
+	return true, nil
+}
+
+// rehash passes a new encoding of plaintext to Rehash if the encoded password is not current.
+func (a *Authenticator) rehash(user, plaintext, encoded string) error {
+	isCurrent, err := mcf.IsCurrent(encoded)
+	if err != nil || isCurrent {
+		return err
+	}
+
+	encoded, err = mcf.Create(plaintext)
+	if err != nil {
+		return err
+	}
+	a.Rehash(user, encoded)
+	return nil
+}
@@ -0,0 +1,273 @@
+package mcfhttp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gyepisam/mcf"
+	"github.com/gyepisam/mcf/encoder"
+	"github.com/gyepisam/mcf/pbkdf2"
+)
+
+func init() {
+	// Keep verification fast.
+	config := pbkdf2.GetConfig()
+	config.Iterations = 10
+	if err := pbkdf2.SetConfig(config); err != nil {
+		panic(err)
+	}
+}
+
+var hello = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	fmt.Fprintf(w, "hello %s", User(r))
+})
+
+type store struct {
+	sync.Mutex
+	users   map[string]string
+	lookups int
+}
+
+func newStore(t *testing.T, users map[string]string) *store {
+	s := &store{users: map[string]string{}}
+	for user, plain := range users {
+		encoded, err := mcf.Create(plain)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.users[user] = encoded
+	}
+	return s
+}
+
+func (s *store) lookup(user string) (string, error) {
+	s.Lock()
+	defer s.Unlock()
+	s.lookups++
+	if user == "broken" {
+		return "", fmt.Errorf("database is down")
+	}
+	encoded, ok := s.users[user]
+	if !ok {
+		return "", ErrNoUser
+	}
+	return encoded, nil
+}
+
+func get(t *testing.T, h http.Handler, user, plain string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest("GET", "/", nil)
+	if user != "" {
+		r.SetBasicAuth(user, plain)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestBasicAuth(t *testing.T) {
+	s := newStore(t, map[string]string{"alice": "wonderland"})
+	h := BasicAuth(`The "Internal" Realm`, s.lookup, hello)
+
+	w := get(t, h, "alice", "wonderland")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	if body, _ := io.ReadAll(w.Body); string(body) != "hello alice" {
+		t.Errorf("unexpected body: %q", body)
+	}
+
+	for _, v := range []struct {
+		user, plain string
+		code        int
+	}{
+		{"", "", http.StatusUnauthorized},
+		{"alice", "wrong", http.StatusUnauthorized},
+		{"bob", "wonderland", http.StatusUnauthorized},
+		{"broken", "x", http.StatusInternalServerError},
+	} {
+		w := get(t, h, v.user, v.plain)
+		if w.Code != v.code {
+			t.Errorf("%q: expected status %d, got %d", v.user, v.code, w.Code)
+		}
+		if v.code != http.StatusUnauthorized {
+			continue
+		}
+		want := `Basic realm="The \"Internal\" Realm", charset="UTF-8"`
+		if got := w.Header().Get("WWW-Authenticate"); got != want {
+			t.Errorf("%q: expected challenge %s, got %s", v.user, want, got)
+		}
+	}
+
+	if s.lookups != 4 {
+		t.Errorf("expected 4 lookups, got %d", s.lookups)
+	}
+}
+
+func TestUnknownUser(t *testing.T) {
+	defer mcf.AddPreCreateHook(func(plaintext []byte) error {
+		return errors.New("refused")
+	})()
+
+	lookup := func(user string) (string, error) {
+		return "", fmt.Errorf("users: %q: %w", user, ErrNoUser)
+	}
+	a := &Authenticator{Realm: "test", Lookup: lookup}
+	if w := get(t, a.Wrap(hello), "nobody", "x"); w.Code != http.StatusUnauthorized {
+		t.Errorf("expected status 401, got %d", w.Code)
+	}
+
+	// The dummy password, which pre-create hooks cannot refuse, is verified like any other.
+	if _, err := mcf.IsCurrent(a.dummy); err != nil {
+		t.Errorf("dummy password %q: %s", a.dummy, err)
+	}
+}
+
+func TestRejectedScheme(t *testing.T) {
+	s := newStore(t, map[string]string{"alice": "wonderland"})
+
+	encoding := mcf.Default()
+	mcf.SetLifecycle(mcf.Lifecycle{Encoding: encoding,
+		Stages: []mcf.Stage{{State: mcf.StateRejected, From: time.Now().Add(-time.Hour)}}})
+	defer mcf.SetLifecycle(mcf.Lifecycle{Encoding: encoding})
+
+	h := BasicAuth("test", s.lookup, hello)
+	if w := get(t, h, "alice", "wonderland"); w.Code != http.StatusUnauthorized {
+		t.Errorf("expected status 401, got %d", w.Code)
+	}
+}
+
+// noCreate is an encoder whose Create fails.
+type noCreate struct {
+	encoder.Encoder
+}
+
+func (noCreate) Create(plaintext []byte) ([]byte, error) {
+	return nil, errors.New("Create is disabled")
+}
+
+func TestNoDummy(t *testing.T) {
+	s := newStore(t, map[string]string{"alice": "wonderland"})
+
+	enc := mcf.Registered(mcf.PBKDF2)
+	if err := mcf.Register(mcf.PBKDF2, noCreate{enc}); err != nil {
+		t.Fatal(err)
+	}
+	defer mcf.Register(mcf.PBKDF2, enc)
+
+	h := BasicAuth("test", s.lookup, hello)
+	for _, user := range []string{"alice", "bob"} {
+		if w := get(t, h, user, "wonderland"); w.Code != http.StatusInternalServerError {
+			t.Errorf("%q: expected status 500, got %d", user, w.Code)
+		}
+	}
+}
+
+func TestRehash(t *testing.T) {
+	config := pbkdf2.GetConfig()
+	config.Iterations = 5
+	if err := pbkdf2.SetConfig(config); err != nil {
+		t.Fatal(err)
+	}
+
+	s := newStore(t, map[string]string{"alice": "wonderland"})
+	old := s.users["alice"]
+
+	config.Iterations = 10
+	if err := pbkdf2.SetConfig(config); err != nil {
+		t.Fatal(err)
+	}
+
+	var rehashed int
+	a := &Authenticator{
+		Realm:  "test",
+		Lookup: s.lookup,
+		Rehash: func(user, encoded string) {
+			rehashed++
+			s.users[user] = encoded
+		},
+	}
+	h := a.Wrap(hello)
+
+	for i := 0; i < 2; i++ {
+		if w := get(t, h, "alice", "wonderland"); w.Code != http.StatusOK {
+			t.Fatalf("%d: expected status 200, got %d", i, w.Code)
+		}
+	}
+
+	if rehashed != 1 {
+		t.Errorf("expected 1 rehash, got %d", rehashed)
+	}
+	if s.users["alice"] == old {
+		t.Errorf("stored password was not replaced")
+	}
+	if isCurrent, err := mcf.IsCurrent(s.users["alice"]); err != nil || !isCurrent {
+		t.Errorf("IsCurrent: expected true, nil; got %t, %v", isCurrent, err)
+	}
+}
+
+func TestRehashError(t *testing.T) {
+	config := pbkdf2.GetConfig()
+	config.Iterations = 5
+	if err := pbkdf2.SetConfig(config); err != nil {
+		t.Fatal(err)
+	}
+	s := newStore(t, map[string]string{"alice": "wonderland"})
+	config.Iterations = 10
+	if err := pbkdf2.SetConfig(config); err != nil {
+		t.Fatal(err)
+	}
+
+	a := &Authenticator{Realm: "test", Lookup: s.lookup}
+
+	refused := errors.New("refused")
+	defer mcf.AddPreCreateHook(func(plaintext []byte) error { return refused })()
+
+	var rehashErr error
+	a.Rehash = func(user, encoded string) { t.Errorf("Rehash: unexpected call") }
+	a.RehashError = func(user string, err error) { rehashErr = err }
+	if w := get(t, a.Wrap(hello), "alice", "wonderland"); w.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d", w.Code)
+	}
+	if rehashErr != refused {
+		t.Errorf("RehashError: expected %v, got %v", refused, rehashErr)
+	}
+}
+
+func TestMaxConcurrent(t *testing.T) {
+	var inflight, peak int32
+	lookup := func(user string) (string, error) {
+		n := atomic.AddInt32(&inflight, 1)
+		defer atomic.AddInt32(&inflight, -1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		return "", ErrNoUser
+	}
+
+	h := (&Authenticator{Realm: "test", Lookup: lookup, MaxConcurrent: 2}).Wrap(hello)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			get(t, h, "nobody", "x")
+		}()
+	}
+	wg.Wait()
+
+	if peak > 2 {
+		t.Errorf("expected at most 2 concurrent verifications, got %d", peak)
+	}
+}
@@ -0,0 +1,187 @@
+// Copyright 2014 Gyepi Sam. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mcftest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/gyepisam/mcf"
+	"github.com/gyepisam/mcf/encoder"
+)
+
+// Passwords used by Conformance.
+var passwords = []string{
+	"password",
+	"",
+	"correct horse battery staple",
+	"pässwörd ☃",
+	"\x00\xff$:{}",
+}
+
+// Conformance checks that enc behaves as an encoder.Encoder should. It checks that
+//
+//   - encoded passwords are prefixed with "$" and the id,
+//   - Verify accepts the password given to Create and rejects others, without error,
+//   - IsCurrent accepts the output of Create,
+//   - malformed input is rejected, without panicking, and any error wraps one of
+//     mcf.ErrMalformedHash, mcf.ErrInvalidParams or mcf.ErrUnsupportedVersion.
+//
+// If weaker is not nil, it must be the same encoder with a weaker configuration, and
+// Conformance also checks that each encoder verifies the other's passwords and that
+// IsCurrent is monotonic: enc considers passwords created by weaker to be out of date,
+// but weaker considers passwords created by enc to be current.
+//
+// Expensive encoders should be given cheap configurations.
+func Conformance(t *testing.T, enc, weaker encoder.Encoder) {
+	t.Helper()
+
+	id := enc.Id()
+	if len(id) == 0 {
+		t.Fatal("empty id")
+	}
+	prefix := append([]byte{'$'}, id...)
+
+	var sample []byte
+
+	for _, plaintext := range passwords {
+		encoded, err := enc.Create([]byte(plaintext))
+		if err != nil {
+			t.Errorf("Create %q: %s", plaintext, err)
+			continue
+		}
+		if !bytes.HasPrefix(encoded, prefix) {
+			t.Errorf("Create %q: %q does not begin with %q", plaintext, encoded, prefix)
+		}
+		if sample == nil {
+			sample = encoded
+		}
+
+		checkVerify(t, enc, plaintext, encoded, true)
+		checkVerify(t, enc, plaintext+"x", encoded, false)
+		checkVerify(t, enc, "wrong", encoded, false)
+
+		if isCurrent, err := enc.IsCurrent(encoded); err != nil || !isCurrent {
+			t.Errorf("IsCurrent %q: got (%t, %v), expected (true, nil)", encoded, isCurrent, err)
+		}
+	}
+
+	if weaker != nil {
+		checkWeaker(t, enc, weaker)
+	}
+
+	if sample != nil {
+		checkMalformed(t, enc, prefix, sample)
+	}
+}
+
+func checkVerify(t *testing.T, enc encoder.Encoder, plaintext string, encoded []byte, want bool) {
+	t.Helper()
+	isValid, err := enc.Verify([]byte(plaintext), encoded)
+	if err != nil || isValid != want {
+		t.Errorf("Verify %q, %q: got (%t, %v), expected (%t, nil)", plaintext, encoded, isValid, err, want)
+	}
+}
+
+func checkWeaker(t *testing.T, enc, weaker encoder.Encoder) {
+	t.Helper()
+
+	plaintext := passwords[0]
+
+	old, err := weaker.Create([]byte(plaintext))
+	if err != nil {
+		t.Errorf("weaker: Create: %s", err)
+		return
+	}
+	cur, err := enc.Create([]byte(plaintext))
+	if err != nil {
+		t.Errorf("Create: %s", err)
+		return
+	}
+
+	checkVerify(t, enc, plaintext, old, true)
+	checkVerify(t, weaker, plaintext, cur, true)
+
+	if isCurrent, err := enc.IsCurrent(old); err != nil || isCurrent {
+		t.Errorf("IsCurrent of weaker encoding %q: got (%t, %v), expected (false, nil)", old, isCurrent, err)
+	}
+	if isCurrent, err := weaker.IsCurrent(cur); err != nil || !isCurrent {
+		t.Errorf("weaker: IsCurrent of stronger encoding %q: got (%t, %v), expected (true, nil)", cur, isCurrent, err)
+	}
+}
+
+// malformed produces variations of a valid encoded password that are unlikely to be valid.
+func malformed(prefix, sample []byte) [][]byte {
+	list := [][]byte{
+		nil,
+		[]byte("$"),
+		prefix,
+		append(append([]byte(nil), prefix...), '$'),
+		append(append([]byte(nil), prefix...), "$$$$"...),
+		append(append([]byte(nil), sample...), '$'),
+		append(append([]byte(nil), sample...), "$extra"...),
+		bytes.Replace(sample, []byte("$"), []byte("$$"), -1),
+	}
+
+	for n := len(prefix) + 1; n < len(sample); n += 1 + len(sample)/16 {
+		list = append(list, sample[:n:n])
+	}
+
+	for _, c := range []byte{'!', '$', 0, 0xff} {
+		for i := len(prefix) + 1; i < len(sample); i += 1 + len(sample)/8 {
+			b := append([]byte(nil), sample...)
+			b[i] = c
+			list = append(list, b)
+		}
+	}
+
+	return list
+}
+
+func checkMalformed(t *testing.T, enc encoder.Encoder, prefix, sample []byte) {
+	t.Helper()
+
+	for _, encoded := range malformed(prefix, sample) {
+		if bytes.Equal(encoded, sample) {
+			continue
+		}
+
+		err := noPanic(func() error {
+			isValid, err := enc.Verify([]byte(passwords[0]), encoded)
+			if isValid {
+				return errors.New("Verify succeeded")
+			}
+			if err != nil && !classified(err) {
+				return fmt.Errorf("Verify: unclassified error: %w", err)
+			}
+
+			_, err = enc.IsCurrent(encoded)
+			if err != nil && !classified(err) {
+				return fmt.Errorf("IsCurrent: unclassified error: %w", err)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Errorf("malformed input %q: %s", encoded, err)
+		}
+	}
+}
+
+func classified(err error) bool {
+	return errors.Is(err, mcf.ErrMalformedHash) ||
+		errors.Is(err, mcf.ErrInvalidParams) ||
+		errors.Is(err, mcf.ErrUnsupportedVersion)
+}
+
+func noPanic(fn func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return fn()
+}
@@ -0,0 +1,243 @@
+// Copyright 2014 Gyepi Sam. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Package mcftest provides a fast, deterministic encoder and helpers for testing code that uses mcf.
+
+Real password hashes are deliberately slow, which makes tests that create users slow too.
+Importing this package registers an encoder for the MCFTEST encoding that is cheap to compute.
+Use makes it the default for the duration of a test:
+
+	func TestSignup(t *testing.T) {
+		mcftest.Use(t)
+		// mcf.Create now produces $mcftest$... passwords.
+	}
+
+The test encoder offers no security and must never be used outside of tests.
+
+Conformance runs a set of checks that any encoder.Encoder implementation should pass.
+*/
+package mcftest
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/gyepisam/mcf"
+	"github.com/gyepisam/mcf/bridge"
+	"github.com/gyepisam/mcf/encoder"
+)
+
+// maxRounds limits the work done for an encoded password.
+const maxRounds = 1 << 16
+
+// maxSaltLen limits the length of the salt.
+const maxSaltLen = 64
+
+// Default values.
+const (
+	DefaultRounds  = 1
+	DefaultSaltLen = 8
+	DefaultKeyLen  = sha256.Size
+)
+
+// DefaultSalt is the salt produced by the default SaltMine.
+var DefaultSalt = []byte("mcftest!")
+
+// Config contains the parameters of the test encoder.
+// Use GetConfig and SetConfig to change them.
+type Config struct {
+	Rounds  int // Number of times the hash is applied.
+	SaltLen int // Length of salt in bytes. At most 64.
+	KeyLen  int // Length of key in bytes. At most sha256.Size.
+
+	// SaltMine is the source of salt. If nil, the package SaltMine is used.
+	SaltMine mcf.SaltMiner
+}
+
+// SaltMine is the source of salt. It defaults to FixedSalt(DefaultSalt), so that the encoder is deterministic:
+// the same password always produces the same encoding. Set it to nil to use random salt.
+var SaltMine mcf.SaltMiner = FixedSalt(DefaultSalt)
+
+// GetConfig returns the default configuration.
+func GetConfig() Config {
+	return Config{
+		Rounds:  DefaultRounds,
+		SaltLen: DefaultSaltLen,
+		KeyLen:  DefaultKeyLen,
+	}
+}
+
+// SetConfig establishes a new configuration for the test encoder.
+// Use Restore to undo the change at the end of a test.
+func SetConfig(config Config) error {
+	if err := config.validate(); err != nil {
+		return err
+	}
+	return register(config)
+}
+
+func newEncoder(config Config) encoder.Encoder {
+	fn := func() bridge.Implementer {
+		c := config
+		return &c
+	}
+	return bridge.New([]byte("mcftest"), fn)
+}
+
+func register(config Config) error {
+	return mcf.Register(mcf.MCFTEST, newEncoder(config))
+}
+
+func init() {
+	if err := register(GetConfig()); err != nil {
+		panic(err)
+	}
+	mcf.RegisterFactory(mcf.MCFTEST, mcf.NewFactory((*Config).validate, newEncoder))
+}
+
+func (c *Config) validate() error {
+	if c.Rounds < 1 || c.Rounds > maxRounds || c.SaltLen < 0 || c.SaltLen > maxSaltLen || c.KeyLen < 1 || c.KeyLen > sha256.Size {
+		return fmt.Errorf("%w: mcftest: rounds=%d, saltlen=%d, keylen=%d",
+			encoder.ErrInvalidParams, c.Rounds, c.SaltLen, c.KeyLen)
+	}
+	return nil
+}
+
+// Keep these together.
+const format = "rounds=%d,keylen=%d"
+
+// Params encodes the parameters in a string.
+func (c *Config) Params() string {
+	return fmt.Sprintf(format, c.Rounds, c.KeyLen)
+}
+
+// SetParams restores parameters encoded by Params.
+func (c *Config) SetParams(s string) error {
+	if _, err := fmt.Sscanf(s, format, &c.Rounds, &c.KeyLen); err != nil {
+		return fmt.Errorf("%w: mcftest: %s", encoder.ErrInvalidParams, err)
+	}
+	return c.validate()
+}
+
+// Salt produces SaltLen bytes from the configured SaltMine.
+func (c *Config) Salt() ([]byte, error) {
+	miner := c.SaltMine
+	if miner == nil {
+		miner = SaltMine
+	}
+	salt, err := mcf.Salt(c.SaltLen, miner)
+	if err != nil {
+		return nil, fmt.Errorf("mcftest: salt: %w", err)
+	}
+	return salt, nil
+}
+
+// Key returns the first KeyLen bytes of SHA-256 applied Rounds times to salt and password.
+func (c *Config) Key(plaintext, salt []byte) ([]byte, error) {
+	h := sha256.New()
+	h.Write(salt)
+	h.Write(plaintext)
+	sum := h.Sum(nil)
+	for i := 1; i < c.Rounds; i++ {
+		next := sha256.Sum256(sum)
+		sum = next[:]
+	}
+	return sum[:c.KeyLen], nil
+}
+
+// SetKeyLen sets the key length, which must be between 1 and sha256.Size.
+// It implements bridge.KeySizer.
+func (c *Config) SetKeyLen(n int) error {
+	c.KeyLen = n
+	return c.validate()
+}
+
+// AtLeast returns true if the parameters are at least as large as those of the current configuration.
+func (c *Config) AtLeast(currentImp bridge.Implementer) bool {
+	current, ok := currentImp.(*Config)
+	return ok && c.Rounds >= current.Rounds && c.KeyLen >= current.KeyLen
+}
+
+// FixedSalt returns a SaltMiner that always produces the same salt, repeating salt as often as necessary.
+// It is useful for reproducible tests and must never be used otherwise.
+func FixedSalt(salt []byte) mcf.SaltMiner {
+	salt = append([]byte(nil), salt...)
+	return func(n int) ([]byte, error) {
+		if len(salt) == 0 && n > 0 {
+			return nil, errors.New("mcftest: empty fixed salt")
+		}
+		b := make([]byte, n)
+		for i := range b {
+			b[i] = salt[i%len(salt)]
+		}
+		return b, nil
+	}
+}
+
+// Restore arranges for the default encoding, the lifecycles, and the encoders registered for the given encodings,
+// to be restored to their present state when the test finishes.
+// Call it before changing the configuration of an encoder or the default encoding:
+//
+//	mcftest.Restore(t, mcf.SCRYPT)
+//	config := scrypt.GetConfig()
+//	config.N = 16
+//	scrypt.SetConfig(config)
+//
+// Tests that use Restore must not run in parallel with other tests that use mcf.
+func Restore(t testing.TB, encodings ...mcf.Encoding) {
+	t.Helper()
+
+	def := mcf.Default()
+	cycles := mcf.Lifecycles()
+	saved := make([]encoder.Encoder, len(encodings))
+	for i, encoding := range encodings {
+		saved[i] = mcf.Registered(encoding)
+	}
+
+	t.Cleanup(func() {
+		for i, enc := range saved {
+			if enc == nil {
+				continue
+			}
+			if err := mcf.Register(encodings[i], enc); err != nil {
+				t.Errorf("mcftest: restoring %s: %s", encodings[i], err)
+			}
+		}
+		for _, l := range mcf.Lifecycles() {
+			mcf.SetLifecycle(mcf.Lifecycle{Encoding: l.Encoding, Params: l.Params})
+		}
+		for _, l := range cycles {
+			if err := mcf.SetLifecycle(l); err != nil {
+				t.Errorf("mcftest: restoring lifecycle of %s: %s", l.Encoding, err)
+			}
+		}
+		if def.IsValid() {
+			if err := mcf.SetDefault(def); err != nil {
+				t.Errorf("mcftest: restoring default %s: %s", def, err)
+			}
+		}
+	})
+}
+
+// SetDefault makes encoding the default until the test finishes.
+func SetDefault(t testing.TB, encoding mcf.Encoding) {
+	t.Helper()
+	Restore(t)
+	if err := mcf.SetDefault(encoding); err != nil {
+		t.Fatalf("mcftest: %s", err)
+	}
+}
+
+// Use makes the test encoder, with its default configuration, the default encoder until the test finishes.
+func Use(t testing.TB) {
+	t.Helper()
+	Restore(t, mcf.MCFTEST)
+	if err := SetConfig(GetConfig()); err != nil {
+		t.Fatalf("mcftest: %s", err)
+	}
+	SetDefault(t, mcf.MCFTEST)
+}
@@ -0,0 +1,125 @@
+package mcftest
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gyepisam/mcf"
+)
+
+func TestConformance(t *testing.T) {
+	Restore(t, mcf.MCFTEST)
+
+	weak := mcf.Registered(mcf.MCFTEST)
+
+	config := GetConfig()
+	config.Rounds = 3
+	if err := SetConfig(config); err != nil {
+		t.Fatal(err)
+	}
+
+	Conformance(t, mcf.Registered(mcf.MCFTEST), weak)
+}
+
+func TestUse(t *testing.T) {
+	before := mcf.Default()
+
+	t.Run("use", func(t *testing.T) {
+		Use(t)
+
+		if got := mcf.Default(); got != mcf.MCFTEST {
+			t.Fatalf("default: got %s, expected %s", got, mcf.MCFTEST)
+		}
+
+		a, err := mcf.Create("password")
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := mcf.Create("password")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if a != b {
+			t.Errorf("not deterministic: %q != %q", a, b)
+		}
+
+		isValid, err := mcf.Verify("password", a)
+		if err != nil || !isValid {
+			t.Errorf("Verify: got (%t, %v), expected (true, nil)", isValid, err)
+		}
+	})
+
+	if got := mcf.Default(); got != before {
+		t.Errorf("default not restored: got %s, expected %s", got, before)
+	}
+}
+
+func TestRestore(t *testing.T) {
+	before := mcf.Registered(mcf.MCFTEST)
+
+	t.Run("change", func(t *testing.T) {
+		Restore(t, mcf.MCFTEST)
+		if err := SetConfig(Config{Rounds: 2, SaltLen: 4, KeyLen: 4}); err != nil {
+			t.Fatal(err)
+		}
+		if mcf.Registered(mcf.MCFTEST) == before {
+			t.Fatal("SetConfig did not register a new encoder")
+		}
+	})
+
+	if mcf.Registered(mcf.MCFTEST) != before {
+		t.Error("encoder not restored")
+	}
+}
+
+func TestSetConfig(t *testing.T) {
+	for _, c := range []Config{
+		{Rounds: 0, SaltLen: 8, KeyLen: 8},
+		{Rounds: 1, SaltLen: -1, KeyLen: 8},
+		{Rounds: 1, SaltLen: 8, KeyLen: 0},
+		{Rounds: 1, SaltLen: 8, KeyLen: 33},
+	} {
+		if err := SetConfig(c); err == nil {
+			t.Errorf("SetConfig(%+v): expected an error", c)
+		}
+	}
+}
+
+func TestFixedSalt(t *testing.T) {
+	miner := FixedSalt([]byte("abc"))
+	b, err := miner(7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []byte("abcabca"); !bytes.Equal(b, want) {
+		t.Errorf("got %q, expected %q", b, want)
+	}
+
+	if _, err := FixedSalt(nil)(4); err == nil {
+		t.Error("empty salt: expected an error")
+	}
+
+	salt, err := mcf.Salt(5, miner)
+	if err != nil || len(salt) != 5 {
+		t.Errorf("mcf.Salt: got (%q, %v)", salt, err)
+	}
+}
+
+func TestLoadVectors(t *testing.T) {
+	dir := t.TempDir()
+
+	for name, content := range map[string]string{
+		"bad.json":     `{"vectors": [`,
+		"missing.json": `{"vectors": [{"scheme": "mcftest", "password": "password"}]}`,
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadVectors(path); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
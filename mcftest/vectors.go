@@ -0,0 +1,182 @@
+// Copyright 2014 Gyepi Sam. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mcftest
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gyepisam/mcf"
+	"github.com/gyepisam/mcf/encoder"
+)
+
+// A Vector is a known answer test for an encoder.
+//
+// Vectors are stored in JSON files of the form
+//
+//	{
+//		"description": "where the vectors come from",
+//		"vectors": [
+//			{"scheme": "scrypt", "params": "KeyLen=64,N=16,R=1,P=1", "salt": "", "password": "", "hash": "$scrypt$..."},
+//			...
+//		]
+//	}
+//
+// See the files in github.com/gyepisam/mcf/test/vectors.
+type Vector struct {
+	Scheme   string `json:"scheme"`   // The name of the encoding, as produced by mcf.Encoding.String.
+	Params   string `json:"params"`   // The parameters, as they appear in Hash. Optional; see RunVectors.
+	Salt     string `json:"salt"`     // The salt, as it appears in Hash. Given with Params.
+	Password string `json:"password"` // The plaintext password.
+	Hash     string `json:"hash"`     // The expected encoded password.
+	Source   string `json:"source"`   // Where the vector comes from. Optional.
+}
+
+// A VectorFile is the content of a vector file.
+type VectorFile struct {
+	Description string   `json:"description"`
+	Vectors     []Vector `json:"vectors"`
+}
+
+// LoadVectors reads the vectors in the named file.
+func LoadVectors(path string) ([]Vector, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var f VectorFile
+	if err := json.Unmarshal(b, &f); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	for i, v := range f.Vectors {
+		if v.Scheme == "" || v.Hash == "" {
+			return nil, fmt.Errorf("%s: vector %d: scheme and hash are required", path, i)
+		}
+	}
+
+	return f.Vectors, nil
+}
+
+// encodingNamed returns the encoding with the given name.
+func encodingNamed(name string) (mcf.Encoding, bool) {
+	for e := mcf.Encoding(0); e.IsValid(); e++ {
+		if e.String() == name {
+			return e, true
+		}
+	}
+	return 0, false
+}
+
+// RunVectors checks each vector against the encoder registered for its scheme.
+// The password must verify against the hash, a different password must not,
+// and IsCurrent must accept the hash. Vectors for unregistered schemes are skipped.
+//
+// If a vector has Params, the hash must begin with $id$params$salt. If the encoder
+// also implements encoder.KeyDeriver, the hash is rebuilt from the parameters, salt
+// and password with mcf.DeriveKey, and must be the same, so Params and Salt must then
+// be in the form that the encoder writes. Vectors of other forms should omit them.
+func RunVectors(t *testing.T, vectors []Vector) {
+	t.Helper()
+
+	for i, v := range vectors {
+		v := v
+		t.Run(fmt.Sprintf("%s/%d", v.Scheme, i), func(t *testing.T) {
+			encoding, ok := encodingNamed(v.Scheme)
+			if !ok {
+				t.Fatalf("unknown scheme %q", v.Scheme)
+			}
+			enc := mcf.Registered(encoding)
+			if enc == nil {
+				t.Skipf("%s is not registered", encoding)
+			}
+
+			if v.Params != "" {
+				prefix := "$" + string(enc.Id()) + "$" + v.Params + "$" + v.Salt
+				if !strings.HasPrefix(v.Hash, prefix) {
+					t.Fatalf("hash %q does not match params and salt %q", v.Hash, prefix)
+				}
+				if _, ok := enc.(encoder.KeyDeriver); ok {
+					rebuild(t, v, prefix)
+				}
+			}
+
+			isValid, err := enc.Verify([]byte(v.Password), []byte(v.Hash))
+			if err != nil || !isValid {
+				t.Errorf("Verify %q, %q: got (%t, %v), expected (true, nil)", v.Password, v.Hash, isValid, err)
+			}
+
+			isValid, err = enc.Verify([]byte("x"+v.Password), []byte(v.Hash))
+			if err != nil || isValid {
+				t.Errorf("Verify wrong password, %q: got (%t, %v), expected (false, nil)", v.Hash, isValid, err)
+			}
+
+			if _, err := enc.IsCurrent([]byte(v.Hash)); err != nil {
+				t.Errorf("IsCurrent %q: %s", v.Hash, err)
+			}
+		})
+	}
+}
+
+// rebuild derives the key of a vector from the record prefix, which holds its parameters and salt,
+// and checks that the record and key form the hash.
+func rebuild(t *testing.T, v Vector, prefix string) {
+	t.Helper()
+
+	// The salt is passed separately, since DeriveKey replaces a missing one.
+	salt, err := base64.StdEncoding.DecodeString(v.Salt)
+	if err != nil {
+		t.Fatalf("salt %q: %s", v.Salt, err)
+	}
+	key, record, err := mcf.DeriveKey(prefix, []byte(v.Password), salt, 0)
+	if err != nil {
+		t.Fatalf("DeriveKey %q: %s", prefix, err)
+	}
+	if hash := record + "$" + base64.StdEncoding.EncodeToString(key); hash != v.Hash {
+		t.Errorf("hash rebuilt from params and salt: got %q, expected %q", hash, v.Hash)
+	}
+}
+
+// RunVectorFiles loads the vector files that match pattern, as for filepath.Glob, and runs them.
+// It also reports an error for each registered encoder that has no vectors, so that every encoder
+// is checked against an independent implementation.
+func RunVectorFiles(t *testing.T, pattern string) {
+	t.Helper()
+
+	files, err := filepath.Glob(pattern)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatalf("no vector files match %q", pattern)
+	}
+
+	var all []Vector
+	for _, file := range files {
+		vectors, err := LoadVectors(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		all = append(all, vectors...)
+	}
+
+	covered := map[string]bool{}
+	for _, v := range all {
+		covered[v.Scheme] = true
+	}
+	for e := mcf.Encoding(0); e.IsValid(); e++ {
+		if mcf.Registered(e) != nil && !covered[e.String()] {
+			t.Errorf("no vectors for registered encoding %s", e)
+		}
+	}
+
+	RunVectors(t, all)
+}
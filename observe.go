@@ -0,0 +1,166 @@
+// Copyright 2014 Gyepi Sam. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mcf
+
+import (
+	"errors"
+	"strconv"
+	"time"
+)
+
+// An Op is an operation reported to an Observer.
+type Op uint8
+
+// Operations.
+const (
+	OpCreate    Op = iota // Create
+	OpVerify              // Verify
+	OpIsCurrent           // IsCurrent
+)
+
+func (o Op) String() string {
+	switch o {
+	case OpCreate:
+		return "create"
+	case OpVerify:
+		return "verify"
+	case OpIsCurrent:
+		return "iscurrent"
+	}
+	return "unknown"
+}
+
+// An Outcome classifies the result of an operation.
+type Outcome uint8
+
+// Outcomes.
+const (
+	OutcomeOK                 Outcome = iota // Create or IsCurrent succeeded.
+	OutcomeMatch                             // Verify: the password matched.
+	OutcomeMismatch                          // Verify: the password did not match.
+	OutcomeRefused                           // Create: a PreCreateHook refused the password.
+	OutcomeUnknownScheme                     // The encoded password does not belong to any registered encoder.
+	OutcomeError                             // The encoder returned an error not classified below.
+	OutcomeMalformed                         // The encoder returned ErrMalformedHash.
+	OutcomeInvalidParams                     // The encoder returned ErrInvalidParams.
+	OutcomeUnsupportedVersion                // The encoder returned ErrUnsupportedVersion.
+	OutcomeRejected                          // Verify: the scheme is rejected by a Lifecycle.
+)
+
+func (o Outcome) String() string {
+	switch o {
+	case OutcomeOK:
+		return "ok"
+	case OutcomeMatch:
+		return "match"
+	case OutcomeMismatch:
+		return "mismatch"
+	case OutcomeRefused:
+		return "refused"
+	case OutcomeUnknownScheme:
+		return "unknown_scheme"
+	case OutcomeError:
+		return "error"
+	case OutcomeMalformed:
+		return "malformed"
+	case OutcomeInvalidParams:
+		return "invalid_params"
+	case OutcomeUnsupportedVersion:
+		return "unsupported_version"
+	case OutcomeRejected:
+		return "rejected"
+	}
+	return "unknown"
+}
+
+// An Event describes a single call to Create, Verify or IsCurrent.
+// It never contains the plaintext or encoded password.
+type Event struct {
+	Op       Op
+	Encoding Encoding // The encoding used, or one for which IsValid is false if none was found.
+	Id       string   // The encoder id, such as "scrypt" or "2a". Empty if no encoder was found.
+	Duration time.Duration
+	Outcome  Outcome
+
+	// Upgrade is true when IsCurrent reports that the password is out of date,
+	// which is when an application replaces it.
+	Upgrade bool
+}
+
+// Labels returns the event's dimensions as a map,
+// with the keys "op", "encoding", "id", "outcome" and "upgrade".
+// It is intended for metric systems that use labels or attributes, such as Prometheus or OpenTelemetry,
+// and allows them to be wired up without mcf depending on them. For instance:
+//
+//	mcf.AddObserver(mcf.ObserverFunc(func(e mcf.Event) {
+//		durations.With(e.Labels()).Observe(e.Duration.Seconds())
+//	}))
+func (e Event) Labels() map[string]string {
+	return map[string]string{
+		"op":       e.Op.String(),
+		"encoding": e.Encoding.String(),
+		"id":       e.Id,
+		"outcome":  e.Outcome.String(),
+		"upgrade":  strconv.FormatBool(e.Upgrade),
+	}
+}
+
+// An Observer is notified after every Create, Verify and IsCurrent call.
+// Observe is called synchronously, possibly from multiple goroutines, and should return quickly.
+// See github.com/gyepisam/mcf/mcfexpvar for an implementation.
+type Observer interface {
+	Observe(Event)
+}
+
+// ObserverFunc adapts an ordinary function to the Observer interface.
+type ObserverFunc func(Event)
+
+// Observe calls f(e).
+func (f ObserverFunc) Observe(e Event) { f(e) }
+
+var observers []Observer
+
+// errorOutcome classifies an error returned by an encoder.
+func errorOutcome(err error) Outcome {
+	switch {
+	case errors.Is(err, ErrMalformedHash):
+		return OutcomeMalformed
+	case errors.Is(err, ErrInvalidParams):
+		return OutcomeInvalidParams
+	case errors.Is(err, ErrUnsupportedVersion):
+		return OutcomeUnsupportedVersion
+	case errors.Is(err, ErrUnknownScheme):
+		return OutcomeUnknownScheme
+	}
+	return OutcomeError
+}
+
+// AddObserver adds an observer to the list that is notified of each operation.
+// Like Register, it is meant to be called during initialization.
+func AddObserver(o Observer) {
+	observers = append(observers, o)
+}
+
+// observe reports an operation, started at start, to the observers, if any.
+func observe(op Op, encoding Encoding, inst *instance, start time.Time, outcome Outcome, upgrade bool) {
+	if len(observers) == 0 {
+		return
+	}
+
+	e := Event{
+		Op:       op,
+		Encoding: encoding,
+		Duration: time.Since(start),
+		Outcome:  outcome,
+		Upgrade:  upgrade,
+	}
+	if inst != nil {
+		e.Id = string(inst.id)
+	}
+
+	for _, o := range observers {
+		o.Observe(e)
+	}
+}
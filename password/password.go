@@ -11,6 +11,8 @@ import (
 	"encoding/base64"
 	"encoding/hex"
 	"fmt"
+
+	"github.com/gyepisam/mcf/encoder"
 )
 
 //This separates password fields
@@ -39,6 +41,7 @@ const fieldCount = 4
 
 //ErrorInputPassword is returned for input passwords that fail validation.
 //The struct can be examined for a possible solution.
+//It wraps encoder.ErrMalformedHash.
 type ErrorInputPassword struct {
 	Msg      string //Error message
 	Password string //Input password
@@ -48,6 +51,11 @@ func (e ErrorInputPassword) Error() string {
 	return e.Msg
 }
 
+// Unwrap returns encoder.ErrMalformedHash.
+func (e ErrorInputPassword) Unwrap() error {
+	return encoder.ErrMalformedHash
+}
+
 // New returns a Passwd struct initialized with the default encoders.
 func New(name []byte) *Passwd {
 	return &Passwd{Name: name, Decoder: decode, Encoder: EncodeBase64}
@@ -88,10 +96,13 @@ func (p *Passwd) Parse(encoded []byte) (err error) {
 
 	p.Salt, err = p.Decoder(parts[2])
 	if err != nil {
-		return
+		return inputErr("invalid salt: %s", err)
 	}
 
 	p.Key, err = p.Decoder(parts[3])
+	if err != nil {
+		return inputErr("invalid key: %s", err)
+	}
 
 	return
 }
@@ -100,7 +111,7 @@ func (p *Passwd) Parse(encoded []byte) (err error) {
 // The output can be stored and later used to verify the password.
 func (p *Passwd) Bytes() []byte {
 
-	in := [][]byte{p.Name, p.Params, p.Encoder(p.Salt), p.Encoder(p.Key)}
+	in := [][]byte{p.Name, p.Params, p.encode(p.Salt), p.encode(p.Key)}
 
 	n := 0
 	for _, b := range in {
@@ -121,6 +132,19 @@ func (p *Passwd) Bytes() []byte {
 	return out
 }
 
+// encode serializes b with Encoder. The default Decoder cannot distinguish between Base64 and Hex
+// when a Base64 encoding happens to consist entirely of hex digits, as does "AAAA", so in the rare
+// case that the encoding does not decode to b, Hex is used instead, which always does.
+func (p *Passwd) encode(b []byte) []byte {
+	out := p.Encoder(b)
+	if p.Decoder != nil {
+		if dec, err := p.Decoder(out); err != nil || !bytes.Equal(dec, b) {
+			return EncodeHex(b)
+		}
+	}
+	return out
+}
+
 // determine input type and decode accordingly
 func decode(encoded []byte) (dst []byte, err error) {
 	var b64NotHex = []byte("GHIJKLMNOPQRSTUVWXYZghijklmnopqrstuvwxyz+/-_")
@@ -0,0 +1,76 @@
+package password
+
+import (
+	"bytes"
+	"testing"
+)
+
+var seeds = []string{
+	"$scrypt$KeyLen=32,N=16384,R=8,P=1$PmxwHoNHjIILwrdOG8vA+A==$KRYMgbJr4vrYutrEjtueDDylXHQ2EoePyPoqtrDnil0=",
+	"$scrypt$KeyLen=16,N=16384,R=8,P=1$643873597251626754$e6b2da790d99bef794d6feb8ab7fda61",
+	"$pbkdf2$keylen=20,iterations=4096,hmac=SHA1$c2FsdA==$SwB5AbdlSJq+rUnZJvch0GWkKcE=",
+	"$mcftest$rounds=1,keylen=32$bWNmdGVzdCE=$JEccVfgtB4oG4Uf536WcRJBSsMUWQb5jvPabxofDvOU=",
+	"$scrypt$$$",
+	"$scrypt$KeyLen=1$AAAA$AAAAAA==",
+	"$scrypt",
+	"",
+}
+
+func TestAmbiguousEncoding(t *testing.T) {
+	// Base64 encodings that consist only of hex digits.
+	for _, b := range [][]byte{{0, 0, 0}, {0x10, 0x41, 0x04}, {}} {
+		p := New([]byte("test"))
+		p.Salt, p.Key = b, b
+
+		q := New([]byte("test"))
+		if err := q.Parse(p.Bytes()); err != nil {
+			t.Errorf("%x: %s", b, err)
+			continue
+		}
+		if !bytes.Equal(q.Salt, b) || !bytes.Equal(q.Key, b) {
+			t.Errorf("%x: got salt %x, key %x", b, q.Salt, q.Key)
+		}
+	}
+}
+
+// FuzzParse checks that Parse does not panic and that whatever it accepts survives a round trip through Bytes.
+func FuzzParse(f *testing.F) {
+	for _, s := range seeds {
+		f.Add(s)
+	}
+
+	f.Fuzz(func(t *testing.T, encoded string) {
+		name := []byte("scrypt")
+		if len(encoded) > 1 {
+			if i := bytes.IndexByte([]byte(encoded[1:]), separator); i >= 0 {
+				name = []byte(encoded[1 : i+1])
+			}
+		}
+
+		p := New(name)
+		if err := p.Parse([]byte(encoded)); err != nil {
+			return
+		}
+
+		out := p.Bytes()
+
+		q := New(name)
+		if err := q.Parse(out); err != nil {
+			t.Fatalf("Parse(%q) of Bytes output failed: %s", out, err)
+		}
+
+		for _, f := range []struct {
+			name string
+			a, b []byte
+		}{
+			{"name", p.Name, q.Name},
+			{"params", p.Params, q.Params},
+			{"salt", p.Salt, q.Salt},
+			{"key", p.Key, q.Key},
+		} {
+			if !bytes.Equal(f.a, f.b) {
+				t.Errorf("%q -> %q: %s changed from %x to %x", encoded, out, f.name, f.a, f.b)
+			}
+		}
+	})
+}
@@ -0,0 +1,126 @@
+// Copyright 2014 Gyepi Sam. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pbkdf2
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+
+	"github.com/gyepisam/mcf"
+	"github.com/gyepisam/mcf/bridge"
+	"github.com/gyepisam/mcf/encoder"
+)
+
+// Django's password hashers, by the prefix of their passwords, which have the form
+//
+//	pbkdf2_sha256$260000$salt$key
+//
+// The salt is used as written, and the key is base64 encoded.
+// Django passwords are verified so that they can be replaced, and are never current.
+var djangoHashes = []struct {
+	prefix []byte
+	hash   Hash
+}{
+	{[]byte("pbkdf2_sha256$"), SHA256},
+	{[]byte("pbkdf2_sha1$"), SHA1},
+}
+
+// pbkdf2Encoder extends the bridge encoder with Django passwords.
+type pbkdf2Encoder struct {
+	*bridge.Encoder
+}
+
+// detectDjango recognizes Django passwords for mcf, which cannot find them by their id.
+func detectDjango(encoded []byte) (encoder.Encoder, bool) {
+	for _, d := range djangoHashes {
+		if bytes.HasPrefix(encoded, d.prefix) {
+			return mcf.Registered(mcf.PBKDF2), true
+		}
+	}
+	return nil, false
+}
+
+// parseDjango parses a Django password. It returns false, and nothing else, for any other password.
+// The parameters are validated.
+func parseDjango(encoded []byte) (ok bool, c *Config, salt, key []byte, err error) {
+	var hash Hash
+	for _, d := range djangoHashes {
+		if bytes.HasPrefix(encoded, d.prefix) {
+			ok, hash = true, d.hash
+			break
+		}
+	}
+	if !ok {
+		return false, nil, nil, nil, nil
+	}
+
+	fields := bytes.Split(encoded, []byte{'$'})
+	if len(fields) != 4 {
+		return true, nil, nil, nil, fmt.Errorf("%w: pbkdf2: django: expected 4 fields", encoder.ErrMalformedHash)
+	}
+
+	iterations, err := strconv.Atoi(string(fields[1]))
+	if err != nil {
+		return true, nil, nil, nil, fmt.Errorf("%w: pbkdf2: django: invalid iterations %q", encoder.ErrMalformedHash, fields[1])
+	}
+
+	salt = fields[2]
+	key, err = base64.StdEncoding.DecodeString(string(fields[3]))
+	if err != nil {
+		return true, nil, nil, nil, fmt.Errorf("%w: pbkdf2: django: invalid key: %s", encoder.ErrMalformedHash, err)
+	}
+
+	c = &Config{Hash: hash, Iterations: iterations, KeyLen: len(key), SaltLen: len(salt)}
+	if err := c.validate(); err != nil {
+		return true, nil, nil, nil, err
+	}
+	return true, c, salt, key, nil
+}
+
+// Verify verifies a password in the native or Django form.
+func (e *pbkdf2Encoder) Verify(plaintext, encoded []byte) (isValid bool, err error) {
+	ok, c, salt, key, err := parseDjango(encoded)
+	if !ok {
+		return e.Encoder.Verify(plaintext, encoded)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	testKey, err := c.Key(plaintext, salt)
+	if err != nil {
+		return false, err
+	}
+	return subtle.ConstantTimeCompare(key, testKey) == 1, nil
+}
+
+// IsCurrent compares the parameters of a native password with the current configuration.
+// Django passwords are never current.
+func (e *pbkdf2Encoder) IsCurrent(encoded []byte) (isCurrent bool, err error) {
+	ok, _, _, _, err := parseDjango(encoded)
+	if !ok {
+		return e.Encoder.IsCurrent(encoded)
+	}
+	return false, err
+}
+
+// ParseParams reports the parameters of a password in either form with the names used by the native form.
+func (e *pbkdf2Encoder) ParseParams(encoded []byte) (map[string]string, error) {
+	ok, c, _, _, err := parseDjango(encoded)
+	if !ok {
+		return e.Encoder.ParseParams(encoded)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{
+		"keylen":     strconv.Itoa(c.KeyLen),
+		"iterations": strconv.Itoa(c.Iterations),
+		"hmac":       c.Hash.String(),
+	}, nil
+}
@@ -3,6 +3,7 @@
 // license that can be found in the LICENSE file.
 
 // Package pbkdf2 implements a password encoding mechanism for the mcf framework
+// It also verifies the pbkdf2_sha256$ and pbkdf2_sha1$ passwords of Django, so that they can be replaced.
 package pbkdf2
 
 import (
@@ -16,6 +17,7 @@ import (
 
 	"github.com/gyepisam/mcf"
 	"github.com/gyepisam/mcf/bridge"
+	"github.com/gyepisam/mcf/encoder"
 )
 
 // Hash represents the HMAC hash function that the PBKDF2 algorithm uses as a pseudorandom function.
@@ -24,15 +26,22 @@ type Hash string
 // Hash implements the Stringer interface
 func (h Hash) String() string { return string(h) }
 
-// Size is the output length of the hash function.
+// Size is the output length of the hash function, or 0 if the hash is unknown.
 func (h Hash) Size() int {
 	hash, ok := hashes[h]
 	if !ok {
-		panic("unknown hash: " + string(h))
+		return 0
 	}
 	return hash().Size()
 }
 
+// Func returns the hash function, or nil if the hash is unknown.
+// It allows other packages, such as github.com/gyepisam/mcf/scram, to compute HMACs and digests
+// with the same function as the PBKDF2 key.
+func (h Hash) Func() func() hash.Hash {
+	return hashes[h]
+}
+
 // Available hashes
 const (
 	SHA1   Hash = "SHA1"
@@ -66,8 +75,10 @@ type Config struct {
 	KeyLen int
 
 	// Size of salt in bytes.
-	// The RFC recommends at least 8 bytes.
+	// The RFC recommends at least 8 bytes. At least mcf.MinSaltLen and at most MaxSaltLen.
 	SaltLen int
+
+	SaltMine mcf.SaltMiner // Source of salt, or the package SaltMine if nil; see mcf.SaltMiner.
 }
 
 // Default values. These are exported for documentation purposes.
@@ -115,7 +126,7 @@ func GetConfig() Config {
 //      err := pbkdf2.SetConfig(config)
 //      // error handling elided
 func SetConfig(config Config) error {
-	err := (&config).validate()
+	err := (&config).validateNew()
 	if err != nil {
 		return err
 	}
@@ -124,9 +135,12 @@ func SetConfig(config Config) error {
 
 // SaltMine is a custom source of salt, which is normally unset.
 // Change this to override the use of rand.Reader if you need to use a custom salt producer.
+// It is used when Config.SaltMine is nil.
+//
+// Deprecated: Set Config.SaltMine instead.
 var SaltMine mcf.SaltMiner = nil
 
-func register(config Config) error {
+func newEncoder(config Config) encoder.Encoder {
 
 	// Constructor for Implementer. Always return a fresh copy.
 	fn := func() bridge.Implementer {
@@ -135,9 +149,11 @@ func register(config Config) error {
 	}
 
 	// the bridge handles the generic parts of the interface
-	enc := bridge.New([]byte("pbkdf2"), fn)
+	return &pbkdf2Encoder{bridge.New([]byte("pbkdf2"), fn).(*bridge.Encoder)}
+}
 
-	return mcf.Register(mcf.PBKDF2, enc)
+func register(config Config) error {
+	return mcf.Register(mcf.PBKDF2, newEncoder(config))
 }
 
 func init() {
@@ -145,6 +161,10 @@ func init() {
 	if err != nil {
 		panic(err)
 	}
+	mcf.RegisterFactory(mcf.PBKDF2, mcf.NewFactory((*Config).validateNew, newEncoder))
+	if err := mcf.RegisterDetectorPrecedence(mcf.PrecedencePrefix, detectDjango); err != nil {
+		panic(err)
+	}
 }
 
 // ErrInvalidHash is returned when an invalid Hash is encountered.
@@ -158,13 +178,54 @@ func (e *ErrInvalidHash) Error() string {
 	return fmt.Sprintf("Invalid Hash: %s", e.Hash)
 }
 
+// Unwrap returns encoder.ErrInvalidParams.
+func (e *ErrInvalidHash) Unwrap() error { return encoder.ErrInvalidParams }
+
+// ErrInvalidParameter is returned when a numeric parameter is out of range.
+// The error message contains the name and value of the faulty parameter.
+type ErrInvalidParameter struct {
+	Name  string
+	Value int
+}
+
+func (e ErrInvalidParameter) Error() string {
+	return fmt.Sprintf("parameter %s has invalid value: %d", e.Name, e.Value)
+}
+
+// Unwrap returns encoder.ErrInvalidParams.
+func (e ErrInvalidParameter) Unwrap() error { return encoder.ErrInvalidParams }
+
+// Limits on parameters, which guard against the exhaustion of memory or time by a corrupt or malicious
+// encoded password. They may be raised if necessary.
+var (
+	MaxIterations = 1 << 24
+	MaxKeyLen     = 1024
+	MaxSaltLen    = 1024
+)
+
 func (c *Config) validate() error {
 	if _, ok := hashes[c.Hash]; !ok {
 		return &ErrInvalidHash{c.Hash}
 	}
+	switch {
+	case c.Iterations < 1 || c.Iterations > MaxIterations:
+		return ErrInvalidParameter{"Iterations", c.Iterations}
+	case c.KeyLen < 1 || c.KeyLen > MaxKeyLen:
+		return ErrInvalidParameter{"KeyLen", c.KeyLen}
+	case c.SaltLen < 0 || c.SaltLen > MaxSaltLen:
+		return ErrInvalidParameter{"SaltLen", c.SaltLen}
+	}
 	return nil
 }
 
+// validateNew validates the configuration of new passwords, which, unlike stored ones, must be salted.
+func (c *Config) validateNew() error {
+	if c.SaltLen < mcf.MinSaltLen {
+		return ErrInvalidParameter{"SaltLen", c.SaltLen}
+	}
+	return c.validate()
+}
+
 // Keep these together
 // Note that Sscanf on %s breaks on space and must therefore be the last item (and the only string).
 const format = "keylen=%d,iterations=%d,hmac=%s"
@@ -178,26 +239,47 @@ func (c *Config) Params() string {
 func (c *Config) SetParams(params string) error {
 	_, err := fmt.Sscanf(params, format, &c.KeyLen, &c.Iterations, &c.Hash)
 	if err != nil {
-		return err
+		return fmt.Errorf("%w: pbkdf2: %s", encoder.ErrInvalidParams, err)
 	}
 	return c.validate()
 }
 
-// Salt produces SaltLen bytes of random data.
+// Salt produces SaltLen bytes from SaltMine, or of random data if it is not set.
 func (c *Config) Salt() ([]byte, error) {
-	return mcf.Salt(c.SaltLen, SaltMine)
+	miner := c.SaltMine
+	if miner == nil {
+		miner = SaltMine
+	}
+	salt, err := mcf.Salt(c.SaltLen, miner)
+	if err != nil {
+		return nil, fmt.Errorf("pbkdf2: salt: %w", err)
+	}
+	return salt, nil
 }
 
 // Key generates a PBKDF2 digest from the password, salt and iteration count,
 // using the Hash as a pseudorandom function.
 func (c *Config) Key(password, salt []byte) ([]byte, error) {
+	if len(salt) > MaxSaltLen {
+		return nil, fmt.Errorf("%w: pbkdf2: salt is longer than %d bytes", encoder.ErrMalformedHash, MaxSaltLen)
+	}
 	return pbkdf2.Key(password, salt, c.Iterations, c.KeyLen, hashes[c.Hash]), nil
 }
 
+// SetKeyLen sets the key length, which must be between 1 and MaxKeyLen.
+// It implements bridge.KeySizer.
+func (c *Config) SetKeyLen(n int) error {
+	c.KeyLen = n
+	return c.validate()
+}
+
 // AtLeast compares the parameters for an encoded password to the current configuration
 // and returns true if the encoded password configuration has the same or longer configuration
 // parameter values.
 func (c *Config) AtLeast(current_imp bridge.Implementer) bool {
-	current := current_imp.(*Config) // ok to panic if this fails.
+	current, ok := current_imp.(*Config)
+	if !ok {
+		return false
+	}
 	return !(c.Iterations < current.Iterations || c.KeyLen < current.KeyLen || c.SaltLen < current.SaltLen)
 }
@@ -3,6 +3,7 @@ package pbkdf2
 import (
 	"bytes"
 	"encoding/hex"
+	"errors"
 	"testing"
 
 	"github.com/gyepisam/mcf"
@@ -116,6 +117,18 @@ func TestVectors(t *testing.T) {
 			continue
 		}
 
+		if len(v.salt) < mcf.MinSaltLen {
+			// New passwords need longer salts, but the key is still computed for stored ones.
+			if _, err := setConfig(len(key), v.iterations, len(v.salt)); !errors.Is(err, mcf.ErrInvalidParams) {
+				t.Errorf("%d: setting config with a short salt: got %v, expected %v", i, err, mcf.ErrInvalidParams)
+			}
+			c := Config{KeyLen: len(key), Iterations: v.iterations, Hash: SHA1}
+			if got, err := c.Key([]byte(v.plain), []byte(v.salt)); err != nil || !bytes.Equal(got, key) {
+				t.Errorf("%d: Key: got (%x, %v), expected %x", i, got, err, key)
+			}
+			continue
+		}
+
 		config, err := setConfig(len(key), v.iterations, len(v.salt))
 		if err != nil {
 			t.Errorf("%d: unexpected error setting config: %s", i, err)
@@ -164,7 +177,7 @@ func TestVectors(t *testing.T) {
 			setConfig(c.KeyLen, c.Iterations, c.SaltLen)
 			isCurrent, err := mcf.IsCurrent(encoded)
 			if err != nil {
-				t.Errorf("%d-%d: IsCurrent: unexpected failure: %", i, j, err)
+				t.Errorf("%d-%d: IsCurrent: unexpected failure: %s", i, j, err)
 				continue
 			}
 			//old configuration says yes, new configuration says no
@@ -175,3 +188,70 @@ func TestVectors(t *testing.T) {
 		}
 	}
 }
+
+func TestInvalidParams(t *testing.T) {
+	if n := Hash("MD4").Size(); n != 0 {
+		t.Errorf("Size of unknown hash: got %d, expected 0", n)
+	}
+	if Hash("MD4").Func() != nil || SHA256.Func() == nil {
+		t.Errorf("Func: got a function for an unknown hash, or none for a known one")
+	}
+
+	for _, params := range []string{
+		"keylen=-1,iterations=1,hmac=SHA1",
+		"keylen=20,iterations=0,hmac=SHA1",
+		"keylen=20,iterations=1,hmac=MD4",
+		"keylen=20,iterations=junk",
+	} {
+		c := GetConfig()
+		if err := c.SetParams(params); !errors.Is(err, mcf.ErrInvalidParams) {
+			t.Errorf("SetParams(%q): got %v, expected %v", params, err, mcf.ErrInvalidParams)
+		}
+	}
+
+	c := GetConfig()
+	if c.AtLeast(nil) {
+		t.Error("AtLeast(nil): got true, expected false")
+	}
+}
+
+// Django passwords computed with Python's hashlib, as Django's PBKDF2PasswordHasher does.
+var djangoVectors = []struct {
+	plaintext, encoded string
+}{
+	{"password", "pbkdf2_sha256$260000$seasalt$ftMWvEdczZQK5azuap2CQYKRjHLa1wOuMrfMiYEswYQ="},
+	{"secret", "pbkdf2_sha256$1000$ZmNfUEO2F1Le$UqgnH93jVlmu0vsh3UxYFkbhsXR+/M8NB7r9whbWQ5s="},
+	{"password", "pbkdf2_sha1$10000$salt$osJkYYaChHS3VFkaVHwY8TLYjXQ="},
+}
+
+func TestDjango(t *testing.T) {
+	for i, v := range djangoVectors {
+		isValid, err := mcf.Verify(v.plaintext, v.encoded)
+		if err != nil || !isValid {
+			t.Errorf("%d: Verify: got (%t, %v), expected (true, nil)", i, isValid, err)
+		}
+		isValid, err = mcf.Verify(v.plaintext+"x", v.encoded)
+		if err != nil || isValid {
+			t.Errorf("%d: Verify wrong password: got (%t, %v), expected (false, nil)", i, isValid, err)
+		}
+		if isCurrent, err := mcf.IsCurrent(v.encoded); err != nil || isCurrent {
+			t.Errorf("%d: IsCurrent: got (%t, %v), expected (false, nil)", i, isCurrent, err)
+		}
+	}
+
+	for _, tt := range []struct {
+		encoded string
+		want    error
+	}{
+		{"pbkdf2_sha256$1000$salt", mcf.ErrMalformedHash},
+		{"pbkdf2_sha256$x$salt$UqgnH93jVlmu0vsh3UxYFkbhsXR+/M8NB7r9whbWQ5s=", mcf.ErrMalformedHash},
+		{"pbkdf2_sha256$1000$salt$UqgnH93jVlmu0vsh3UxYFkbhsXR+/M8NB7r9whbWQ5s", mcf.ErrMalformedHash},
+		{"pbkdf2_sha256$0$salt$UqgnH93jVlmu0vsh3UxYFkbhsXR+/M8NB7r9whbWQ5s=", mcf.ErrInvalidParams},
+		{"pbkdf2_sha256$1000$salt$", mcf.ErrInvalidParams},
+		{"pbkdf2_md5$1000$salt$UqgnH93jVlmu0vsh3UxYFkbhsXR+/M8NB7r9whbWQ5s=", mcf.ErrUnknownScheme},
+	} {
+		if isValid, err := mcf.Verify("secret", tt.encoded); isValid || !errors.Is(err, tt.want) {
+			t.Errorf("Verify %q: got (%t, %v), expected (false, %v)", tt.encoded, isValid, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,224 @@
+// Copyright 2014 Gyepi Sam. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Package phpass verifies the portable password hashes of the phpass framework, for the mcf framework.
+
+Phpass portable hashes are used by WordPress, phpBB and others, and look like
+
+	$P$984478476IagS59wHZvyQMArzfx58u.     (WordPress)
+	$H$9y5boZ2wsDKRneTX2jRMYcWQg/EfNt0     (phpBB)
+
+Drupal 7 uses a variant, based on SHA-512 rather than MD5, whose hashes are truncated to 55 characters:
+
+	$S$C33783772bRXEx1aCsvY.dqgaaSu76XmVlKrW9Qu8IQlvxHlmzLf
+
+The package verifies all three, so that users can log in and have their passwords replaced by the
+default encoder. IsCurrent always returns false to that end. The encoder is registered with
+mcf.RegisterVerifier, so it never becomes the default, and its Create is disabled unless Config.Enabled
+is set; see SetConfig.
+*/
+package phpass
+
+import (
+	"crypto/md5"
+	"crypto/sha512"
+	"crypto/subtle"
+	"fmt"
+	"hash"
+	"strconv"
+
+	"github.com/gyepisam/mcf"
+	"github.com/gyepisam/mcf/encoder"
+	"github.com/gyepisam/mcf/internal/crypt64"
+)
+
+// DefaultCost is the base 2 logarithm of the iteration count used by WordPress,
+// and is exported here for documentation purposes only.
+const DefaultCost = 13
+
+// MinCost is the lowest cost that phpass accepts.
+const MinCost = 7
+
+// MaxCost is the highest cost that is accepted, both by SetConfig and in encoded passwords.
+// It guards against the exhaustion of time by a corrupt or malicious encoded password,
+// since each increment doubles the work. It may be raised, to at most 30, if necessary.
+var MaxCost = 24
+
+// ErrCreateDisabled is returned by Create unless Config.Enabled is set.
+var ErrCreateDisabled = fmt.Errorf("%w: phpass", encoder.ErrCreateDisabled)
+
+// Config contains the parameters used to create new passwords, which are always in the WordPress ($P$) form.
+type Config struct {
+	// Enabled allows Create to produce new passwords. It is off by default,
+	// since phpass is only suitable for verifying legacy passwords.
+	Enabled bool
+
+	Cost int // The base 2 logarithm of the iteration count.
+
+	SaltMine mcf.SaltMiner // Source of the 6 bytes of salt; see mcf.SaltMiner.
+}
+
+// GetConfig returns the default configuration, which can be modified and used as a parameter to SetConfig.
+func GetConfig() Config {
+	return Config{Cost: DefaultCost}
+}
+
+// SetConfig sets the configuration used by Create.
+func SetConfig(config Config) error {
+	if err := config.validate(); err != nil {
+		return err
+	}
+	return register(config)
+}
+
+func (c *Config) validate() error {
+	if c.Cost < MinCost || c.Cost > MaxCost {
+		return fmt.Errorf("%w: phpass: cost %d is not between %d and MaxCost %d", encoder.ErrInvalidParams, c.Cost, MinCost, MaxCost)
+	}
+	return nil
+}
+
+type phpass struct {
+	config Config
+}
+
+func register(config Config) error {
+	return mcf.RegisterVerifier(mcf.PHPASS, &phpass{config})
+}
+
+func init() {
+	if err := register(GetConfig()); err != nil {
+		panic(err)
+	}
+	mcf.RegisterFactory(mcf.PHPASS, mcf.NewFactory((*Config).validate, func(c Config) encoder.Encoder { return &phpass{c} }))
+}
+
+// A variant is one of the forms of phpass hashes.
+type variant struct {
+	id   byte
+	name string
+	hash func() hash.Hash
+	size int // The length of the encoded password.
+}
+
+var variants = []variant{
+	{'P', "phpass", md5.New, 34},
+	{'H', "phpbb", md5.New, 34},
+	{'S', "drupal", sha512.New, 55},
+}
+
+// settingLen is the length of the prefix, cost and salt.
+const settingLen = 12
+
+func malformed(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: phpass: "+format, append([]interface{}{encoder.ErrMalformedHash}, args...)...)
+}
+
+// parse checks an encoded password and returns its variant and cost.
+func parse(encoded []byte) (v variant, cost int, err error) {
+	if len(encoded) < settingLen || encoded[0] != '$' || encoded[2] != '$' {
+		err = malformed("invalid prefix")
+		return
+	}
+
+	found := false
+	for _, v = range variants {
+		if v.id == encoded[1] {
+			found = true
+			break
+		}
+	}
+	if !found {
+		err = malformed("unknown id %q", encoded[1])
+		return
+	}
+
+	if len(encoded) != v.size {
+		err = malformed("%s hash must be %d characters", v.name, v.size)
+		return
+	}
+
+	cost = crypt64.Index(encoded[3])
+	if cost < MinCost || cost > MaxCost {
+		err = fmt.Errorf("%w: phpass: cost %d is not between %d and MaxCost %d", encoder.ErrInvalidParams, cost, MinCost, MaxCost)
+		return
+	}
+	return
+}
+
+// crypt hashes plaintext with the setting, which is the first settingLen bytes of an encoded password,
+// and returns the encoded password, which has the length of the variant.
+func crypt(v variant, cost int, setting, plaintext []byte) []byte {
+	salt := setting[4:settingLen]
+
+	h := v.hash()
+	h.Write(salt)
+	h.Write(plaintext)
+	sum := h.Sum(nil)
+
+	for i := 1 << cost; i > 0; i-- {
+		h.Reset()
+		h.Write(sum)
+		h.Write(plaintext)
+		sum = h.Sum(sum[:0])
+	}
+
+	encoded := append(setting[:settingLen:settingLen], crypt64.Encode(sum)...)
+	return encoded[:v.size]
+}
+
+// Id returns the identifier of WordPress passwords.
+// Those of phpBB and Drupal are aliases.
+func (p *phpass) Id() []byte {
+	return []byte("P")
+}
+
+// Aliases implements encoder.Aliaser.
+func (p *phpass) Aliases() [][]byte {
+	return [][]byte{[]byte("H"), []byte("S")}
+}
+
+// Create produces an encoded password in the WordPress form, if Config.Enabled is set,
+// and otherwise returns ErrCreateDisabled.
+func (p *phpass) Create(plaintext []byte) (encoded []byte, err error) {
+	if !p.config.Enabled {
+		return nil, ErrCreateDisabled
+	}
+
+	salt, err := mcf.Salt(6, p.config.SaltMine)
+	if err != nil {
+		return nil, fmt.Errorf("phpass: salt: %w", err)
+	}
+
+	setting := []byte{'$', 'P', '$', crypt64.Alphabet[p.config.Cost]}
+	setting = append(setting, crypt64.Encode(salt)...)
+	return crypt(variants[0], p.config.Cost, setting, plaintext), nil
+}
+
+// Verify returns true if the proffered plaintext password,
+// when encoded using the same parameters, matches the encoded password.
+func (p *phpass) Verify(plaintext, encoded []byte) (isValid bool, err error) {
+	v, cost, err := parse(encoded)
+	if err != nil {
+		return false, err
+	}
+	return subtle.ConstantTimeCompare(crypt(v, cost, encoded, plaintext), encoded) == 1, nil
+}
+
+// IsCurrent always returns false for a valid encoded password, since phpass hashes should be replaced.
+func (p *phpass) IsCurrent(encoded []byte) (isCurrent bool, err error) {
+	_, _, err = parse(encoded)
+	return false, err
+}
+
+// ParseParams implements encoder.ParamsParser. The parameters are "variant", which is one of
+// "phpass", "phpbb" and "drupal", and "cost".
+func (p *phpass) ParseParams(encoded []byte) (map[string]string, error) {
+	v, cost, err := parse(encoded)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{"variant": v.name, "cost": strconv.Itoa(cost)}, nil
+}
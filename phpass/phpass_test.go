@@ -0,0 +1,121 @@
+// Copyright 2014 Gyepi Sam. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package phpass
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/gyepisam/mcf"
+)
+
+// The first three are hashcat's example hashes; the others were produced with
+// a port of Drupal's password.inc and checked against them.
+var testVectors = []struct {
+	plaintext string
+	encoded   string
+	variant   string
+	cost      string
+}{
+	{"test12345", "$P$9IQRaTwmfeRo7ud9Fh4E2PdI0S3r.L0", "phpass", "11"},
+	{"hashcat", "$P$984478476IagS59wHZvyQMArzfx58u.", "phpass", "11"},
+	{"hashcat", "$S$C33783772bRXEx1aCsvY.dqgaaSu76XmVlKrW9Qu8IQlvxHlmzLf", "drupal", "14"},
+	{"hashcat", "$H$9y5boZ2wsDKRneTX2jRMYcWQg/EfNt0", "phpbb", "11"},
+	{"password", "$S$DSALTsaltFgEr81fWJhb.HFLqtnf7urI7e/ikkdOZ16I9twDADNS", "drupal", "15"},
+	{"", "$P$BabcdefghrBY/znFl0cIh22fo6F2px.", "phpass", "13"},
+}
+
+func TestVectors(t *testing.T) {
+	p := mcf.Registered(mcf.PHPASS).(*phpass)
+
+	for i, v := range testVectors {
+		isValid, err := mcf.Verify(v.plaintext, v.encoded)
+		if err != nil || !isValid {
+			t.Errorf("%d: Verify: got (%t, %v), expected (true, nil)", i, isValid, err)
+		}
+
+		isValid, err = mcf.Verify(v.plaintext+"x", v.encoded)
+		if err != nil || isValid {
+			t.Errorf("%d: Verify wrong password: got (%t, %v), expected (false, nil)", i, isValid, err)
+		}
+
+		isCurrent, err := p.IsCurrent([]byte(v.encoded))
+		if err != nil || isCurrent {
+			t.Errorf("%d: IsCurrent: got (%t, %v), expected (false, nil)", i, isCurrent, err)
+		}
+
+		params, err := p.ParseParams([]byte(v.encoded))
+		if err != nil {
+			t.Errorf("%d: ParseParams: %s", i, err)
+		} else if params["variant"] != v.variant || params["cost"] != v.cost {
+			t.Errorf("%d: ParseParams: got %v, expected variant %s and cost %s", i, params, v.variant, v.cost)
+		}
+	}
+}
+
+func TestNotDefault(t *testing.T) {
+	if mcf.Default() == mcf.PHPASS {
+		t.Errorf("phpass is the default encoding")
+	}
+}
+
+func TestCreate(t *testing.T) {
+	defer SetConfig(GetConfig())
+
+	if _, err := mcf.Registered(mcf.PHPASS).Create([]byte("password")); !errors.Is(err, mcf.ErrCreateDisabled) {
+		t.Errorf("Create: got %v, expected %v", err, mcf.ErrCreateDisabled)
+	}
+
+	config := GetConfig()
+	config.Enabled = true
+	config.Cost = 8
+	config.SaltMine = func(n int) ([]byte, error) { return bytes.Repeat([]byte{0}, n), nil }
+	if err := SetConfig(config); err != nil {
+		t.Fatal(err)
+	}
+
+	p := mcf.Registered(mcf.PHPASS)
+	encoded, err := p.Create([]byte("password"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "$P$6........"; !strings.HasPrefix(string(encoded), want) || len(encoded) != 34 {
+		t.Errorf("Create: got %s, expected 34 characters beginning with %s", encoded, want)
+	}
+	if isValid, err := p.Verify([]byte("password"), encoded); err != nil || !isValid {
+		t.Errorf("Verify: got (%t, %v), expected (true, nil)", isValid, err)
+	}
+
+	for _, cost := range []int{MinCost - 1, MaxCost + 1} {
+		config.Cost = cost
+		if err := SetConfig(config); !errors.Is(err, mcf.ErrInvalidParams) {
+			t.Errorf("SetConfig cost %d: got %v, expected %v", cost, err, mcf.ErrInvalidParams)
+		}
+	}
+}
+
+func TestMalformed(t *testing.T) {
+	for _, tt := range []struct {
+		encoded string
+		want    error
+	}{
+		{"$P$", mcf.ErrMalformedHash},
+		{"$P$9IQRaTwmfeRo7ud9Fh4E2PdI0S3r.L", mcf.ErrMalformedHash},
+		{"$P$9IQRaTwmfeRo7ud9Fh4E2PdI0S3r.L00", mcf.ErrMalformedHash},
+		{"$S$C33783772bRXEx1aCsvY.dqgaaSu76XmVlKrW9Qu8IQlvxHlmzL", mcf.ErrMalformedHash},
+		{"$P$4IQRaTwmfeRo7ud9Fh4E2PdI0S3r.L0", mcf.ErrInvalidParams},
+		{"$P$zIQRaTwmfeRo7ud9Fh4E2PdI0S3r.L0", mcf.ErrInvalidParams},
+		{"$P$*IQRaTwmfeRo7ud9Fh4E2PdI0S3r.L0", mcf.ErrInvalidParams},
+	} {
+		if isValid, err := mcf.Verify("test12345", tt.encoded); isValid || !errors.Is(err, tt.want) {
+			t.Errorf("Verify %q: got (%t, %v), expected (false, %v)", tt.encoded, isValid, err, tt.want)
+		}
+		if _, err := mcf.IsCurrent(tt.encoded); !errors.Is(err, tt.want) {
+			t.Errorf("IsCurrent %q: got %v, expected %v", tt.encoded, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+// Copyright 2014 Gyepi Sam. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mcf
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/gyepisam/mcf/encoder"
+)
+
+// A Factory produces an encoder from an encoder specific configuration, such as a scrypt.Config.
+// Each encoder registers one with RegisterFactory so that profiles can use it.
+type Factory func(config interface{}) (encoder.Encoder, error)
+
+var factories [maxEncoding]Factory
+
+// NewFactory returns a Factory for an encoder whose configuration has the type C.
+// The Factory accepts a C or a *C, checks it with validate and produces the encoder with newEncoder.
+func NewFactory[C any](validate func(*C) error, newEncoder func(C) encoder.Encoder) Factory {
+	return func(config interface{}) (encoder.Encoder, error) {
+		var c C
+		switch v := config.(type) {
+		case C:
+			c = v
+		case *C:
+			if v == nil {
+				return nil, fmt.Errorf("mcf: expected a %T, got a nil %T", c, config)
+			}
+			c = *v
+		default:
+			return nil, fmt.Errorf("mcf: expected a %T, got %T", c, config)
+		}
+		if err := validate(&c); err != nil {
+			return nil, err
+		}
+		return newEncoder(c), nil
+	}
+}
+
+// RegisterFactory registers the factory for an encoding.
+// It is expected that each encoder will call RegisterFactory from an init() function, along with Register.
+func RegisterFactory(encoding Encoding, factory Factory) error {
+	if !encoding.IsValid() {
+		return encoding.errInvalid()
+	}
+	factories[encoding] = factory
+	return nil
+}
+
+// A Profile is a named password policy, consisting of an encoding and its configuration,
+// for a class of accounts that needs a policy other than the default.
+type Profile struct {
+	Encoding Encoding
+
+	// Config is the encoder specific configuration, such as a scrypt.Config,
+	// a pbkdf2.Config or a bcrypt.Config. If it is nil, the encoder's current
+	// configuration, as set by its SetConfig function, is used.
+	Config interface{}
+}
+
+// ErrNoProfile is returned for a profile name that has not been defined.
+var ErrNoProfile = errors.New("mcf: no such profile")
+
+type profile struct {
+	encoding Encoding
+	inst     *instance // nil to use the registered encoder.
+}
+
+var profiles = map[string]profile{}
+
+// DefineProfile defines, or redefines, the named profile. For instance:
+//
+//	config := scrypt.GetConfig()
+//	config.N *= 4
+//	err := mcf.DefineProfile("admin", mcf.Profile{Encoding: mcf.SCRYPT, Config: config})
+//
+// The encoder must be imported. Like Register, DefineProfile is meant to be called during initialization.
+func DefineProfile(name string, p Profile) error {
+	inst, err := p.instance()
+	if err != nil {
+		return err
+	}
+	if p.Config == nil {
+		inst = nil
+	}
+	profiles[name] = profile{encoding: p.Encoding, inst: inst}
+	return nil
+}
+
+// instance returns an encoder for the profile's Config, or the registered encoder if it is nil.
+func (p Profile) instance() (*instance, error) {
+	if !p.Encoding.IsValid() {
+		return nil, p.Encoding.errInvalid()
+	}
+	inst := encoders[p.Encoding]
+	if inst == nil {
+		return nil, &ErrUnregisteredEncoding{fmt.Sprintf("encoding [%s] not registered. Forgot to import?", p.Encoding)}
+	}
+	if p.Config == nil {
+		return inst, nil
+	}
+
+	factory := factories[p.Encoding]
+	if factory == nil {
+		return nil, fmt.Errorf("mcf: encoding [%s] does not support profiles", p.Encoding)
+	}
+	enc, err := factory(p.Config)
+	if err != nil {
+		return nil, err
+	}
+	return &instance{id: enc.Id(), Encoder: enc}, nil
+}
+
+func lookupProfile(name string) (profile, *instance, error) {
+	p, ok := profiles[name]
+	if !ok {
+		return p, nil, fmt.Errorf("%w: %q", ErrNoProfile, name)
+	}
+	if p.inst != nil {
+		return p, p.inst, nil
+	}
+	enc := encoders[p.encoding]
+	if enc == nil {
+		return p, nil, &ErrUnregisteredEncoding{fmt.Sprintf("encoding [%s] not registered", p.encoding)}
+	}
+	return p, enc, nil
+}
+
+// CreateWith is like Create, but uses the encoding and configuration of the named profile.
+func CreateWith(name, plaintext string) (encoded string, err error) {
+	p, enc, err := lookupProfile(name)
+	if err != nil {
+		return
+	}
+	return create(p.encoding, enc, plaintext)
+}
+
+// IsCurrentFor is like IsCurrent, but judges the encoded password against the named profile
+// rather than the default encoding. Use it for accounts whose passwords are created with CreateWith.
+// Passwords are verified with Verify, whatever their profile.
+func IsCurrentFor(name, encoded string) (isCurrent bool, err error) {
+	p, enc, err := lookupProfile(name)
+	if err != nil {
+		return
+	}
+	return checkCurrent(encoded, p.encoding, enc)
+}
@@ -0,0 +1,143 @@
+// Copyright 2014 Gyepi Sam. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pwned
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// builder writes index records and ensures that they are in strictly ascending order,
+// since Index lookups depend on it.
+type builder struct {
+	w    *bufio.Writer
+	prev []byte
+	n    int
+}
+
+func newBuilder(w io.Writer) (*builder, error) {
+	b := &builder{w: bufio.NewWriter(w)}
+	_, err := b.w.WriteString(magic)
+	return b, err
+}
+
+// add parses a line of the form HASH:COUNT, where HASH is prefix followed by hex digits.
+func (b *builder) add(prefix string, line []byte, lineno int) error {
+	line = bytes.TrimSpace(line)
+	if len(line) == 0 {
+		return nil
+	}
+
+	i := bytes.IndexByte(line, ':')
+	if i < 0 {
+		return fmt.Errorf("pwned: line %d: missing count", lineno)
+	}
+
+	digest := prefix + string(line[:i])
+	if len(digest) != 2*hashLen {
+		return fmt.Errorf("pwned: line %d: hash must be %d hex digits: %q", lineno, 2*hashLen, digest)
+	}
+
+	hash, err := hex.DecodeString(digest)
+	if err != nil {
+		return fmt.Errorf("pwned: line %d: invalid hash: %q", lineno, digest)
+	}
+
+	count, err := strconv.ParseUint(string(line[i+1:]), 10, 32)
+	if err != nil {
+		return fmt.Errorf("pwned: line %d: invalid count: %s", lineno, err)
+	}
+
+	if b.prev != nil && bytes.Compare(hash, b.prev) <= 0 {
+		return fmt.Errorf("pwned: line %d: hashes must be unique and sorted in ascending order", lineno)
+	}
+	b.prev = hash
+
+	var rec [recordLen]byte
+	copy(rec[:], hash)
+	binary.BigEndian.PutUint32(rec[hashLen:], uint32(count))
+	_, err = b.w.Write(rec[:])
+	b.n++
+	return err
+}
+
+func (b *builder) scan(prefix string, r io.Reader) error {
+	s := bufio.NewScanner(r)
+	for lineno := 1; s.Scan(); lineno++ {
+		if err := b.add(prefix, s.Bytes(), lineno); err != nil {
+			return err
+		}
+	}
+	return s.Err()
+}
+
+// Build reads the Pwned Passwords SHA-1 dump, ordered by hash, from r and writes an index to w.
+// Each line of the dump has the form HASH:COUNT, where HASH is 40 hex digits.
+// It returns the number of hashes written.
+func Build(w io.Writer, r io.Reader) (n int, err error) {
+	b, err := newBuilder(w)
+	if err != nil {
+		return
+	}
+
+	if err = b.scan("", r); err != nil {
+		return
+	}
+
+	return b.n, b.w.Flush()
+}
+
+// BuildRanges writes an index to w from a directory of range files,
+// such as those fetched from the range API or produced by the official downloader.
+// Each file is named for its five hex digit prefix, optionally followed by an extension,
+// and contains lines of the form SUFFIX:COUNT.
+// Files whose names do not begin with a prefix are ignored.
+// It returns the number of hashes written.
+func BuildRanges(w io.Writer, dir string) (n int, err error) {
+	names, err := filepath.Glob(filepath.Join(dir, "*"))
+	if err != nil {
+		return
+	}
+
+	type rangeFile struct{ prefix, path string }
+	var files []rangeFile
+	for _, path := range names {
+		base := filepath.Base(path)
+		prefix := strings.ToUpper(strings.TrimSuffix(base, filepath.Ext(base)))
+		if _, err := parsePrefix(prefix); err != nil {
+			continue
+		}
+		files = append(files, rangeFile{prefix, path})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].prefix < files[j].prefix })
+
+	b, err := newBuilder(w)
+	if err != nil {
+		return
+	}
+
+	for _, rf := range files {
+		f, err := os.Open(rf.path)
+		if err != nil {
+			return b.n, err
+		}
+		err = b.scan(rf.prefix, f)
+		f.Close()
+		if err != nil {
+			return b.n, fmt.Errorf("%s: %w", rf.path, err)
+		}
+	}
+
+	return b.n, b.w.Flush()
+}
@@ -0,0 +1,219 @@
+// Copyright 2014 Gyepi Sam. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Package pwned checks passwords against a local copy of the Pwned Passwords dataset.
+
+The dataset is published as SHA-1 hashes of compromised passwords, along with the number
+of times each one has been seen. The text form is large and slow to search, so Build and
+BuildRanges convert it, once, into a compact index of fixed size records sorted by hash.
+An Index performs k-anonymity style lookups on that file: a five character hash prefix
+selects a range of records, which is found by binary search, and the remaining suffix
+is matched within the range. No network access is needed.
+
+To reject compromised passwords when they are created:
+
+	idx, err := pwned.Open("/var/lib/pwned/sha1.idx")
+	// error handling elided
+	mcf.AddPreCreateHook(idx.Check)
+
+mcf.Create then returns an *ErrPwned for any password found in the index.
+*/
+package pwned
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Layout of an index file: a header followed by records sorted by hash.
+const (
+	magic      = "MCFPWND1"
+	headerLen  = len(magic)
+	hashLen    = sha1.Size
+	recordLen  = hashLen + 4 // hash followed by a big endian uint32 count.
+	PrefixLen  = 5           // Length, in hex digits, of a range prefix.
+	prefixBits = 4 * PrefixLen
+)
+
+// ErrPwned is returned by Check for a password that is present in the dataset.
+// Count is the number of times the password has been seen in breaches.
+type ErrPwned struct {
+	Count int
+}
+
+func (e *ErrPwned) Error() string {
+	return fmt.Sprintf("password has appeared in a data breach %d times", e.Count)
+}
+
+// ErrBadIndex is returned when an index file is malformed.
+var ErrBadIndex = errors.New("pwned: invalid index file")
+
+// An Entry is a single record in a range: the hash suffix, in upper case hex,
+// and the number of times the password with that hash has been seen.
+type Entry struct {
+	Suffix string
+	Count  int
+}
+
+// An Index is a read only, searchable, compact form of the Pwned Passwords dataset.
+// An Index is safe for concurrent use.
+type Index struct {
+	r     io.ReaderAt
+	n     int // record count
+	close func() error
+}
+
+// New returns an Index that reads from r, which holds size bytes in the format produced by Build.
+func New(r io.ReaderAt, size int64) (*Index, error) {
+	if size < int64(headerLen) || (size-int64(headerLen))%recordLen != 0 {
+		return nil, ErrBadIndex
+	}
+
+	b := make([]byte, headerLen)
+	if _, err := r.ReadAt(b, 0); err != nil {
+		return nil, err
+	}
+	if string(b) != magic {
+		return nil, ErrBadIndex
+	}
+
+	return &Index{r: r, n: int((size - int64(headerLen)) / recordLen)}, nil
+}
+
+// Open opens the named index file.
+// The caller should call Close when the Index is no longer needed.
+func Open(name string) (*Index, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	x, err := New(f, fi.Size())
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	x.close = f.Close
+	return x, nil
+}
+
+// Close releases the file opened by Open. It is a no-op for an Index created with New.
+func (x *Index) Close() error {
+	if x.close == nil {
+		return nil
+	}
+	return x.close()
+}
+
+// Len returns the number of hashes in the index.
+func (x *Index) Len() int { return x.n }
+
+func (x *Index) record(i int) (hash []byte, count int, err error) {
+	b := make([]byte, recordLen)
+	n, err := x.r.ReadAt(b, int64(headerLen)+int64(i)*recordLen)
+	// A ReaderAt may return io.EOF along with the last record.
+	if err != nil && !(err == io.EOF && n == recordLen) {
+		return nil, 0, err
+	}
+	return b[:hashLen], int(binary.BigEndian.Uint32(b[hashLen:])), nil
+}
+
+// leading returns the range prefix of a hash as an integer.
+func leading(hash []byte) uint32 {
+	return (uint32(hash[0])<<16 | uint32(hash[1])<<8 | uint32(hash[2])) >> (24 - prefixBits)
+}
+
+func parsePrefix(prefix string) (uint32, error) {
+	if len(prefix) != PrefixLen {
+		return 0, fmt.Errorf("pwned: prefix must be %d hex digits: %q", PrefixLen, prefix)
+	}
+	b, err := hex.DecodeString(prefix + "0")
+	if err != nil {
+		return 0, fmt.Errorf("pwned: invalid prefix: %q", prefix)
+	}
+	return leading(b), nil
+}
+
+// Range returns every entry whose hash begins with prefix, which must be PrefixLen hex digits.
+// It is the local equivalent of the Pwned Passwords range API.
+func (x *Index) Range(prefix string) (entries []Entry, err error) {
+	want, err := parsePrefix(prefix)
+	if err != nil {
+		return
+	}
+
+	var searchErr error
+	i := sort.Search(x.n, func(i int) bool {
+		hash, _, err := x.record(i)
+		if err != nil {
+			searchErr = err
+			return true
+		}
+		return leading(hash) >= want
+	})
+	if searchErr != nil {
+		return nil, searchErr
+	}
+
+	for ; i < x.n; i++ {
+		hash, count, err := x.record(i)
+		if err != nil {
+			return nil, err
+		}
+		if leading(hash) != want {
+			break
+		}
+		entries = append(entries, Entry{Suffix: strings.ToUpper(hex.EncodeToString(hash))[PrefixLen:], Count: count})
+	}
+
+	return
+}
+
+// Count returns the number of times plaintext has been seen in breaches, or 0 if it is not in the index.
+func (x *Index) Count(plaintext []byte) (int, error) {
+	sum := sha1.Sum(plaintext)
+	digest := strings.ToUpper(hex.EncodeToString(sum[:]))
+
+	entries, err := x.Range(digest[:PrefixLen])
+	if err != nil {
+		return 0, err
+	}
+
+	suffix := []byte(digest[PrefixLen:])
+	for _, e := range entries {
+		if bytes.Equal([]byte(e.Suffix), suffix) {
+			return e.Count, nil
+		}
+	}
+	return 0, nil
+}
+
+// Check returns an *ErrPwned if plaintext is in the index.
+// It has the signature of an mcf.PreCreateHook.
+func (x *Index) Check(plaintext []byte) error {
+	n, err := x.Count(plaintext)
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		return &ErrPwned{Count: n}
+	}
+	return nil
+}
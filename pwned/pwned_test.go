@@ -0,0 +1,212 @@
+package pwned
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gyepisam/mcf"
+	_ "github.com/gyepisam/mcf/pbkdf2"
+)
+
+func buildFixture(t *testing.T) *Index {
+	f, err := os.Open("testdata/pwned.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var buf bytes.Buffer
+	n, err := Build(&buf, f)
+	if err != nil {
+		t.Fatalf("Build: unexpected error: %s", err)
+	}
+	if n != 7 {
+		t.Fatalf("Build: expected 7 hashes, got %d", n)
+	}
+
+	x, err := New(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("New: unexpected error: %s", err)
+	}
+	return x
+}
+
+func TestCount(t *testing.T) {
+	x := buildFixture(t)
+
+	for i, v := range []struct {
+		plain string
+		count int
+	}{
+		{"password", 9659365},
+		{"123456", 37359195},
+		{"hunter2", 47152},
+		{"correct horse battery staple", 0},
+		{"", 0},
+	} {
+		n, err := x.Count([]byte(v.plain))
+		if err != nil {
+			t.Errorf("%d: unexpected error: %s", i, err)
+			continue
+		}
+		if n != v.count {
+			t.Errorf("%d: %q: expected count %d, got %d", i, v.plain, v.count, n)
+		}
+	}
+}
+
+func TestRange(t *testing.T) {
+	x := buildFixture(t)
+
+	entries, err := x.Range("5baa6")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %d: %v", len(entries), entries)
+	}
+	if want, got := "1E4C9B93F3F0682250B6CF8331B7EE68FD8", entries[1].Suffix; want != got {
+		t.Errorf("suffix: expected %s, got %s", want, got)
+	}
+
+	for _, prefix := range []string{"00000", "FFFFF", "5BAA5", "5BAA7"} {
+		entries, err := x.Range(prefix)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", prefix, err)
+		}
+		if len(entries) != 0 {
+			t.Errorf("%s: expected no entries, got %v", prefix, entries)
+		}
+	}
+
+	for _, prefix := range []string{"", "5BAA", "5BAA61", "XXXXX"} {
+		if _, err := x.Range(prefix); err == nil {
+			t.Errorf("%q: expected error, got nil", prefix)
+		}
+	}
+}
+
+func TestBuildRanges(t *testing.T) {
+	var dump, ranges bytes.Buffer
+
+	f, err := os.Open("testdata/pwned.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if _, err := Build(&dump, f); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := BuildRanges(&ranges, "testdata/ranges")
+	if err != nil {
+		t.Fatalf("BuildRanges: unexpected error: %s", err)
+	}
+	if n != 7 {
+		t.Errorf("BuildRanges: expected 7 hashes, got %d", n)
+	}
+
+	if !bytes.Equal(dump.Bytes(), ranges.Bytes()) {
+		t.Errorf("BuildRanges output differs from Build output")
+	}
+}
+
+func TestBuildErrors(t *testing.T) {
+	for i, input := range []string{
+		"5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8\n",                                               // no count
+		"5BAA61E4C9B93F3F0682250B6CF8331B7EE68F:1\n",                                               // short hash
+		"ZBAA61E4C9B93F3F0682250B6CF8331B7EE68FD8:1\n",                                             // not hex
+		"5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8:-1\n",                                            // bad count
+		"7C4A8D09CA3762AF61E59520943DC26494F8941B:1\n5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8:1\n", // unsorted
+		"5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8:1\n5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8:1\n", // duplicate
+	} {
+		var buf bytes.Buffer
+		if _, err := Build(&buf, strings.NewReader(input)); err == nil {
+			t.Errorf("%d: expected error, got nil", i)
+		}
+	}
+}
+
+func TestBadIndex(t *testing.T) {
+	for i, input := range []string{"", "MCFPWND", "MCFPWND2", "MCFPWND1x"} {
+		if _, err := New(strings.NewReader(input), int64(len(input))); err == nil {
+			t.Errorf("%d: expected error, got nil", i)
+		}
+	}
+}
+
+func TestPreCreateHook(t *testing.T) {
+	x := buildFixture(t)
+	defer mcf.AddPreCreateHook(x.Check)()
+
+	_, err := mcf.Create("letmein")
+	e, ok := err.(*ErrPwned)
+	if !ok {
+		t.Fatalf("expected *ErrPwned, got %v", err)
+	}
+	if e.Count != 511087 {
+		t.Errorf("expected count 511087, got %d", e.Count)
+	}
+
+	if strings.Contains(err.Error(), "letmein") {
+		t.Errorf("error message contains the plaintext: %s", err)
+	}
+
+	encoded, err := mcf.Create("s3cr3t pa55phrase")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	isValid, err := mcf.Verify("s3cr3t pa55phrase", encoded)
+	if err != nil || !isValid {
+		t.Errorf("Verify: expected true, nil; got %t, %v", isValid, err)
+	}
+}
+
+func TestRemovePreCreateHook(t *testing.T) {
+	x := buildFixture(t)
+	mcf.AddPreCreateHook(x.Check)()
+
+	if _, err := mcf.Create("letmein"); err != nil {
+		t.Errorf("Create after the hook was removed: unexpected error: %s", err)
+	}
+}
+
+// eofReader returns io.EOF along with a read that reaches the end of its input, as an io.ReaderAt may.
+type eofReader struct {
+	*bytes.Reader
+}
+
+func (r eofReader) ReadAt(p []byte, off int64) (int, error) {
+	n, err := r.Reader.ReadAt(p, off)
+	if err == nil && off+int64(n) == r.Size() {
+		err = io.EOF
+	}
+	return n, err
+}
+
+func TestEOF(t *testing.T) {
+	f, err := os.Open("testdata/pwned.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var buf bytes.Buffer
+	if _, err := Build(&buf, f); err != nil {
+		t.Fatal(err)
+	}
+	x, err := New(eofReader{bytes.NewReader(buf.Bytes())}, int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The last record.
+	if n, err := x.Count([]byte("hunter2")); err != nil || n != 47152 {
+		t.Errorf("Count: expected 47152, nil; got %d, %v", n, err)
+	}
+}
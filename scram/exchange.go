@@ -0,0 +1,454 @@
+// Copyright 2014 Gyepi Sam. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package scram
+
+// The SCRAM exchange of RFC 5802, with SHA-256 as in RFC 7677:
+//
+//	C: n,,n=user,r=rOprNGfwEbeRWgbNEkqO                                  client-first-message
+//	S: r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,s=...,i=4096  server-first-message
+//	C: c=biws,r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,p=... client-final-message
+//	S: v=6rriTRBi23WpRR/wtup+mMhUZUn/dB5nLTJRsjl95G4=                     server-final-message
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// An Error is a failed exchange. Its Reason is one of the server-error values of RFC 5802,
+// such as "invalid-proof", which the server sends to the client in its final message.
+type Error struct {
+	Reason string
+}
+
+func (e *Error) Error() string {
+	return "scram: " + e.Reason
+}
+
+// Reasons for failure, as defined by RFC 5802.
+const (
+	ReasonInvalidEncoding             = "invalid-encoding"
+	ReasonExtensionsNotSupported      = "extensions-not-supported"
+	ReasonInvalidProof                = "invalid-proof"
+	ReasonChannelBindingsDontMatch    = "channel-bindings-dont-match"
+	ReasonServerDoesSupportCB         = "server-does-support-channel-binding"
+	ReasonChannelBindingNotSupported  = "channel-binding-not-supported"
+	ReasonUnsupportedChannelBinding   = "unsupported-channel-binding-type"
+	ReasonInvalidUsernameEncoding     = "invalid-username-encoding"
+	ReasonOtherError                  = "other-error"
+	reasonUnexpectedMessage           = "unexpected-message" // not sent; the exchange is out of order.
+	reasonServerSignatureDoesNotMatch = "server-signature-does-not-match"
+)
+
+func fail(reason string) error {
+	return &Error{reason}
+}
+
+// ErrUnexpectedMessage is returned when the messages of an exchange are out of order.
+var ErrUnexpectedMessage = fail(reasonUnexpectedMessage)
+
+// ErrServerSignature is returned by Client.Verify if the server does not know the verifier.
+var ErrServerSignature = fail(reasonServerSignatureDoesNotMatch)
+
+// nonceLen is the number of random bytes in a nonce.
+const nonceLen = 18
+
+func randomNonce() (string, error) {
+	b := make([]byte, nonceLen)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(b), nil
+}
+
+// validNonce reports whether a nonce consists of printable characters other than ','.
+func validNonce(nonce string) bool {
+	for i := 0; i < len(nonce); i++ {
+		if c := nonce[i]; c < 0x21 || c > 0x7e || c == ',' {
+			return false
+		}
+	}
+	return nonce != ""
+}
+
+// ChannelBinding describes the channel binding offered by a Server or required by a Client,
+// such as the "tls-server-end-point" data of RFC 5929. Channel binding is used only with the
+// SCRAM-SHA-256-PLUS mechanism, which the application advertises if it sets a Server's ChannelBinding.
+type ChannelBinding struct {
+	Type string
+	Data []byte
+}
+
+// A Server authenticates a client with SCRAM-SHA-256. It handles a single exchange.
+type Server struct {
+	// Lookup returns the verifier of a user, as produced by Verifier.String or by mcf.Create
+	// with mcf.SCRAM as the default encoding. To avoid revealing which users exist, it may return
+	// a made-up verifier for an unknown user, and the exchange then fails with an invalid proof.
+	Lookup func(username string) (encoded string, err error)
+
+	// ChannelBinding, if set, is the channel binding the server supports.
+	ChannelBinding *ChannelBinding
+
+	// Nonce produces the server's part of the nonce. If nil, it is random.
+	Nonce func() (string, error)
+
+	state       int
+	username    string
+	gs2Header   string
+	nonce       string
+	clientFirst string
+	serverFirst string
+	verifier    *Verifier
+}
+
+// States of an exchange.
+const (
+	stateStart = iota
+	stateFirst // The first messages have been exchanged.
+	stateFinal // The client has sent its final message.
+	stateDone
+)
+
+// NewServer returns a Server that finds verifiers with lookup.
+func NewServer(lookup func(username string) (encoded string, err error)) *Server {
+	return &Server{Lookup: lookup}
+}
+
+// Username returns the name of the user, once the client's first message is processed.
+func (s *Server) Username() string {
+	return s.username
+}
+
+// ServerFirst processes the client-first-message and returns the server-first-message.
+// Any error ends the exchange.
+func (s *Server) ServerFirst(clientFirst []byte) (serverFirst []byte, err error) {
+	if s.state != stateStart {
+		return nil, ErrUnexpectedMessage
+	}
+	s.state = stateDone
+
+	msg := string(clientFirst)
+
+	// gs2-header: cbind-flag "," [authzid] ","
+	fields := strings.SplitN(msg, ",", 3)
+	if len(fields) != 3 {
+		return nil, fail(ReasonInvalidEncoding)
+	}
+	cbFlag, authzid, bare := fields[0], fields[1], fields[2]
+
+	switch {
+	case cbFlag == "n":
+	case cbFlag == "y":
+		if s.ChannelBinding != nil {
+			return nil, fail(ReasonServerDoesSupportCB)
+		}
+	case strings.HasPrefix(cbFlag, "p="):
+		if s.ChannelBinding == nil {
+			return nil, fail(ReasonChannelBindingNotSupported)
+		}
+		if cbFlag[2:] != s.ChannelBinding.Type {
+			return nil, fail(ReasonUnsupportedChannelBinding)
+		}
+	default:
+		return nil, fail(ReasonInvalidEncoding)
+	}
+
+	attrs, err := parseAttributes(bare)
+	if err != nil {
+		return nil, err
+	}
+	if len(attrs) > 0 && attrs[0].key == 'm' {
+		return nil, fail(ReasonExtensionsNotSupported)
+	}
+	if len(attrs) < 2 || attrs[0].key != 'n' || attrs[1].key != 'r' {
+		return nil, fail(ReasonInvalidEncoding)
+	}
+
+	username, ok := decodeName(attrs[0].value)
+	if !ok {
+		return nil, fail(ReasonInvalidUsernameEncoding)
+	}
+	if authzid != "" {
+		if a, ok := decodeName(strings.TrimPrefix(authzid, "a=")); !ok || !strings.HasPrefix(authzid, "a=") || a != username {
+			return nil, fail(ReasonOtherError)
+		}
+	}
+
+	clientNonce := attrs[1].value
+	if !validNonce(clientNonce) {
+		return nil, fail(ReasonInvalidEncoding)
+	}
+
+	encoded, err := s.Lookup(username)
+	if err != nil {
+		return nil, err
+	}
+	if s.verifier, err = ParseVerifier([]byte(encoded)); err != nil {
+		return nil, err
+	}
+
+	newNonce := s.Nonce
+	if newNonce == nil {
+		newNonce = randomNonce
+	}
+	serverNonce, err := newNonce()
+	if err != nil {
+		return nil, fmt.Errorf("scram: nonce: %w", err)
+	}
+	if !validNonce(serverNonce) {
+		return nil, errors.New("scram: invalid server nonce")
+	}
+
+	s.username = username
+	s.gs2Header = cbFlag + "," + authzid + ","
+	s.nonce = clientNonce + serverNonce
+	s.clientFirst = bare
+	s.serverFirst = fmt.Sprintf("r=%s,s=%s,i=%d", s.nonce, base64.StdEncoding.EncodeToString(s.verifier.Salt), s.verifier.Iterations)
+	s.state = stateFirst
+
+	return []byte(s.serverFirst), nil
+}
+
+// ServerFinal processes the client-final-message and returns the server-final-message,
+// which must be sent to the client whether or not there is an error.
+// The client is authenticated if, and only if, the error is nil.
+// An *Error describes why the client was not authenticated.
+func (s *Server) ServerFinal(clientFinal []byte) (serverFinal []byte, err error) {
+	if s.state != stateFirst {
+		return nil, ErrUnexpectedMessage
+	}
+	s.state = stateDone
+
+	failure := func(reason string) ([]byte, error) {
+		return []byte("e=" + reason), fail(reason)
+	}
+
+	msg := string(clientFinal)
+	i := strings.LastIndex(msg, ",p=")
+	if i < 0 {
+		return failure(ReasonInvalidEncoding)
+	}
+	withoutProof := msg[:i]
+
+	attrs, err := parseAttributes(msg)
+	if err != nil || len(attrs) < 3 || attrs[0].key != 'c' || attrs[1].key != 'r' || attrs[len(attrs)-1].key != 'p' {
+		return failure(ReasonInvalidEncoding)
+	}
+
+	cbind, err := base64.StdEncoding.DecodeString(attrs[0].value)
+	if err != nil {
+		return failure(ReasonInvalidEncoding)
+	}
+	want := []byte(s.gs2Header)
+	if strings.HasPrefix(s.gs2Header, "p=") {
+		want = append(want, s.ChannelBinding.Data...)
+	}
+	if !hmac.Equal(cbind, want) {
+		return failure(ReasonChannelBindingsDontMatch)
+	}
+
+	if attrs[1].value != s.nonce {
+		return failure(ReasonOtherError)
+	}
+
+	proof, err := base64.StdEncoding.DecodeString(attrs[len(attrs)-1].value)
+	if err != nil || len(proof) != prf.Size() {
+		return failure(ReasonInvalidEncoding)
+	}
+
+	authMessage := []byte(s.clientFirst + "," + s.serverFirst + "," + withoutProof)
+
+	// ClientKey = ClientProof XOR HMAC(StoredKey, AuthMessage); the proof is valid if H(ClientKey) = StoredKey.
+	key := computeHMAC(s.verifier.StoredKey, authMessage)
+	for i := range key {
+		key[i] ^= proof[i]
+	}
+	if !hmac.Equal(storedKey(key), s.verifier.StoredKey) {
+		return failure(ReasonInvalidProof)
+	}
+
+	signature := computeHMAC(s.verifier.ServerKey, authMessage)
+	return []byte("v=" + base64.StdEncoding.EncodeToString(signature)), nil
+}
+
+// A Client authenticates to a Server with SCRAM-SHA-256. It handles a single exchange.
+type Client struct {
+	Username string
+	Password string
+
+	// ChannelBinding, if set, is required by the client.
+	ChannelBinding *ChannelBinding
+
+	// Nonce produces the client's part of the nonce. If nil, it is random.
+	Nonce func() (string, error)
+
+	state       int
+	gs2Header   string
+	nonce       string
+	clientFirst string
+	signature   []byte
+}
+
+// NewClient returns a Client for a user.
+func NewClient(username, password string) *Client {
+	return &Client{Username: username, Password: password}
+}
+
+// ClientFirst returns the client-first-message.
+func (c *Client) ClientFirst() (clientFirst []byte, err error) {
+	if c.state != stateStart {
+		return nil, ErrUnexpectedMessage
+	}
+	c.state = stateDone
+
+	newNonce := c.Nonce
+	if newNonce == nil {
+		newNonce = randomNonce
+	}
+	if c.nonce, err = newNonce(); err != nil {
+		return nil, fmt.Errorf("scram: nonce: %w", err)
+	}
+	if !validNonce(c.nonce) {
+		return nil, errors.New("scram: invalid client nonce")
+	}
+
+	c.gs2Header = "n,,"
+	if c.ChannelBinding != nil {
+		c.gs2Header = "p=" + c.ChannelBinding.Type + ",,"
+	}
+	c.clientFirst = "n=" + encodeName(c.Username) + ",r=" + c.nonce
+	c.state = stateFirst
+
+	return []byte(c.gs2Header + c.clientFirst), nil
+}
+
+// ClientFinal processes the server-first-message and returns the client-final-message.
+func (c *Client) ClientFinal(serverFirst []byte) (clientFinal []byte, err error) {
+	if c.state != stateFirst {
+		return nil, ErrUnexpectedMessage
+	}
+	c.state = stateDone
+
+	attrs, err := parseAttributes(string(serverFirst))
+	if err != nil {
+		return nil, err
+	}
+	if len(attrs) > 0 && attrs[0].key == 'm' {
+		return nil, fail(ReasonExtensionsNotSupported)
+	}
+	if len(attrs) < 3 || attrs[0].key != 'r' || attrs[1].key != 's' || attrs[2].key != 'i' {
+		return nil, fail(ReasonInvalidEncoding)
+	}
+
+	nonce := attrs[0].value
+	if !strings.HasPrefix(nonce, c.nonce) || len(nonce) == len(c.nonce) || !validNonce(nonce) {
+		return nil, fail(ReasonOtherError)
+	}
+	salt, err := base64.StdEncoding.DecodeString(attrs[1].value)
+	if err != nil {
+		return nil, fail(ReasonInvalidEncoding)
+	}
+	iterations, err := strconv.Atoi(attrs[2].value)
+	if err != nil {
+		return nil, fail(ReasonInvalidEncoding)
+	}
+
+	salted, err := saltedPassword([]byte(c.Password), salt, iterations)
+	if err != nil {
+		return nil, err
+	}
+
+	cbind := []byte(c.gs2Header)
+	if c.ChannelBinding != nil {
+		cbind = append(cbind, c.ChannelBinding.Data...)
+	}
+	withoutProof := "c=" + base64.StdEncoding.EncodeToString(cbind) + ",r=" + nonce
+	authMessage := []byte(c.clientFirst + "," + string(serverFirst) + "," + withoutProof)
+
+	key := clientKey(salted)
+	proof := computeHMAC(storedKey(key), authMessage)
+	for i := range proof {
+		proof[i] ^= key[i]
+	}
+
+	c.signature = computeHMAC(computeHMAC(salted, []byte("Server Key")), authMessage)
+	c.state = stateFinal
+
+	return []byte(withoutProof + ",p=" + base64.StdEncoding.EncodeToString(proof)), nil
+}
+
+// Verify processes the server-final-message and returns nil if the server is authenticated.
+// If the server rejected the client, the error is an *Error with the server's reason.
+func (c *Client) Verify(serverFinal []byte) error {
+	if c.state != stateFinal {
+		return ErrUnexpectedMessage
+	}
+	c.state = stateDone
+
+	attrs, err := parseAttributes(string(serverFinal))
+	if err != nil || len(attrs) == 0 {
+		return fail(ReasonInvalidEncoding)
+	}
+	switch attrs[0].key {
+	case 'e':
+		return fail(attrs[0].value)
+	case 'v':
+		signature, err := base64.StdEncoding.DecodeString(attrs[0].value)
+		if err != nil || !hmac.Equal(signature, c.signature) {
+			return ErrServerSignature
+		}
+		return nil
+	}
+	return fail(ReasonInvalidEncoding)
+}
+
+// An attribute is a key=value pair of a SCRAM message.
+type attribute struct {
+	key   byte
+	value string
+}
+
+// parseAttributes splits a message into its attributes.
+func parseAttributes(msg string) ([]attribute, error) {
+	var attrs []attribute
+	for _, f := range strings.Split(msg, ",") {
+		if len(f) < 2 || f[1] != '=' || !('a' <= f[0] && f[0] <= 'z' || 'A' <= f[0] && f[0] <= 'Z') {
+			return nil, fail(ReasonInvalidEncoding)
+		}
+		attrs = append(attrs, attribute{f[0], f[2:]})
+	}
+	return attrs, nil
+}
+
+// encodeName escapes ',' and '=' in a user name, as =2C and =3D.
+func encodeName(name string) string {
+	return strings.NewReplacer("=", "=3D", ",", "=2C").Replace(name)
+}
+
+// decodeName reverses encodeName. It returns false for any other use of '='.
+func decodeName(s string) (string, bool) {
+	var b bytes.Buffer
+	for i := 0; i < len(s); i++ {
+		if s[i] != '=' {
+			b.WriteByte(s[i])
+			continue
+		}
+		switch {
+		case strings.HasPrefix(s[i:], "=2C"):
+			b.WriteByte(',')
+		case strings.HasPrefix(s[i:], "=3D"):
+			b.WriteByte('=')
+		default:
+			return "", false
+		}
+		i += 2
+	}
+	return b.String(), s != ""
+}
@@ -0,0 +1,297 @@
+// Copyright 2014 Gyepi Sam. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Package scram produces and verifies SCRAM-SHA-256 verifiers, as defined by RFC 5802 and RFC 7677,
+in the form PostgreSQL stores in pg_authid.rolpassword:
+
+	SCRAM-SHA-256$4096:c2FsdHNhbHRzYWx0c2FsdA==$CozjiHjNmiMjBgH9gZ7qn0QWud6nrVP6E72IBh477bQ=:VKers2x8MllK1Rh7LZLqtj6KOTzoFWJpIaokMX3blS0=
+
+that is, SCRAM-SHA-256$iterations:salt$StoredKey:ServerKey, all base64 encoded.
+A verifier can be sent to PostgreSQL in place of a plaintext password, with
+
+	ALTER ROLE name PASSWORD 'SCRAM-SHA-256$...'
+
+and the StoredKey and ServerKey of a verifier are all the server side of a SCRAM exchange needs.
+A Server conducts that exchange, so that passwords never cross the wire, using verifiers that are
+created and stored like any other mcf password. A Client is provided for tests and for Go clients.
+
+The salted password is computed with the pbkdf2 package, whose limits apply, so importing this package
+also registers the pbkdf2 encoder. PostgreSQL normalizes passwords with SASLprep before hashing them,
+which this package does not, so non-ASCII passwords may need to be normalized by the caller.
+*/
+package scram
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+
+	"github.com/gyepisam/mcf"
+	"github.com/gyepisam/mcf/encoder"
+	"github.com/gyepisam/mcf/pbkdf2"
+)
+
+// Mechanism is the name of the SASL mechanism, and the id of encoded verifiers.
+const Mechanism = "SCRAM-SHA-256"
+
+// Default values, which match those of PostgreSQL.
+// These are exported to show default values.
+// See GetConfig and SetConfig(...) to change them.
+const (
+	DefaultIterations = 4096
+	DefaultSaltLen    = 16
+)
+
+// prf is the hash function of the mechanism.
+const prf = pbkdf2.SHA256
+
+// Config contains the parameters used to create new verifiers.
+type Config struct {
+	Iterations int // Number of PBKDF2 iterations. At most pbkdf2.MaxIterations.
+	SaltLen    int // Length of salt in bytes. At least mcf.MinSaltLen and at most pbkdf2.MaxSaltLen.
+
+	SaltMine mcf.SaltMiner // Source of salt; see mcf.SaltMiner.
+}
+
+// GetConfig returns the default configuration used to create new verifiers.
+// The return value can be modified and used as a parameter to SetConfig.
+func GetConfig() Config {
+	return Config{Iterations: DefaultIterations, SaltLen: DefaultSaltLen}
+}
+
+// SetConfig sets the configuration used to create new verifiers.
+func SetConfig(config Config) error {
+	if err := config.validate(); err != nil {
+		return err
+	}
+	return register(config)
+}
+
+func (c *Config) validate() error {
+	switch {
+	case c.Iterations < 1 || c.Iterations > pbkdf2.MaxIterations:
+		return pbkdf2.ErrInvalidParameter{Name: "Iterations", Value: c.Iterations}
+	case c.SaltLen < mcf.MinSaltLen || c.SaltLen > pbkdf2.MaxSaltLen:
+		return pbkdf2.ErrInvalidParameter{Name: "SaltLen", Value: c.SaltLen}
+	}
+	return nil
+}
+
+// A Verifier is what a server stores to authenticate a user with SCRAM.
+type Verifier struct {
+	Iterations int
+	Salt       []byte
+	StoredKey  []byte // H(ClientKey), with which the server checks the client's proof.
+	ServerKey  []byte // With which the server proves that it knows the verifier.
+}
+
+// NewVerifier computes the verifier of a password.
+// The iterations and salt length must be within the limits of the pbkdf2 package,
+// and the salt must be at least mcf.MinSaltLen bytes.
+func NewVerifier(password, salt []byte, iterations int) (*Verifier, error) {
+	if len(salt) < mcf.MinSaltLen || len(salt) > pbkdf2.MaxSaltLen {
+		return nil, fmt.Errorf("%w: scram: salt length %d is not between %d and %d", encoder.ErrInvalidParams, len(salt), mcf.MinSaltLen, pbkdf2.MaxSaltLen)
+	}
+	return newVerifier(password, salt, iterations)
+}
+
+// newVerifier computes the verifier of a password without checking the salt,
+// so that stored verifiers with shorter salts can still be checked.
+func newVerifier(password, salt []byte, iterations int) (*Verifier, error) {
+	salted, err := saltedPassword(password, salt, iterations)
+	if err != nil {
+		return nil, err
+	}
+	return &Verifier{
+		Iterations: iterations,
+		Salt:       salt,
+		StoredKey:  storedKey(clientKey(salted)),
+		ServerKey:  computeHMAC(salted, []byte("Server Key")),
+	}, nil
+}
+
+// saltedPassword is Hi(password, salt, iterations) of RFC 5802, which is PBKDF2 with HMAC as the PRF.
+func saltedPassword(password, salt []byte, iterations int) ([]byte, error) {
+	if iterations < 1 || iterations > pbkdf2.MaxIterations {
+		return nil, fmt.Errorf("%w: scram: iterations %d is not between 1 and %d", encoder.ErrInvalidParams, iterations, pbkdf2.MaxIterations)
+	}
+	c := pbkdf2.Config{Hash: prf, Iterations: iterations, KeyLen: prf.Size()}
+	return c.Key(password, salt)
+}
+
+func clientKey(salted []byte) []byte {
+	return computeHMAC(salted, []byte("Client Key"))
+}
+
+func storedKey(clientKey []byte) []byte {
+	h := prf.Func()()
+	h.Write(clientKey)
+	return h.Sum(nil)
+}
+
+func computeHMAC(key, msg []byte) []byte {
+	m := hmac.New(prf.Func(), key)
+	m.Write(msg)
+	return m.Sum(nil)
+}
+
+// Verify returns true if password produces the verifier.
+func (v *Verifier) Verify(password []byte) (isValid bool, err error) {
+	w, err := newVerifier(password, v.Salt, v.Iterations)
+	if err != nil {
+		return false, err
+	}
+	return subtle.ConstantTimeCompare(w.StoredKey, v.StoredKey)&subtle.ConstantTimeCompare(w.ServerKey, v.ServerKey) == 1, nil
+}
+
+// String returns the verifier in the form stored by PostgreSQL.
+func (v *Verifier) String() string {
+	enc := base64.StdEncoding.EncodeToString
+	return fmt.Sprintf("%s$%d:%s$%s:%s", Mechanism, v.Iterations, enc(v.Salt), enc(v.StoredKey), enc(v.ServerKey))
+}
+
+func malformed(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: scram: "+format, append([]interface{}{encoder.ErrMalformedHash}, args...)...)
+}
+
+// ParseVerifier parses a verifier in the form produced by Verifier.String.
+// Errors wrap mcf.ErrMalformedHash or mcf.ErrInvalidParams.
+func ParseVerifier(encoded []byte) (*Verifier, error) {
+	prefix := []byte(Mechanism + "$")
+	if !bytes.HasPrefix(encoded, prefix) {
+		return nil, malformed("missing %s prefix", prefix)
+	}
+
+	fields := bytes.Split(encoded[len(prefix):], []byte{'$'})
+	if len(fields) != 2 {
+		return nil, malformed("expected iterations:salt$StoredKey:ServerKey")
+	}
+	params, keys := bytes.Split(fields[0], []byte{':'}), bytes.Split(fields[1], []byte{':'})
+	if len(params) != 2 || len(keys) != 2 {
+		return nil, malformed("expected iterations:salt$StoredKey:ServerKey")
+	}
+
+	iterations, err := strconv.Atoi(string(params[0]))
+	if err != nil {
+		return nil, malformed("invalid iterations %q", params[0])
+	}
+	if iterations < 1 || iterations > pbkdf2.MaxIterations {
+		return nil, fmt.Errorf("%w: scram: iterations %d is not between 1 and %d", encoder.ErrInvalidParams, iterations, pbkdf2.MaxIterations)
+	}
+
+	v := &Verifier{Iterations: iterations}
+	for _, f := range []struct {
+		name string
+		src  []byte
+		dst  *[]byte
+	}{
+		{"salt", params[1], &v.Salt},
+		{"StoredKey", keys[0], &v.StoredKey},
+		{"ServerKey", keys[1], &v.ServerKey},
+	} {
+		b, err := base64.StdEncoding.DecodeString(string(f.src))
+		if err != nil {
+			return nil, malformed("invalid %s: %s", f.name, err)
+		}
+		*f.dst = b
+	}
+
+	switch {
+	case len(v.Salt) == 0 || len(v.Salt) > pbkdf2.MaxSaltLen:
+		return nil, malformed("salt must be between 1 and %d bytes", pbkdf2.MaxSaltLen)
+	case len(v.StoredKey) != prf.Size() || len(v.ServerKey) != prf.Size():
+		return nil, malformed("keys must be %d bytes", prf.Size())
+	}
+	return v, nil
+}
+
+type scram struct {
+	config Config
+}
+
+func register(config Config) error {
+	return mcf.Register(mcf.SCRAM, &scram{config})
+}
+
+func init() {
+	if err := register(GetConfig()); err != nil {
+		panic(err)
+	}
+	mcf.RegisterFactory(mcf.SCRAM, mcf.NewFactory((*Config).validate, func(c Config) encoder.Encoder { return &scram{c} }))
+	if err := mcf.RegisterDetectorPrecedence(mcf.PrecedencePrefix, detect); err != nil {
+		panic(err)
+	}
+}
+
+// detect recognizes verifiers for mcf, which cannot find them by their id.
+func detect(encoded []byte) (encoder.Encoder, bool) {
+	if !bytes.HasPrefix(encoded, []byte(Mechanism+"$")) {
+		return nil, false
+	}
+	return mcf.Registered(mcf.SCRAM), true
+}
+
+// Id returns the identifier of verifiers, which, unlike those of Modular Crypt Format
+// passwords, is not preceded by a separator. mcf recognizes verifiers with a detector instead.
+func (s *scram) Id() []byte {
+	return []byte(Mechanism)
+}
+
+// Create produces a verifier from a plaintext password using the current configuration.
+func (s *scram) Create(plaintext []byte) (encoded []byte, err error) {
+	salt, err := mcf.Salt(s.config.SaltLen, s.config.SaltMine)
+	if err != nil {
+		return nil, fmt.Errorf("scram: salt: %w", err)
+	}
+	v, err := NewVerifier(plaintext, salt, s.config.Iterations)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(v.String()), nil
+}
+
+// Verify returns true if the plaintext password produces the verifier.
+func (s *scram) Verify(plaintext, encoded []byte) (isValid bool, err error) {
+	v, err := ParseVerifier(encoded)
+	if err != nil {
+		return false, err
+	}
+	return v.Verify(plaintext)
+}
+
+// Verify returns true if plaintext produces the encoded verifier.
+// Unlike mcf.Verify, it accepts only verifiers.
+func Verify(plaintext, encoded string) (isValid bool, err error) {
+	v, err := ParseVerifier([]byte(encoded))
+	if err != nil {
+		return false, err
+	}
+	return v.Verify([]byte(plaintext))
+}
+
+// IsCurrent returns true if the verifier has at least as many iterations and as much salt
+// as the current configuration.
+func (s *scram) IsCurrent(encoded []byte) (isCurrent bool, err error) {
+	v, err := ParseVerifier(encoded)
+	if err != nil {
+		return false, err
+	}
+	return v.Iterations >= s.config.Iterations && len(v.Salt) >= s.config.SaltLen, nil
+}
+
+// ParseParams implements encoder.ParamsParser. The parameters are "iterations" and "saltlen".
+func (s *scram) ParseParams(encoded []byte) (map[string]string, error) {
+	v, err := ParseVerifier(encoded)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{
+		"iterations": strconv.Itoa(v.Iterations),
+		"saltlen":    strconv.Itoa(len(v.Salt)),
+	}, nil
+}
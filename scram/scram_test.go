@@ -0,0 +1,342 @@
+// Copyright 2014 Gyepi Sam. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package scram
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/gyepisam/mcf"
+	"github.com/gyepisam/mcf/encoder"
+	"github.com/gyepisam/mcf/pbkdf2"
+)
+
+// Verifiers computed with Python's hashlib and hmac modules.
+// The first uses the salt and password of the example in RFC 7677.
+var testVectors = []struct {
+	plaintext string
+	encoded   string
+}{
+	{"pencil", "SCRAM-SHA-256$4096:W22ZaJ0SNY7soEsUEjb6gQ==$WG5d8oPm3OtcPnkdi4Uo7BkeZkBFzpcXkuLmtbsT4qY=:wfPLwcE6nTWhTAmQ7tl2KeoiWGPlZqQxSrmfPwDl2dU="},
+	{"password", "SCRAM-SHA-256$4096:c2FsdHNhbHRzYWx0c2FsdA==$CozjiHjNmiMjBgH9gZ7qn0QWud6nrVP6E72IBh477bQ=:VKers2x8MllK1Rh7LZLqtj6KOTzoFWJpIaokMX3blS0="},
+	{"", "SCRAM-SHA-256$1:MDEyMzQ1Njc4OWFiY2RlZg==$kaGhv2Dbs2e8yUNaqcE3ex9QpYZt17zsRT2AUPvmwLE=:ggw/rb8IOfl2NHsGU8CVSr/iCt587CpXeNgzSXKxKNo="},
+}
+
+func TestVectors(t *testing.T) {
+	enc := mcf.Registered(mcf.SCRAM)
+
+	for i, v := range testVectors {
+		isValid, err := Verify(v.plaintext, v.encoded)
+		if err != nil || !isValid {
+			t.Errorf("%d: Verify: got (%t, %v), expected (true, nil)", i, isValid, err)
+		}
+		isValid, err = enc.Verify([]byte(v.plaintext+"x"), []byte(v.encoded))
+		if err != nil || isValid {
+			t.Errorf("%d: Verify wrong password: got (%t, %v), expected (false, nil)", i, isValid, err)
+		}
+		isValid, err = mcf.Verify(v.plaintext, v.encoded)
+		if err != nil || !isValid {
+			t.Errorf("%d: mcf.Verify: got (%t, %v), expected (true, nil)", i, isValid, err)
+		}
+
+		verifier, err := ParseVerifier([]byte(v.encoded))
+		if err != nil {
+			t.Fatalf("%d: %s", i, err)
+		}
+		if s := verifier.String(); s != v.encoded {
+			t.Errorf("%d: String: got %s, expected %s", i, s, v.encoded)
+		}
+
+		w, err := NewVerifier([]byte(v.plaintext), verifier.Salt, verifier.Iterations)
+		if err != nil {
+			t.Fatalf("%d: %s", i, err)
+		}
+		if !bytes.Equal(w.StoredKey, verifier.StoredKey) || !bytes.Equal(w.ServerKey, verifier.ServerKey) {
+			t.Errorf("%d: NewVerifier: got %s, expected %s", i, w, v.encoded)
+		}
+	}
+}
+
+func TestCreate(t *testing.T) {
+	defer SetConfig(GetConfig())
+
+	salt := []byte("0123456789abcdef")
+	config := GetConfig()
+	config.Iterations = 1
+	config.SaltMine = func(n int) ([]byte, error) { return salt[:n], nil }
+	if err := SetConfig(config); err != nil {
+		t.Fatal(err)
+	}
+
+	enc := mcf.Registered(mcf.SCRAM)
+	encoded, err := enc.Create(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := testVectors[2].encoded; string(encoded) != want {
+		t.Errorf("Create: got %s, expected %s", encoded, want)
+	}
+
+	if isCurrent, err := enc.IsCurrent(encoded); err != nil || !isCurrent {
+		t.Errorf("IsCurrent: got (%t, %v), expected (true, nil)", isCurrent, err)
+	}
+
+	config.Iterations = 2
+	if err := SetConfig(config); err != nil {
+		t.Fatal(err)
+	}
+	if isCurrent, err := mcf.Registered(mcf.SCRAM).IsCurrent(encoded); err != nil || isCurrent {
+		t.Errorf("IsCurrent with more iterations: got (%t, %v), expected (false, nil)", isCurrent, err)
+	}
+
+	params, err := enc.(encoder.ParamsParser).ParseParams(encoded)
+	if err != nil || params["iterations"] != "1" || params["saltlen"] != "16" {
+		t.Errorf("ParseParams: got (%v, %v)", params, err)
+	}
+
+	for _, c := range []Config{{Iterations: 0, SaltLen: 16}, {Iterations: 1, SaltLen: mcf.MinSaltLen - 1}} {
+		if err := SetConfig(c); !errors.Is(err, mcf.ErrInvalidParams) {
+			t.Errorf("SetConfig %+v: got %v, expected %v", c, err, mcf.ErrInvalidParams)
+		}
+	}
+}
+
+func TestMalformed(t *testing.T) {
+	key := base64.StdEncoding.EncodeToString(make([]byte, 32))
+	for _, tt := range []struct {
+		encoded string
+		want    error
+	}{
+		{"", mcf.ErrMalformedHash},
+		{"SCRAM-SHA-1$4096:c2FsdA==$" + key + ":" + key, mcf.ErrMalformedHash},
+		{"SCRAM-SHA-256$4096:c2FsdA==$" + key, mcf.ErrMalformedHash},
+		{"SCRAM-SHA-256$4096$c2FsdA==$" + key + ":" + key, mcf.ErrMalformedHash},
+		{"SCRAM-SHA-256$x:c2FsdA==$" + key + ":" + key, mcf.ErrMalformedHash},
+		{"SCRAM-SHA-256$0:c2FsdA==$" + key + ":" + key, mcf.ErrInvalidParams},
+		{"SCRAM-SHA-256$4096:$" + key + ":" + key, mcf.ErrMalformedHash},
+		{"SCRAM-SHA-256$4096:c2FsdA=$" + key + ":" + key, mcf.ErrMalformedHash},
+		{"SCRAM-SHA-256$4096:c2FsdA==$c2FsdA==:" + key, mcf.ErrMalformedHash},
+	} {
+		if isValid, err := Verify("password", tt.encoded); isValid || !errors.Is(err, tt.want) {
+			t.Errorf("Verify %q: got (%t, %v), expected (false, %v)", tt.encoded, isValid, err, tt.want)
+		}
+	}
+
+	if _, err := NewVerifier([]byte("password"), []byte("saltsaltsaltsalt"), 0); !errors.Is(err, mcf.ErrInvalidParams) {
+		t.Errorf("NewVerifier with no iterations: got %v, expected %v", err, mcf.ErrInvalidParams)
+	}
+}
+
+func TestNewVerifier(t *testing.T) {
+	v, err := NewVerifier([]byte("password"), []byte("saltsalt"), 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := ParseVerifier([]byte(v.String()))
+	if err != nil {
+		t.Fatalf("ParseVerifier %s: %s", v, err)
+	}
+	if w.Iterations != v.Iterations || !bytes.Equal(w.Salt, v.Salt) || !bytes.Equal(w.StoredKey, v.StoredKey) || !bytes.Equal(w.ServerKey, v.ServerKey) {
+		t.Errorf("ParseVerifier: got %s, expected %s", w, v)
+	}
+	if isValid, err := w.Verify([]byte("password")); err != nil || !isValid {
+		t.Errorf("Verify: got (%t, %v), expected (true, nil)", isValid, err)
+	}
+
+	for _, salt := range [][]byte{nil, {}, []byte("saltsal"), make([]byte, pbkdf2.MaxSaltLen+1)} {
+		if _, err := NewVerifier([]byte("password"), salt, 16); !errors.Is(err, mcf.ErrInvalidParams) {
+			t.Errorf("NewVerifier with %d bytes of salt: got %v, expected %v", len(salt), err, mcf.ErrInvalidParams)
+		}
+	}
+
+	// Stored verifiers with short salts are still checked.
+	short, err := newVerifier([]byte("password"), []byte("salt"), 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoded := short.String()
+	if isValid, err := Verify("password", encoded); err != nil || !isValid {
+		t.Errorf("Verify %s: got (%t, %v), expected (true, nil)", encoded, isValid, err)
+	}
+}
+
+// The example exchange of RFC 7677, section 3.
+var rfc7677 = []string{
+	"n,,n=user,r=rOprNGfwEbeRWgbNEkqO",
+	"r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096",
+	"c=biws,r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,p=dHzbZapWIk4jUhN+Ute9ytag9zjfMHgsqmmiz7AndVQ=",
+	"v=6rriTRBi23WpRR/wtup+mMhUZUn/dB5nLTJRsjl95G4=",
+}
+
+func fixedNonce(nonce string) func() (string, error) {
+	return func() (string, error) { return nonce, nil }
+}
+
+// lookup returns the verifier of "user", whose password is "pencil".
+func lookup(username string) (string, error) {
+	if username != "user" {
+		return "", errors.New("unknown user")
+	}
+	return testVectors[0].encoded, nil
+}
+
+// exchange runs an exchange between c and s and returns the messages and the errors of the server and client.
+func exchange(c *Client, s *Server) (msgs []string, serverErr, clientErr error) {
+	clientFirst, err := c.ClientFirst()
+	if err != nil {
+		return msgs, nil, err
+	}
+	msgs = append(msgs, string(clientFirst))
+
+	serverFirst, err := s.ServerFirst(clientFirst)
+	if err != nil {
+		return msgs, err, nil
+	}
+	msgs = append(msgs, string(serverFirst))
+
+	clientFinal, err := c.ClientFinal(serverFirst)
+	if err != nil {
+		return msgs, nil, err
+	}
+	msgs = append(msgs, string(clientFinal))
+
+	serverFinal, serverErr := s.ServerFinal(clientFinal)
+	msgs = append(msgs, string(serverFinal))
+
+	return msgs, serverErr, c.Verify(serverFinal)
+}
+
+func TestExchange(t *testing.T) {
+	c := NewClient("user", "pencil")
+	c.Nonce = fixedNonce("rOprNGfwEbeRWgbNEkqO")
+	s := NewServer(lookup)
+	s.Nonce = fixedNonce("%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0")
+
+	msgs, serverErr, clientErr := exchange(c, s)
+	if serverErr != nil || clientErr != nil {
+		t.Fatalf("got errors %v and %v", serverErr, clientErr)
+	}
+	for i, msg := range msgs {
+		if msg != rfc7677[i] {
+			t.Errorf("message %d: got %s, expected %s", i, msg, rfc7677[i])
+		}
+	}
+	if s.Username() != "user" {
+		t.Errorf("Username: got %q", s.Username())
+	}
+
+	if _, err := s.ServerFinal([]byte(rfc7677[2])); err != ErrUnexpectedMessage {
+		t.Errorf("replayed ServerFinal: got %v, expected %v", err, ErrUnexpectedMessage)
+	}
+	if _, err := c.ClientFirst(); err != ErrUnexpectedMessage {
+		t.Errorf("repeated ClientFirst: got %v, expected %v", err, ErrUnexpectedMessage)
+	}
+}
+
+func reason(err error) string {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Reason
+	}
+	return fmt.Sprint(err)
+}
+
+func TestExchangeFailures(t *testing.T) {
+	cb := &ChannelBinding{Type: "tls-server-end-point", Data: []byte("certificate hash")}
+
+	// A random nonce, channel binding and a verifier created by mcf.
+	encoded, err := mcf.Registered(mcf.SCRAM).Create([]byte("pencil"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewClient("user", "pencil")
+	c.ChannelBinding = cb
+	s := NewServer(func(string) (string, error) { return string(encoded), nil })
+	s.ChannelBinding = cb
+	if _, serverErr, clientErr := exchange(c, s); serverErr != nil || clientErr != nil {
+		t.Errorf("channel binding: got errors %v and %v", serverErr, clientErr)
+	}
+
+	for _, tt := range []struct {
+		name           string
+		client         *Client
+		serverCB       *ChannelBinding
+		serverReason   string
+		clientReason   string
+		serverRejected bool // in its first message
+	}{
+		{"wrong password", NewClient("user", "pencils"), nil, ReasonInvalidProof, ReasonInvalidProof, false},
+		{"unknown user", NewClient("nobody", "pencil"), nil, "unknown user", "", true},
+		{"channel binding unsupported", &Client{Username: "user", Password: "pencil", ChannelBinding: cb}, nil, ReasonChannelBindingNotSupported, "", true},
+		{"channel binding type", &Client{Username: "user", Password: "pencil", ChannelBinding: &ChannelBinding{Type: "tls-unique"}}, cb, ReasonUnsupportedChannelBinding, "", true},
+		{"channel binding data", &Client{Username: "user", Password: "pencil", ChannelBinding: &ChannelBinding{Type: cb.Type, Data: []byte("other")}}, cb, ReasonChannelBindingsDontMatch, ReasonChannelBindingsDontMatch, false},
+	} {
+		s := NewServer(lookup)
+		s.ChannelBinding = tt.serverCB
+		_, serverErr, clientErr := exchange(tt.client, s)
+		if reason(serverErr) != tt.serverReason {
+			t.Errorf("%s: server: got %v, expected %s", tt.name, serverErr, tt.serverReason)
+		}
+		if !tt.serverRejected && reason(clientErr) != tt.clientReason {
+			t.Errorf("%s: client: got %v, expected %s", tt.name, clientErr, tt.clientReason)
+		}
+	}
+
+	for _, tt := range []struct {
+		name, clientFirst, reason string
+		cb                        *ChannelBinding
+	}{
+		{"no gs2 header", "n=user,r=abc", ReasonInvalidEncoding, nil},
+		{"downgrade", "y,,n=user,r=abc", ReasonServerDoesSupportCB, cb},
+		{"mandatory extension", "n,,m=ext,n=user,r=abc", ReasonExtensionsNotSupported, nil},
+		{"bad username", "n,,n=us=er,r=abc", ReasonInvalidUsernameEncoding, nil},
+		{"other authzid", "n,a=admin,n=user,r=abc", ReasonOtherError, nil},
+		{"bad nonce", "n,,n=user,r=", ReasonInvalidEncoding, nil},
+	} {
+		s := NewServer(lookup)
+		s.ChannelBinding = tt.cb
+		if _, err := s.ServerFirst([]byte(tt.clientFirst)); reason(err) != tt.reason {
+			t.Errorf("%s: got %v, expected %s", tt.name, err, tt.reason)
+		}
+	}
+
+	// Tampered final messages.
+	for _, clientFinal := range []string{
+		"c=biws,r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k1,p=dHzbZapWIk4jUhN+Ute9ytag9zjfMHgsqmmiz7AndVQ=",
+		"c=eSws,r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,p=dHzbZapWIk4jUhN+Ute9ytag9zjfMHgsqmmiz7AndVQ=",
+		"c=biws,r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,p=eHzbZapWIk4jUhN+Ute9ytag9zjfMHgsqmmiz7AndVQ=",
+		"c=biws,r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0",
+	} {
+		s := NewServer(lookup)
+		s.Nonce = fixedNonce("%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0")
+		if _, err := s.ServerFirst([]byte(rfc7677[0])); err != nil {
+			t.Fatal(err)
+		}
+		serverFinal, err := s.ServerFinal([]byte(clientFinal))
+		if err == nil || !bytes.HasPrefix(serverFinal, []byte("e=")) {
+			t.Errorf("ServerFinal %s: got (%s, %v), expected an error", clientFinal, serverFinal, err)
+		}
+	}
+
+	// A server that does not know the verifier.
+	c = NewClient("user", "pencil")
+	c.Nonce = fixedNonce("rOprNGfwEbeRWgbNEkqO")
+	c.ClientFirst()
+	c.ClientFinal([]byte(rfc7677[1]))
+	if err := c.Verify([]byte("v=" + base64.StdEncoding.EncodeToString(make([]byte, 32)))); err != ErrServerSignature {
+		t.Errorf("Verify: got %v, expected %v", err, ErrServerSignature)
+	}
+}
+
+func TestNames(t *testing.T) {
+	for _, name := range []string{"user", "a,b=c", "=2C"} {
+		if got, ok := decodeName(encodeName(name)); !ok || got != name {
+			t.Errorf("decodeName(encodeName(%q)): got (%q, %t)", name, got, ok)
+		}
+	}
+}
@@ -0,0 +1,299 @@
+// Copyright 2014 Gyepi Sam. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package scrypt
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"math/bits"
+	"strconv"
+
+	"github.com/gyepisam/mcf/bridge"
+	"github.com/gyepisam/mcf/encoder"
+	"github.com/gyepisam/mcf/internal/crypt64"
+)
+
+// A Format is a way of writing scrypt passwords. The encoder verifies passwords in all of them,
+// and uses Config.Format for new passwords.
+type Format int
+
+// Formats.
+const (
+	// Native is the format of this package:
+	// $scrypt$KeyLen=32,N=16384,R=8,P=1$salt$key
+	Native Format = iota
+
+	// PHC is the format of passlib, and of the PHC string format:
+	// $scrypt$ln=14,r=8,p=1$salt$key
+	PHC
+
+	// Libsodium is the format of libsodium's crypto_pwhash_scryptsalsa208sha256_str, and of libxcrypt:
+	// $7$C6..../....salt$key
+	// The key length is always 32 bytes.
+	Libsodium
+
+	// Lambdaworks is the format of the com.lambdaworks.crypto Java library:
+	// $s0$e0801$salt$key
+	// The key length is always 32 bytes and R and P are at most 255.
+	Lambdaworks
+)
+
+func (f Format) String() string {
+	switch f {
+	case Native:
+		return "native"
+	case PHC:
+		return "phc"
+	case Libsodium:
+		return "libsodium"
+	case Lambdaworks:
+		return "lambdaworks"
+	}
+	return "unknown"
+}
+
+// fixedKeyLen is the key length of the Libsodium and Lambdaworks formats.
+const fixedKeyLen = 32
+
+var (
+	idLibsodium   = []byte("7")
+	idLambdaworks = []byte("s0")
+	prefixPHC     = []byte("$scrypt$ln=")
+)
+
+// scryptEncoder extends the bridge encoder, which handles the Native format, with the other formats.
+type scryptEncoder struct {
+	*bridge.Encoder
+	config Config
+}
+
+// Aliases implements encoder.Aliaser.
+func (e *scryptEncoder) Aliases() [][]byte {
+	return [][]byte{idLibsodium, idLambdaworks}
+}
+
+// Create produces an encoded password in the configured Format.
+func (e *scryptEncoder) Create(plaintext []byte) ([]byte, error) {
+	c := e.config
+	if c.Format == Native {
+		return e.Encoder.Create(plaintext)
+	}
+
+	salt, err := c.Salt()
+	if err != nil {
+		return nil, err
+	}
+	if c.Format == Libsodium {
+		// The salt is used as written.
+		salt = crypt64.Encode(salt)
+	}
+
+	key, err := c.Key(plaintext, salt)
+	if err != nil {
+		return nil, err
+	}
+
+	return formatDialect(c.Format, &c, salt, key), nil
+}
+
+// Verify verifies a password in any Format.
+func (e *scryptEncoder) Verify(plaintext, encoded []byte) (isValid bool, err error) {
+	f, c, salt, key, err := parseDialect(encoded)
+	if f == Native {
+		return e.Encoder.Verify(plaintext, encoded)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	testKey, err := c.Key(plaintext, salt)
+	if err != nil {
+		return false, err
+	}
+	return subtle.ConstantTimeCompare(key, testKey) == 1, nil
+}
+
+// IsCurrent compares the parameters of a password in any Format with the current configuration.
+func (e *scryptEncoder) IsCurrent(encoded []byte) (isCurrent bool, err error) {
+	f, c, _, _, err := parseDialect(encoded)
+	if f == Native {
+		return e.Encoder.IsCurrent(encoded)
+	}
+	if err != nil {
+		return false, err
+	}
+	current := e.config
+	return c.AtLeast(&current), nil
+}
+
+// ParseParams reports the parameters of a password in any Format with the names used by the Native format.
+func (e *scryptEncoder) ParseParams(encoded []byte) (map[string]string, error) {
+	f, c, _, _, err := parseDialect(encoded)
+	if f == Native {
+		return e.Encoder.ParseParams(encoded)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{
+		"KeyLen": strconv.Itoa(c.KeyLen),
+		"N":      strconv.Itoa(c.N),
+		"R":      strconv.Itoa(c.R),
+		"P":      strconv.Itoa(c.P),
+	}, nil
+}
+
+func malformed(f Format, msg string, args ...interface{}) error {
+	return fmt.Errorf("%w: scrypt: %s: "+msg, append([]interface{}{encoder.ErrMalformedHash, f}, args...)...)
+}
+
+// parseDialect parses an encoded password in a format other than Native.
+// It returns Native, and nothing else, for any other password.
+// The parameters are validated.
+func parseDialect(encoded []byte) (f Format, c *Config, salt, key []byte, err error) {
+	fields := bytes.Split(encoded, []byte{'$'})
+	if len(fields) < 2 || len(fields[0]) != 0 {
+		return Native, nil, nil, nil, nil
+	}
+
+	c = &Config{}
+
+	switch {
+	case bytes.Equal(fields[1], idLibsodium):
+		f = Libsodium
+		err = parseLibsodium(encoded, c, &salt, &key)
+
+	case bytes.Equal(fields[1], idLambdaworks):
+		f = Lambdaworks
+		if len(fields) != 5 {
+			return f, nil, nil, nil, malformed(f, "expected 4 fields")
+		}
+		var v uint64
+		v, err = strconv.ParseUint(string(fields[2]), 16, 64)
+		if err != nil || v>>16 > 63 {
+			return f, nil, nil, nil, malformed(f, "invalid parameters %q", fields[2])
+		}
+		c.N, c.R, c.P = 1<<(v>>16), int(v>>8&0xff), int(v&0xff)
+		if salt, err = base64.StdEncoding.DecodeString(string(fields[3])); err != nil {
+			return f, nil, nil, nil, malformed(f, "invalid salt: %s", err)
+		}
+		if key, err = base64.StdEncoding.DecodeString(string(fields[4])); err != nil {
+			return f, nil, nil, nil, malformed(f, "invalid key: %s", err)
+		}
+
+	case bytes.HasPrefix(encoded, prefixPHC):
+		f = PHC
+		if len(fields) != 5 {
+			return f, nil, nil, nil, malformed(f, "expected 4 fields")
+		}
+		var ln int
+		_, err = fmt.Sscanf(string(fields[2]), phcFormat, &ln, &c.R, &c.P)
+		if err != nil || ln < 1 || ln > 62 || fmt.Sprintf(phcFormat, ln, c.R, c.P) != string(fields[2]) {
+			return f, nil, nil, nil, fmt.Errorf("%w: scrypt: %s: invalid parameters %q", encoder.ErrInvalidParams, f, fields[2])
+		}
+		c.N = 1 << ln
+		if salt, err = base64.RawStdEncoding.DecodeString(string(fields[3])); err != nil {
+			return f, nil, nil, nil, malformed(f, "invalid salt: %s", err)
+		}
+		if key, err = base64.RawStdEncoding.DecodeString(string(fields[4])); err != nil {
+			return f, nil, nil, nil, malformed(f, "invalid key: %s", err)
+		}
+
+	default:
+		return Native, nil, nil, nil, nil
+	}
+
+	if err != nil {
+		return f, nil, nil, nil, err
+	}
+
+	if f != PHC && len(key) != fixedKeyLen {
+		return f, nil, nil, nil, malformed(f, "key must be %d bytes", fixedKeyLen)
+	}
+	c.KeyLen, c.SaltLen = len(key), len(salt)
+	if err = c.validate(); err != nil {
+		return f, nil, nil, nil, err
+	}
+	return f, c, salt, key, nil
+}
+
+// Keep these together.
+const phcFormat = "ln=%d,r=%d,p=%d"
+
+// parseLibsodium parses $7$ passwords, whose salt is the text between the parameters and the key.
+func parseLibsodium(encoded []byte, c *Config, salt, key *[]byte) error {
+	const f = Libsodium
+
+	if len(encoded) < len("$7$")+11 {
+		return malformed(f, "parameters are too short")
+	}
+	src := encoded[len("$7$"):]
+
+	nLog2 := crypt64.Index(src[0])
+	r, rok := decodeFixed(src[1:6])
+	p, pok := decodeFixed(src[6:11])
+	if nLog2 < 0 || nLog2 > 62 || !rok || !pok {
+		return malformed(f, "invalid parameters %q", src[:11])
+	}
+	c.N, c.R, c.P = 1<<nLog2, int(r), int(p)
+
+	src = src[11:]
+	i := bytes.LastIndexByte(src, '$')
+	if i < 0 {
+		return malformed(f, "missing key")
+	}
+	*salt = src[:i]
+
+	var ok bool
+	if *key, ok = crypt64.Decode(src[i+1:]); !ok {
+		return malformed(f, "invalid key")
+	}
+	return nil
+}
+
+// formatDialect writes an encoded password in a Format other than Native.
+func formatDialect(f Format, c *Config, salt, key []byte) []byte {
+	var b []byte
+	switch f {
+	case PHC:
+		b = fmt.Appendf(b, "$scrypt$"+phcFormat+"$", bits.Len(uint(c.N))-1, c.R, c.P)
+		b = append(b, base64.RawStdEncoding.EncodeToString(salt)...)
+		b = append(b, '$')
+		b = append(b, base64.RawStdEncoding.EncodeToString(key)...)
+	case Libsodium:
+		b = append(b, "$7$"...)
+		b = append(b, crypt64.Alphabet[bits.Len(uint(c.N))-1])
+		b = encodeFixed(b, uint32(c.R))
+		b = encodeFixed(b, uint32(c.P))
+		b = append(b, salt...)
+		b = append(b, '$')
+		b = append(b, crypt64.Encode(key)...)
+	case Lambdaworks:
+		b = fmt.Appendf(b, "$s0$%x$", (bits.Len(uint(c.N))-1)<<16|c.R<<8|c.P)
+		b = append(b, base64.StdEncoding.EncodeToString(salt)...)
+		b = append(b, '$')
+		b = append(b, base64.StdEncoding.EncodeToString(key)...)
+	}
+	return b
+}
+
+// encodeFixed appends the 30 bit value x as 5 characters.
+func encodeFixed(dst []byte, x uint32) []byte {
+	return crypt64.AppendUint32(dst, x, 5)
+}
+
+// decodeFixed reverses encodeFixed.
+func decodeFixed(src []byte) (x uint32, ok bool) {
+	for i := 0; i < 5; i++ {
+		c := crypt64.Index(src[i])
+		if c < 0 {
+			return 0, false
+		}
+		x |= uint32(c) << (6 * i)
+	}
+	return x, true
+}
@@ -12,6 +12,7 @@ import (
 
 	"github.com/gyepisam/mcf"
 	"github.com/gyepisam/mcf/bridge"
+	"github.com/gyepisam/mcf/encoder"
 )
 
 // Circa 2014 work factors.
@@ -29,17 +30,25 @@ const (
 // Use the GetConfig() and SetConfig() combination to change any desired parameters.
 type Config struct {
 	KeyLen  int //Key output size in bytes.
-	SaltLen int // Length of salt in bytes.
+	SaltLen int // Length of salt in bytes. At least mcf.MinSaltLen and at most MaxSaltLen.
 
 	N int // CPU/Memory cost. Must be a power of two.
 	R int // block size parameter.
 	P int // parallelization parameter.
+
+	SaltMine mcf.SaltMiner // Source of salt, or the package SaltMine if nil; see mcf.SaltMiner.
+
+	// Format is the format of new passwords. Passwords in any format are verified.
+	Format Format
 }
 
 // Custom source of salt, normally unset.
 // Set this if you need to override the user of rand.Reader and
 // use a custom salt producer.
 // Also useful for testing.
+// It is used when Config.SaltMine is nil.
+//
+// Deprecated: Set Config.SaltMine instead.
 var SaltMine mcf.SaltMiner = nil
 
 // ErrInvalidParameter is returned by SetConfig if any of the provided parameters
@@ -54,6 +63,9 @@ func (e ErrInvalidParameter) Error() string {
 	return fmt.Sprintf("parameter %s has invalid value: %d", e.Name, e.Value)
 }
 
+// Unwrap returns encoder.ErrInvalidParams.
+func (e ErrInvalidParameter) Unwrap() error { return encoder.ErrInvalidParams }
+
 // Config returns the default configuration used to create new scrypt password hashes.
 // The return value can be modified and used as a parameter to SetConfig
 func GetConfig() Config {
@@ -79,7 +91,7 @@ Here is an example that doubles the default work factor.
 */
 func SetConfig(config Config) error {
 	c := &config
-	err := c.validate()
+	err := c.validateNew()
 	if err != nil {
 		return err
 	}
@@ -87,16 +99,18 @@ func SetConfig(config Config) error {
 	return register(config)
 }
 
-func register(config Config) error {
+func newEncoder(config Config) encoder.Encoder {
 	// Constructor function. Provide fresh copy each time.
 	fn := func() bridge.Implementer {
 		c := config
 		return &c
 	}
 
-	enc := bridge.New([]byte("scrypt"), fn)
+	return &scryptEncoder{Encoder: bridge.New([]byte("scrypt"), fn).(*bridge.Encoder), config: config}
+}
 
-	return mcf.Register(mcf.SCRYPT, enc)
+func register(config Config) error {
+	return mcf.Register(mcf.SCRYPT, newEncoder(config))
 }
 
 func init() {
@@ -104,12 +118,58 @@ func init() {
 	if err != nil {
 		panic(err)
 	}
+	mcf.RegisterFactory(mcf.SCRYPT, mcf.NewFactory((*Config).validateNew, newEncoder))
 }
 
+// Limits on parameters, which guard against the exhaustion of memory or time by a corrupt or malicious
+// encoded password. They may be raised if necessary.
+var (
+	MaxMemory  int64 = 1 << 30 // Maximum memory used by the algorithm, which is 128 * N * R bytes.
+	MaxWork    int64 = 1 << 34 // Maximum value of 128 * N * R * P, which is proportional to running time.
+	MaxKeyLen        = 1024    // Maximum key length in bytes.
+	MaxSaltLen       = 1024    // Maximum salt length in bytes.
+)
+
 func (c *Config) validate() error {
-	//punt, cheat and see if the underlying algorithm complains!
-	_, err := c.Key([]byte("password"), []byte("salt"))
-	return err
+	switch {
+	case c.KeyLen < 1 || c.KeyLen > MaxKeyLen:
+		return ErrInvalidParameter{"KeyLen", c.KeyLen}
+	case c.SaltLen < 0 || c.SaltLen > MaxSaltLen:
+		return ErrInvalidParameter{"SaltLen", c.SaltLen}
+	case c.N <= 1 || c.N&(c.N-1) != 0:
+		return ErrInvalidParameter{"N", c.N}
+	case c.R < 1:
+		return ErrInvalidParameter{"R", c.R}
+	case c.P < 1 || int64(c.R)*int64(c.P) >= 1<<30:
+		return ErrInvalidParameter{"P", c.P}
+	case c.Format < Native || c.Format > Lambdaworks:
+		return ErrInvalidParameter{"Format", int(c.Format)}
+	case (c.Format == Libsodium || c.Format == Lambdaworks) && c.KeyLen != fixedKeyLen:
+		return ErrInvalidParameter{"KeyLen", c.KeyLen}
+	case c.Format == Lambdaworks && c.R > 255:
+		return ErrInvalidParameter{"R", c.R}
+	case c.Format == Lambdaworks && c.P > 255:
+		return ErrInvalidParameter{"P", c.P}
+	}
+
+	// Checked in steps to avoid overflow.
+	mem := 128 * int64(c.R)
+	if mem > MaxMemory || int64(c.N) > MaxMemory/mem {
+		return ErrInvalidParameter{"N", c.N}
+	}
+	if mem*int64(c.N) > MaxWork/int64(c.P) {
+		return ErrInvalidParameter{"P", c.P}
+	}
+
+	return nil
+}
+
+// validateNew validates the configuration of new passwords, which, unlike stored ones, must be salted.
+func (c *Config) validateNew() error {
+	if c.SaltLen < mcf.MinSaltLen {
+		return ErrInvalidParameter{"SaltLen", c.SaltLen}
+	}
+	return c.validate()
 }
 
 // Keep these together.
@@ -124,25 +184,46 @@ func (c *Config) Params() string {
 func (c *Config) SetParams(s string) error {
 	_, err := fmt.Sscanf(s, format, &c.KeyLen, &c.N, &c.R, &c.P)
 	if err != nil {
-		return err
+		return fmt.Errorf("%w: scrypt: %s", encoder.ErrInvalidParams, err)
 	}
 	return c.validate()
 }
 
-// Salt produces SaltLen bytes of random data.
+// Salt produces SaltLen bytes from SaltMine, or of random data if it is not set.
 func (c *Config) Salt() ([]byte, error) {
-	return mcf.Salt(c.SaltLen, SaltMine)
+	miner := c.SaltMine
+	if miner == nil {
+		miner = SaltMine
+	}
+	salt, err := mcf.Salt(c.SaltLen, miner)
+	if err != nil {
+		return nil, fmt.Errorf("scrypt: salt: %w", err)
+	}
+	return salt, nil
 }
 
 // Key returns an scrypt digest of password and salt using the algorithm parameters: N, r, and p.
 // The returned value is of length KeyLen.
 func (c *Config) Key(plaintext []byte, salt []byte) (b []byte, err error) {
+	if len(salt) > MaxSaltLen {
+		return nil, fmt.Errorf("%w: scrypt: salt is longer than %d bytes", encoder.ErrMalformedHash, MaxSaltLen)
+	}
 	return scrypt.Key(plaintext, salt, c.N, c.R, c.P, c.KeyLen)
 }
 
+// SetKeyLen sets the key length, which must be between 1 and MaxKeyLen.
+// It implements bridge.KeySizer.
+func (c *Config) SetKeyLen(n int) error {
+	c.KeyLen = n
+	return c.validate()
+}
+
 // AtLeast returns true if the parameters used to generate the encoded password
 // are at least as good as those currently in use.
 func (c *Config) AtLeast(current_imp bridge.Implementer) bool {
-	current := current_imp.(*Config) // ok to panic
+	current, ok := current_imp.(*Config)
+	if !ok {
+		return false
+	}
 	return !(c.N < current.N || c.R < current.R || c.P < current.P || c.KeyLen < current.KeyLen)
 }
@@ -2,6 +2,7 @@ package scrypt
 
 import (
 	"bytes"
+	"errors"
 	"github.com/gyepisam/mcf"
 	"github.com/gyepisam/mcf/password"
 
@@ -95,6 +96,18 @@ func setSalt(salt string) {
 func TestKey(t *testing.T) {
 	for i, v := range good {
 
+		if len(v.salt) < mcf.MinSaltLen {
+			// New passwords need longer salts, but the key is still computed for stored ones.
+			if err := setConfig(len(v.output), len(v.salt), v.N, v.r, v.p); !errors.Is(err, mcf.ErrInvalidParams) {
+				t.Errorf("%d: setting config with a short salt: got %v, expected %v", i, err, mcf.ErrInvalidParams)
+			}
+			c := Config{KeyLen: len(v.output), N: v.N, R: v.r, P: v.p}
+			if key, err := c.Key([]byte(v.password), []byte(v.salt)); err != nil || !bytes.Equal(key, v.output) {
+				t.Errorf("%d: Key: got (%x, %v), expected %x", i, key, err, v.output)
+			}
+			continue
+		}
+
 		err := setConfig(len(v.output), len(v.salt), v.N, v.r, v.p)
 		if err != nil {
 			t.Errorf("%d: unexpected error setting config: %s", i, err)
@@ -110,7 +123,7 @@ func TestKey(t *testing.T) {
 		passwd := password.New([]byte("scrypt"))
 		err = passwd.Parse([]byte(encoded))
 		if err != nil {
-			t.Errorf("%d: unexpected error creating password instance: %s", err)
+			t.Errorf("%d: unexpected error creating password instance: %s", i, err)
 		}
 
 		if !bytes.Equal(passwd.Salt, []byte(v.salt)) {
@@ -136,3 +149,106 @@ func TestKey(t *testing.T) {
 		}
 	}
 }
+
+// Passwords in the other formats, produced by libxcrypt, lambdaworks and passlib.
+var dialects = []struct {
+	plaintext string
+	encoded   string
+	format    Format
+}{
+	{"pleaseletmein", "$7$C6..../....SodiumChloride$kBGj9fHznVYFQMEn/qDCfrDevf9YDtcDdKvEqHJLV8D", Libsodium},
+	{"password", "$7$A6..../....saltsalt$QcoLVJSzJJA35.oEK3LhYDr1nyXCKZ98kSoDgX85oFC", Libsodium},
+	{"", "$7$96..../....x$GzZFs2mbRnpmLFRyY1bxOqe0HM2B4oCvl24iG62ueF0", Libsodium},
+	{"secret", "$s0$e0801$epIxT/h6HbbwHaehFnh/bw==$7H0vsXlY8UxxyW/BWx/9GuY7jEvGjT71GFd6O4SZND0=", Lambdaworks},
+	{"password", "$scrypt$ln=16,r=8,p=1$aM15713r3Xsvxbi31lqr1Q$nFNh2CVHVjNldFVKDHDlm4CbdRSCdEBsjjJxD+iCs5E", PHC},
+}
+
+func TestFormats(t *testing.T) {
+	defer SetConfig(GetConfig())
+
+	conf := GetConfig()
+	conf.N, conf.R, conf.P = 1<<14, 8, 1
+	if err := SetConfig(conf); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, v := range dialects {
+		if f, _, _, _, err := parseDialect([]byte(v.encoded)); f != v.format || err != nil {
+			t.Errorf("%d: parseDialect: got (%s, %v), expected (%s, nil)", i, f, err, v.format)
+		}
+
+		isValid, err := mcf.Verify(v.plaintext, v.encoded)
+		if err != nil || !isValid {
+			t.Errorf("%d: Verify: got (%t, %v), expected (true, nil)", i, isValid, err)
+		}
+		isValid, err = mcf.Verify(v.plaintext+"x", v.encoded)
+		if err != nil || isValid {
+			t.Errorf("%d: Verify wrong password: got (%t, %v), expected (false, nil)", i, isValid, err)
+		}
+
+		// All but the second and third use at least N=16384, R=8, P=1 and a 32 byte key.
+		want := i != 1 && i != 2
+		if isCurrent, err := mcf.IsCurrent(v.encoded); err != nil || isCurrent != want {
+			t.Errorf("%d: IsCurrent: got (%t, %v), expected (%t, nil)", i, isCurrent, err, want)
+		}
+	}
+
+	params, err := mcf.Registered(mcf.SCRYPT).(*scryptEncoder).ParseParams([]byte(dialects[3].encoded))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range map[string]string{"KeyLen": "32", "N": "16384", "R": "8", "P": "1"} {
+		if params[k] != v {
+			t.Errorf("ParseParams: %s: want %s, got %s", k, v, params[k])
+		}
+	}
+
+	conf.N = 16
+	conf.SaltMine = func(n int) ([]byte, error) { return bytes.Repeat([]byte{'s'}, n), nil }
+	for f, prefix := range map[Format]string{
+		Native:      "$scrypt$KeyLen=32,N=16,R=8,P=1$",
+		PHC:         "$scrypt$ln=4,r=8,p=1$c3Nzc3Nzc3Nzc3Nzc3Nzcw$",
+		Libsodium:   "$7$26..../....nBrQnBrQnBrQnBrQnBrQn/$",
+		Lambdaworks: "$s0$40801$c3Nzc3Nzc3Nzc3Nzc3Nzcw==$",
+	} {
+		conf.Format = f
+		if err := SetConfig(conf); err != nil {
+			t.Fatalf("%s: %s", f, err)
+		}
+		encoded, err := mcf.Create(plaintext)
+		if err != nil {
+			t.Fatalf("%s: %s", f, err)
+		}
+		if !bytes.HasPrefix([]byte(encoded), []byte(prefix)) {
+			t.Errorf("%s: got %s, expected prefix %s", f, encoded, prefix)
+		}
+		if isValid, err := mcf.Verify(plaintext, encoded); err != nil || !isValid {
+			t.Errorf("%s: Verify: got (%t, %v), expected (true, nil)", f, isValid, err)
+		}
+		if isCurrent, err := mcf.IsCurrent(encoded); err != nil || !isCurrent {
+			t.Errorf("%s: IsCurrent: got (%t, %v), expected (true, nil)", f, isCurrent, err)
+		}
+	}
+
+	conf.Format, conf.KeyLen = Libsodium, 64
+	if err := SetConfig(conf); err == nil {
+		t.Errorf("SetConfig: a 64 byte key in the Libsodium format should be rejected")
+	}
+
+	for _, encoded := range []string{
+		"$7$",
+		"$7$C6..../....SodiumChloride",
+		"$7$C6..../....SodiumChloride$kBGj9fHznVYFQMEn/qDCfrDevf9YDtcDdKvEqHJLV8",
+		"$7$C6...*/....SodiumChloride$kBGj9fHznVYFQMEn/qDCfrDevf9YDtcDdKvEqHJLV8D",
+		"$s0$e0801$epIxT/h6HbbwHaehFnh/bw==",
+		"$s0$e0801$epIxT/h6HbbwHaehFnh/bw==$7H0vsXlY8UxxyW/BWx/9GuY7jEvGjT71GFd6O4SZND0",
+		"$s0$zz$epIxT/h6HbbwHaehFnh/bw==$7H0vsXlY8UxxyW/BWx/9GuY7jEvGjT71GFd6O4SZND0=",
+		"$scrypt$ln=16,r=8,p=1$aM15713r3Xsvxbi31lqr1Q=$nFNh2CVHVjNldFVKDHDlm4CbdRSCdEBsjjJxD+iCs5E",
+		"$scrypt$ln=16,r=8,p=1,x=1$aM15713r3Xsvxbi31lqr1Q$nFNh2CVHVjNldFVKDHDlm4CbdRSCdEBsjjJxD+iCs5E",
+		"$scrypt$ln=40,r=8,p=1$aM15713r3Xsvxbi31lqr1Q$nFNh2CVHVjNldFVKDHDlm4CbdRSCdEBsjjJxD+iCs5E",
+	} {
+		if isValid, err := mcf.Verify(plaintext, encoded); isValid || err == nil {
+			t.Errorf("Verify(%s): got (%t, %v), expected an error", encoded, isValid, err)
+		}
+	}
+}
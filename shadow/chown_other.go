@@ -0,0 +1,12 @@
+// Copyright 2014 Gyepi Sam. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !unix
+
+package shadow
+
+import "os"
+
+// chown is a no-op where file ownership is not supported.
+func chown(f *os.File, fi os.FileInfo) error { return nil }
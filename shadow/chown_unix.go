@@ -0,0 +1,31 @@
+// Copyright 2014 Gyepi Sam. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build unix
+
+package shadow
+
+import (
+	"os"
+	"syscall"
+)
+
+// chown gives f the owner and group described by fi.
+// Nothing is done if they already match, so an unprivileged caller can rewrite its own files.
+func chown(f *os.File, fi os.FileInfo) error {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil
+	}
+
+	cur, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if c, ok := cur.Sys().(*syscall.Stat_t); ok && c.Uid == st.Uid && c.Gid == st.Gid {
+		return nil
+	}
+
+	return f.Chown(int(st.Uid), int(st.Gid))
+}
@@ -0,0 +1,240 @@
+// Copyright 2014 Gyepi Sam. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package shadow
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/gyepisam/mcf"
+)
+
+// ErrNoUser is returned when an operation refers to a user that is not in the file.
+var ErrNoUser = errors.New("shadow: no such user")
+
+// A File is a shadow or passwd file. It is safe for concurrent use within a process,
+// but does not lock the file against other programs.
+type File struct {
+	// Upgrade enables the replacement of out of date password hashes with mcf.Create
+	// when they are successfully verified. Only the password field is changed.
+	Upgrade bool
+
+	// UpgradeError, if set, is called with the error if an out of date entry cannot be upgraded.
+	// The password is valid all the same, so Verify returns true and a nil error.
+	UpgradeError func(name string, err error)
+
+	path    string
+	mu      sync.Mutex
+	lines   []string // comments and blank lines are kept as is.
+	entries []*Entry // the entry of each line, in file order; nil for comments and blank lines.
+
+	once     sync.Once
+	dummy    string
+	dummyErr error
+}
+
+// Open reads the named file.
+func Open(path string) (*File, error) {
+	f := &File{path: path}
+	return f, f.Reload()
+}
+
+// Reload rereads the file from disk.
+func (f *File) Reload() error {
+	b, err := os.ReadFile(f.path)
+	if err != nil {
+		return err
+	}
+
+	var lines []string
+	var entries []*Entry
+
+	s := bufio.NewScanner(bytes.NewReader(b))
+	for s.Scan() {
+		text := s.Text()
+		var e *Entry
+		if t := bytes.TrimSpace(s.Bytes()); len(t) > 0 && t[0] != '#' {
+			if e, err = Parse(text); err != nil {
+				return fmt.Errorf("%s: line %d: %w", f.path, len(lines)+1, err)
+			}
+		}
+		lines, entries = append(lines, text), append(entries, e)
+	}
+	if err := s.Err(); err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	f.lines, f.entries = lines, entries
+	f.mu.Unlock()
+
+	return nil
+}
+
+// find returns the line and entry of the first entry for name.
+func (f *File) find(name string) (int, *Entry) {
+	for i, e := range f.entries {
+		if e != nil && e.Name == name {
+			return i, e
+		}
+	}
+	return -1, nil
+}
+
+// Lookup returns a copy of the entry for name.
+func (f *File) Lookup(name string) (Entry, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	_, e := f.find(name)
+	if e == nil {
+		return Entry{}, false
+	}
+	return *e, true
+}
+
+// Verify returns true if plaintext is the password for name. See Entry.Verify.
+// An unknown name produces ErrNoUser, after as long as a known name would take; see mcf.Dummy.
+// If the password is valid and out of date, and Upgrade is set, the entry is rewritten with a new hash.
+// A failure to do so does not change the result; see UpgradeError.
+// The File is not locked while the password is verified, so logins do not wait for each other.
+func (f *File) Verify(name, plaintext string) (isValid bool, err error) {
+	e, ok := f.Lookup(name)
+	if !ok {
+		f.once.Do(func() { f.dummy, f.dummyErr = mcf.Dummy() })
+		if f.dummyErr != nil {
+			return false, f.dummyErr
+		}
+		// Spend the time anyway, but fail regardless.
+		mcf.Verify(plaintext, f.dummy)
+		return false, ErrNoUser
+	}
+
+	isValid, err = e.Verify(plaintext)
+	if err != nil || !isValid || !f.Upgrade {
+		return
+	}
+
+	if err := f.upgrade(name, e.Password, plaintext); err != nil && f.UpgradeError != nil {
+		f.UpgradeError(name, err)
+	}
+	return true, nil
+}
+
+// upgrade rewrites the entry for name with a new hash of plaintext if password, which was verified, is out of date.
+// The entry is left alone if its password has changed in the meantime.
+func (f *File) upgrade(name, password, plaintext string) error {
+	isCurrent, err := mcf.IsCurrent(password)
+	if err != nil || isCurrent {
+		return err
+	}
+
+	encoded, err := mcf.Create(plaintext)
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	i, e := f.find(name)
+	if e == nil || e.Password != password {
+		return nil
+	}
+	return f.replace(i, e, func(e *Entry) { e.Password = encoded })
+}
+
+// SetPassword changes the password for name and records the change date as today.
+// Any lock is removed.
+func (f *File) SetPassword(name, plaintext string) error {
+	encoded, err := mcf.Create(plaintext)
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	i, e := f.find(name)
+	if e == nil {
+		return ErrNoUser
+	}
+
+	return f.replace(i, e, func(e *Entry) {
+		e.Password = encoded
+		if e.IsShadow() {
+			e.LastChange = Day(time.Now())
+		}
+	})
+}
+
+// replace applies change to a copy of the entry at line i and saves the file.
+// The entry is only changed in memory if the file is saved.
+func (f *File) replace(i int, e *Entry, change func(*Entry)) error {
+	n := *e
+	change(&n)
+
+	lines := append([]string(nil), f.lines...)
+	lines[i] = n.String()
+
+	if err := f.save(lines); err != nil {
+		return err
+	}
+
+	f.lines, f.entries[i] = lines, &n
+	return nil
+}
+
+// save writes lines to a temporary file in the same directory and renames it into place.
+// The original permissions and, where possible, ownership are retained.
+func (f *File) save(lines []string) error {
+	var buf bytes.Buffer
+	for _, l := range lines {
+		buf.WriteString(l)
+		buf.WriteByte('\n')
+	}
+
+	fi, err := os.Stat(f.path)
+	if err != nil {
+		return err
+	}
+
+	dir, base := filepath.Split(f.path)
+	if dir == "" {
+		dir = "."
+	}
+
+	tmp, err := os.CreateTemp(dir, "."+base+".tmp")
+	if err != nil {
+		return err
+	}
+
+	_, err = tmp.Write(buf.Bytes())
+	if err == nil {
+		err = tmp.Chmod(fi.Mode().Perm())
+	}
+	if err == nil {
+		err = chown(tmp, fi)
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), f.path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
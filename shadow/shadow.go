@@ -0,0 +1,191 @@
+// Copyright 2014 Gyepi Sam. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Package shadow reads, verifies and updates shadow(5) and passwd(5) format password files.
+
+A shadow entry has nine colon separated fields:
+
+	name:password:lastchange:min:max:warn:inactive:expire:reserved
+
+The date fields count days since January 1, 1970 and the age fields count days.
+Empty numeric fields are represented as Unset.
+A password that begins with '!' or '*' is locked and never matches.
+
+Passwords are verified by the registered mcf encoders, so the appropriate encoders must be imported.
+Entries in passwd format, with seven fields, are also accepted, for systems that keep
+password hashes in /etc/passwd. An 'x' in the password field of such an entry means that
+the password is in the shadow file.
+
+Entries are rewritten field by field, so anything that is not changed is kept exactly as it was.
+*/
+package shadow
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gyepisam/mcf"
+)
+
+// Unset is the value of an empty numeric field.
+const Unset = -1
+
+// Field counts.
+const (
+	shadowFields = 9
+	passwdFields = 7
+)
+
+// Errors returned by Check and Verify.
+var (
+	ErrLocked   = errors.New("shadow: account is locked")
+	ErrExpired  = errors.New("shadow: account has expired")
+	ErrInactive = errors.New("shadow: password expired and inactivity period has passed")
+	ErrShadowed = errors.New("shadow: password is in the shadow file")
+)
+
+const day = 24 * time.Hour
+
+// Day returns the number of days since the epoch at t, as used by the date fields.
+func Day(t time.Time) int {
+	return int(t.Unix() / int64(day/time.Second))
+}
+
+// An Entry is a single line of a shadow or passwd file.
+type Entry struct {
+	Name     string
+	Password string
+
+	// Shadow fields. These are Unset for passwd entries.
+	LastChange int // Date of last password change, 0 if it must be changed at next login.
+	MinAge     int // Days before the password may be changed.
+	MaxAge     int // Days after which the password must be changed.
+	Warn       int // Days of warning before the password expires.
+	Inactive   int // Days after password expiry during which the password is still accepted.
+	Expire     int // Date on which the account expires.
+
+	fields []string // original fields, for faithful rewriting.
+}
+
+// Parse parses a line of a shadow file or a passwd file.
+func Parse(line string) (*Entry, error) {
+	fields := strings.Split(line, ":")
+	if n := len(fields); n != shadowFields && n != passwdFields {
+		return nil, fmt.Errorf("shadow: expected %d or %d fields, got %d", shadowFields, passwdFields, n)
+	}
+	if fields[0] == "" {
+		return nil, fmt.Errorf("shadow: empty name")
+	}
+
+	e := &Entry{Name: fields[0], Password: fields[1], fields: fields}
+
+	nums := []*int{&e.LastChange, &e.MinAge, &e.MaxAge, &e.Warn, &e.Inactive, &e.Expire}
+	for i, p := range nums {
+		*p = Unset
+		if len(fields) != shadowFields || fields[i+2] == "" {
+			continue
+		}
+		n, err := strconv.Atoi(fields[i+2])
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("shadow: %s: invalid field %d: %q", e.Name, i+3, fields[i+2])
+		}
+		*p = n
+	}
+
+	return e, nil
+}
+
+// IsShadow returns true if the entry is in shadow format.
+func (e *Entry) IsShadow() bool {
+	return len(e.fields) != passwdFields
+}
+
+// String produces the entry in the format it was parsed from.
+func (e *Entry) String() string {
+	if !e.IsShadow() {
+		fields := append([]string(nil), e.fields...)
+		fields[0], fields[1] = e.Name, e.Password
+		return strings.Join(fields, ":")
+	}
+
+	fields := make([]string, shadowFields)
+	copy(fields, e.fields)
+	fields[0], fields[1] = e.Name, e.Password
+	for i, n := range []int{e.LastChange, e.MinAge, e.MaxAge, e.Warn, e.Inactive, e.Expire} {
+		// Keep the original text, such as 007, unless the value has changed.
+		switch {
+		case n == fieldValue(fields[i+2]):
+		case n == Unset:
+			fields[i+2] = ""
+		default:
+			fields[i+2] = strconv.Itoa(n)
+		}
+	}
+	return strings.Join(fields, ":")
+}
+
+// fieldValue returns the value of a numeric field as parsed by Parse, or Unset if it has none.
+func fieldValue(s string) int {
+	n, err := strconv.Atoi(s)
+	if s == "" || err != nil || n < 0 {
+		return Unset
+	}
+	return n
+}
+
+// IsLocked returns true if the password is locked.
+func (e *Entry) IsLocked() bool {
+	return strings.HasPrefix(e.Password, "!") || strings.HasPrefix(e.Password, "*")
+}
+
+// PasswordExpired returns true if the password is past its maximum age at now,
+// in which case it should be changed.
+func (e *Entry) PasswordExpired(now time.Time) bool {
+	if e.LastChange == 0 {
+		return true
+	}
+	if e.LastChange == Unset || e.MaxAge == Unset {
+		return false
+	}
+	return Day(now) >= e.LastChange+e.MaxAge
+}
+
+// Check returns an error if the account may not log in at now, because it is locked or has expired.
+func (e *Entry) Check(now time.Time) error {
+	if e.IsLocked() {
+		return ErrLocked
+	}
+
+	today := Day(now)
+	if e.Expire != Unset && today >= e.Expire {
+		return ErrExpired
+	}
+
+	if e.LastChange > 0 && e.MaxAge != Unset && e.Inactive != Unset &&
+		today >= e.LastChange+e.MaxAge+e.Inactive {
+		return ErrInactive
+	}
+
+	return nil
+}
+
+// Verify returns true if plaintext is the entry's password.
+// It returns an error, without verifying the password, if Check fails.
+// An empty password never matches.
+func (e *Entry) Verify(plaintext string) (isValid bool, err error) {
+	if err = e.Check(time.Now()); err != nil {
+		return
+	}
+	if !e.IsShadow() && e.Password == "x" {
+		return false, ErrShadowed
+	}
+	if e.Password == "" {
+		return false, nil
+	}
+	return mcf.Verify(plaintext, e.Password)
+}
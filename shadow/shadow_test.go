@@ -0,0 +1,372 @@
+package shadow
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gyepisam/mcf"
+	"github.com/gyepisam/mcf/pbkdf2"
+)
+
+const alicePassword = "alice in wonderland"
+
+func TestParse(t *testing.T) {
+	for i, line := range []string{
+		"root:!:19000:0:99999:7:::",
+		"alice:$2a$04$x:19000::::::",
+		"bob:*:1:2:3:4:5:6:reserved",
+		"carol:*:+5:007:3:4:5:6:",
+		"root:x:0:0:root:/root:/bin/sh",
+	} {
+		e, err := Parse(line)
+		if err != nil {
+			t.Errorf("%d: unexpected error: %s", i, err)
+			continue
+		}
+		if s := e.String(); s != line {
+			t.Errorf("%d: round trip: expected %q, got %q", i, line, s)
+		}
+	}
+
+	e, err := Parse("bob:*:1:2:3:4:5:6:reserved")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.LastChange != 1 || e.MinAge != 2 || e.MaxAge != 3 || e.Warn != 4 || e.Inactive != 5 || e.Expire != 6 {
+		t.Errorf("unexpected field values: %+v", e)
+	}
+
+	// Only changed fields are rewritten.
+	e, err = Parse("carol:*:+5:007:3:4:5:6:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.Password, e.MaxAge, e.Expire = "!", 30, Unset
+	if s, want := e.String(), "carol:!:+5:007:30:4:5::"; s != want {
+		t.Errorf("changed fields: expected %q, got %q", want, s)
+	}
+
+	e, err = Parse("alice:$2a$04$x:19000::::::")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.MaxAge != Unset || e.Expire != Unset {
+		t.Errorf("expected unset fields: %+v", e)
+	}
+
+	for i, line := range []string{
+		"",
+		"root",
+		"root:x:0:0",
+		":x:1:2:3:4:5:6:",
+		"root:x:a:0:99999:7:::",
+		"root:x:-5:0:99999:7:::",
+	} {
+		if _, err := Parse(line); err == nil {
+			t.Errorf("%d: %q: expected error, got nil", i, line)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	today := Day(now)
+
+	for i, v := range []struct {
+		line string
+		err  error
+	}{
+		{"a:$x:19000:0:99999:7:::", nil},
+		{"a:!:19000:0:99999:7:::", ErrLocked},
+		{"a:!!:19000:0:99999:7:::", ErrLocked},
+		{"a:*:19000:0:99999:7:::", ErrLocked},
+		{"a:$x:19000:0:99999:7::1:", ErrExpired},
+		{"a:$x:19000:0:99999:7::" + strconv.Itoa(today+1) + ":", nil},
+		{"a:$x:" + strconv.Itoa(today-40) + ":0:30:7:5::", ErrInactive},
+		{"a:$x:" + strconv.Itoa(today-32) + ":0:30:7:5::", nil}, // expired, but within the inactive period.
+		{"a:$x:" + strconv.Itoa(today-40) + ":0:30:7:::", nil},  // expired, no inactive period.
+		{"a:$x:0:0:30:7:5::", nil},                              // must change at next login.
+	} {
+		e, err := Parse(v.line)
+		if err != nil {
+			t.Fatalf("%d: %s", i, err)
+		}
+		if err := e.Check(now); err != v.err {
+			t.Errorf("%d: %s: expected %v, got %v", i, v.line, v.err, err)
+		}
+	}
+
+	e, _ := Parse("a:$x:" + strconv.Itoa(today-32) + ":0:30:7:5::")
+	if !e.PasswordExpired(now) {
+		t.Errorf("expected expired password")
+	}
+	e, _ = Parse("a:$x:0:0:30:7:5::")
+	if !e.PasswordExpired(now) {
+		t.Errorf("expected expired password")
+	}
+	e, _ = Parse("a:$x:" + strconv.Itoa(today) + ":0:30:7:5::")
+	if e.PasswordExpired(now) {
+		t.Errorf("expected unexpired password")
+	}
+}
+
+func tempCopy(t *testing.T, name string, mode os.FileMode) string {
+	b, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, b, mode); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestFileVerify(t *testing.T) {
+	f, err := Open(tempCopy(t, "shadow", 0640))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, v := range []struct {
+		name, plain string
+		valid       bool
+		err         error
+	}{
+		{"alice", alicePassword, true, nil},
+		{"alice", "wrong", false, nil},
+		{"root", "", false, ErrLocked},
+		{"daemon", "x", false, ErrLocked},
+		{"bob", "bobspassword", false, ErrLocked},
+		{"carol", "carolspassword", false, ErrExpired},
+		{"dave", "carolspassword", false, ErrInactive},
+		{"erin", "", false, nil},
+		{"nobody", "x", false, ErrNoUser},
+	} {
+		isValid, err := f.Verify(v.name, v.plain)
+		if err != v.err {
+			t.Errorf("%s: expected error %v, got %v", v.name, v.err, err)
+		}
+		if isValid != v.valid {
+			t.Errorf("%s: expected %t, got %t", v.name, v.valid, isValid)
+		}
+	}
+
+	// No encoder for sha512-crypt.
+	if _, err := f.Verify("frank", "x"); err == nil {
+		t.Errorf("frank: expected error, got nil")
+	}
+}
+
+// The first of several entries for a name is always used.
+func TestDuplicate(t *testing.T) {
+	path := tempCopy(t, "shadow", 0640)
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b = append(b, "alice:!:19000:0:99999:7:::\nalice::19000:0:99999:7:::\n"...)
+	if err := os.WriteFile(path, b, 0640); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 20; i++ {
+		if isValid, err := f.Verify("alice", alicePassword); err != nil || !isValid {
+			t.Fatalf("%d: alice: expected true, nil; got %t, %v", i, isValid, err)
+		}
+	}
+}
+
+func TestPasswd(t *testing.T) {
+	f, err := Open(tempCopy(t, "passwd", 0644))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if isValid, err := f.Verify("alice", alicePassword); err != nil || !isValid {
+		t.Errorf("alice: expected true, nil; got %t, %v", isValid, err)
+	}
+	if _, err := f.Verify("root", "x"); err != ErrShadowed {
+		t.Errorf("root: expected ErrShadowed, got %v", err)
+	}
+}
+
+func TestUpgrade(t *testing.T) {
+	config := pbkdf2.GetConfig()
+	config.Iterations = 2001
+	if err := pbkdf2.SetConfig(config); err != nil {
+		t.Fatal(err)
+	}
+	defer pbkdf2.SetConfig(pbkdf2.GetConfig())
+
+	if err := mcf.SetDefault(mcf.PBKDF2); err != nil {
+		t.Fatal(err)
+	}
+
+	path := tempCopy(t, "shadow", 0640)
+	before, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Upgrade = true
+
+	if isValid, err := f.Verify("alice", alicePassword); err != nil || !isValid {
+		t.Fatalf("expected true, nil; got %t, %v", isValid, err)
+	}
+
+	after, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	old, new := strings.Split(string(before), "\n"), strings.Split(string(after), "\n")
+	if len(old) != len(new) {
+		t.Fatalf("line count changed from %d to %d", len(old), len(new))
+	}
+	for i := range old {
+		if !strings.HasPrefix(old[i], "alice:") {
+			if old[i] != new[i] {
+				t.Errorf("line %d changed from %q to %q", i+1, old[i], new[i])
+			}
+			continue
+		}
+
+		o, n := strings.Split(old[i], ":"), strings.Split(new[i], ":")
+		if !strings.Contains(n[1], "iterations=2001") {
+			t.Errorf("password was not upgraded: %s", n[1])
+		}
+		o[1], n[1] = "", ""
+		if strings.Join(o, ":") != strings.Join(n, ":") {
+			t.Errorf("fields other than password changed: %q -> %q", old[i], new[i])
+		}
+	}
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := fi.Mode().Perm(); mode != 0640 {
+		t.Errorf("expected mode 0640, got %o", mode)
+	}
+
+	g, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if isValid, err := g.Verify("alice", alicePassword); err != nil || !isValid {
+		t.Errorf("after upgrade: expected true, nil; got %t, %v", isValid, err)
+	}
+}
+
+// A valid password is valid even if its entry cannot be upgraded.
+func TestUpgradeError(t *testing.T) {
+	config := pbkdf2.GetConfig()
+	config.Iterations = 2001
+	if err := pbkdf2.SetConfig(config); err != nil {
+		t.Fatal(err)
+	}
+	defer pbkdf2.SetConfig(pbkdf2.GetConfig())
+
+	if err := mcf.SetDefault(mcf.PBKDF2); err != nil {
+		t.Fatal(err)
+	}
+	refused := errors.New("refused")
+	defer mcf.AddPreCreateHook(func(plaintext []byte) error { return refused })()
+
+	f, err := Open(tempCopy(t, "shadow", 0640))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var upgradeErr error
+	f.Upgrade = true
+	f.UpgradeError = func(name string, err error) { upgradeErr = err }
+
+	if isValid, err := f.Verify("alice", alicePassword); err != nil || !isValid {
+		t.Errorf("expected true, nil; got %t, %v", isValid, err)
+	}
+	if upgradeErr != refused {
+		t.Errorf("UpgradeError: expected %v, got %v", refused, upgradeErr)
+	}
+}
+
+// An entry whose password changes while the old one is verified is not upgraded.
+func TestUpgradeChanged(t *testing.T) {
+	config := pbkdf2.GetConfig()
+	config.Iterations = 2001
+	if err := pbkdf2.SetConfig(config); err != nil {
+		t.Fatal(err)
+	}
+	defer pbkdf2.SetConfig(pbkdf2.GetConfig())
+
+	if err := mcf.SetDefault(mcf.PBKDF2); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := Open(tempCopy(t, "shadow", 0640))
+	if err != nil {
+		t.Fatal(err)
+	}
+	old, _ := f.Lookup("alice")
+	if err := f.SetPassword("alice", "changed"); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.upgrade("alice", old.Password, alicePassword); err != nil {
+		t.Fatal(err)
+	}
+
+	if isValid, err := f.Verify("alice", "changed"); err != nil || !isValid {
+		t.Errorf("new password: expected true, nil; got %t, %v", isValid, err)
+	}
+	if isValid, err := f.Verify("alice", alicePassword); err != nil || isValid {
+		t.Errorf("old password: expected false, nil; got %t, %v", isValid, err)
+	}
+}
+
+func TestSetPassword(t *testing.T) {
+	if err := mcf.SetDefault(mcf.PBKDF2); err != nil {
+		t.Fatal(err)
+	}
+
+	path := tempCopy(t, "shadow", 0600)
+	f, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := f.SetPassword("bob", "n3w password"); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.SetPassword("nobody", "x"); err != ErrNoUser {
+		t.Errorf("expected ErrNoUser, got %v", err)
+	}
+
+	g, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e, ok := g.Lookup("bob")
+	if !ok {
+		t.Fatal("bob not found")
+	}
+	if e.LastChange != Day(time.Now()) {
+		t.Errorf("expected LastChange %d, got %d", Day(time.Now()), e.LastChange)
+	}
+	if isValid, err := g.Verify("bob", "n3w password"); err != nil || !isValid {
+		t.Errorf("expected true, nil; got %t, %v", isValid, err)
+	}
+}
@@ -0,0 +1,259 @@
+// Copyright 2014 Gyepi Sam. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package srp
+
+// The SRP-6a exchange of RFC 5054:
+//
+//	C: I                  username
+//	S: N, g, salt, B      Challenge
+//	C: A, M1              client public key and proof
+//	S: M2                 server proof
+//
+// The messages are exchanged in whatever form the application chooses.
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+)
+
+var (
+	// ErrUnexpectedMessage is returned when the messages of an exchange are out of order.
+	ErrUnexpectedMessage = errors.New("srp: unexpected message")
+
+	// ErrIllegalParameter is returned when a public key, or the scrambling parameter, is zero modulo N.
+	ErrIllegalParameter = errors.New("srp: illegal parameter")
+
+	// ErrIdentity is returned by Server.Challenge when the verifier is not bound to the username,
+	// or has no identity at all.
+	ErrIdentity = errors.New("srp: verifier does not have the identity of the user")
+
+	// ErrClientProof is returned by Server.Verify when the client does not know the password.
+	ErrClientProof = errors.New("srp: client proof does not match")
+
+	// ErrServerProof is returned by Client.Verify when the server does not know the verifier.
+	ErrServerProof = errors.New("srp: server proof does not match")
+)
+
+// secretLen is the number of random bytes in the secret exponents a and b.
+const secretLen = 32
+
+// secret reads a non-zero secret exponent from r, or from rand.Reader if r is nil.
+func secret(r io.Reader) (*big.Int, error) {
+	if r == nil {
+		r = rand.Reader
+	}
+	b := make([]byte, secretLen)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return nil, fmt.Errorf("srp: secret: %w", err)
+	}
+	n := new(big.Int).SetBytes(b)
+	if n.Sign() == 0 {
+		return nil, errors.New("srp: secret: zero")
+	}
+	return n, nil
+}
+
+// A Challenge is the server's reply to a username.
+type Challenge struct {
+	Group int    // Size of the group in bits.
+	Hash  string // Name of the hash function.
+	Salt  []byte
+	B     []byte // The server's public key.
+}
+
+// A Server authenticates a client with SRP-6a. It handles a single exchange.
+type Server struct {
+	// Lookup returns the verifier of a user, as produced by NewVerifier.
+	Lookup func(username string) (encoded string, err error)
+
+	// Rand is the source of the secret exponent. If nil, it is rand.Reader.
+	Rand io.Reader
+
+	state    int
+	username string
+	record   *record
+	suite    *suite
+	b, B     *big.Int
+	key      []byte
+}
+
+// States of an exchange.
+const (
+	stateStart     = iota
+	stateChallenge // The server has sent, or the client received, a challenge.
+	stateProof     // The client has sent its proof.
+	stateDone
+)
+
+// NewServer returns a Server that finds verifiers with lookup.
+func NewServer(lookup func(username string) (encoded string, err error)) *Server {
+	return &Server{Lookup: lookup}
+}
+
+// Username returns the username of the exchange, once Challenge has been called.
+func (s *Server) Username() string {
+	return s.username
+}
+
+// Challenge looks up the verifier of username and produces the server's public key B.
+// Errors from Lookup are returned as is.
+func (s *Server) Challenge(username string) (*Challenge, error) {
+	if s.state != stateStart {
+		return nil, ErrUnexpectedMessage
+	}
+	s.state = stateDone
+
+	encoded, err := s.Lookup(username)
+	if err != nil {
+		return nil, err
+	}
+	r, err := parseRecord([]byte(encoded))
+	if err != nil {
+		return nil, err
+	}
+	if r.config.identity != username {
+		return nil, ErrIdentity
+	}
+
+	b, err := secret(s.Rand)
+	if err != nil {
+		return nil, err
+	}
+
+	suite := r.config.suite()
+	// B = k*v + g^b % N
+	B := new(big.Int).Mul(suite.k(), r.verifier)
+	B.Add(B, new(big.Int).Exp(suite.g, b, suite.N))
+	B.Mod(B, suite.N)
+
+	s.username, s.record, s.suite, s.b, s.B = username, r, suite, b, B
+	s.state = stateChallenge
+	return &Challenge{Group: r.config.Group, Hash: r.config.Hash, Salt: r.salt, B: suite.pad(B)}, nil
+}
+
+// Verify checks the client's public key A and proof M1 and, if the client knows the password,
+// returns the server's proof M2. It returns ErrClientProof if the client does not know the password.
+func (s *Server) Verify(A, M1 []byte) (M2 []byte, err error) {
+	if s.state != stateChallenge {
+		return nil, ErrUnexpectedMessage
+	}
+	s.state = stateDone
+
+	suite := s.suite
+	a := new(big.Int).SetBytes(A)
+	if new(big.Int).Mod(a, suite.N).Sign() == 0 {
+		return nil, ErrIllegalParameter
+	}
+	u := suite.u(a, s.B)
+	if u.Sign() == 0 {
+		return nil, ErrIllegalParameter
+	}
+
+	// S = (A * v^u) ^ b % N
+	S := new(big.Int).Exp(s.record.verifier, u, suite.N)
+	S.Mul(S, a)
+	S.Exp(S, s.b, suite.N)
+
+	K, expected, M2 := suite.proofs([]byte(s.username), s.record.salt, a, s.B, S)
+	if subtle.ConstantTimeCompare(M1, expected) != 1 {
+		return nil, ErrClientProof
+	}
+	s.key = K
+	return M2, nil
+}
+
+// Key returns the session key K, once the client has been authenticated.
+func (s *Server) Key() []byte {
+	return s.key
+}
+
+// A Client authenticates to a Server with SRP-6a. It handles a single exchange.
+type Client struct {
+	Username string
+	Password string
+
+	// Rand is the source of the secret exponent. If nil, it is rand.Reader.
+	Rand io.Reader
+
+	state int
+	m2    []byte
+	key   []byte
+}
+
+// NewClient returns a Client for username and password.
+func NewClient(username, password string) *Client {
+	return &Client{Username: username, Password: password}
+}
+
+// Respond computes the client's public key A and proof M1 from the server's challenge.
+func (c *Client) Respond(challenge *Challenge) (A, M1 []byte, err error) {
+	if c.state != stateStart {
+		return nil, nil, ErrUnexpectedMessage
+	}
+	c.state = stateDone
+
+	config := Config{Group: challenge.Group, Hash: challenge.Hash}
+	if groups[config.Group] == nil {
+		return nil, nil, ErrInvalidParameter{"Group", fmt.Sprint(config.Group)}
+	}
+	if hashRank(config.Hash) < 0 {
+		return nil, nil, ErrInvalidParameter{"Hash", config.Hash}
+	}
+	suite := config.suite()
+
+	b := new(big.Int).SetBytes(challenge.B)
+	if new(big.Int).Mod(b, suite.N).Sign() == 0 {
+		return nil, nil, ErrIllegalParameter
+	}
+
+	a, err := secret(c.Rand)
+	if err != nil {
+		return nil, nil, err
+	}
+	pub := new(big.Int).Exp(suite.g, a, suite.N)
+	u := suite.u(pub, b)
+	if u.Sign() == 0 {
+		return nil, nil, ErrIllegalParameter
+	}
+	x := suite.x([]byte(c.Username), []byte(c.Password), challenge.Salt)
+
+	// S = (B - k*g^x) ^ (a + u*x) % N
+	S := new(big.Int).Exp(suite.g, x, suite.N)
+	S.Mul(S, suite.k())
+	S.Sub(b, S)
+	S.Mod(S, suite.N)
+	S.Exp(S, new(big.Int).Add(a, new(big.Int).Mul(u, x)), suite.N)
+
+	K, M1, M2 := suite.proofs([]byte(c.Username), challenge.Salt, pub, b, S)
+	c.key, c.m2 = K, M2
+	c.state = stateProof
+	return suite.pad(pub), M1, nil
+}
+
+// Verify checks the server's proof M2. It returns ErrServerProof if the server does not know the verifier.
+func (c *Client) Verify(M2 []byte) error {
+	if c.state != stateProof {
+		return ErrUnexpectedMessage
+	}
+	c.state = stateDone
+
+	if subtle.ConstantTimeCompare(M2, c.m2) != 1 {
+		c.key = nil
+		return ErrServerProof
+	}
+	return nil
+}
+
+// Key returns the session key K, once the server has been verified.
+func (c *Client) Key() []byte {
+	if c.state != stateDone {
+		return nil
+	}
+	return c.key
+}
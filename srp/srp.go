@@ -0,0 +1,359 @@
+// Copyright 2014 Gyepi Sam. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Package srp stores SRP-6a verifiers, as defined by RFC 2945 and RFC 5054, as Modular Crypt Format passwords
+
+	$srp$g=2048,h=sha256,i=YWxpY2U=$salt$verifier
+
+where g is the size in bits of an RFC 5054 group, h is the hash function (sha1, sha256 or sha512),
+i is the base64 encoded identity (username) and salt and verifier are base64 encoded.
+The groups of 1024, 2048, 3072, 4096, 6144 and 8192 bits are supported.
+
+A verifier is v = g^x % N, where x = H(salt | H(identity | ":" | password)).
+The verifiers produced by NewVerifier include the identity and can be used by a Server to authenticate
+the user with SRP-6a, so that neither the password nor anything that could be used in its place crosses
+the wire. The computations of the exchange are those of RFC 5054:
+
+	k  = H(N | PAD(g))
+	u  = H(PAD(A) | PAD(B))
+	B  = k*v + g^b % N
+	S  = (A * v^u) ^ b % N      (server)
+	S  = (B - k*g^x) ^ (a + u*x) % N      (client)
+	K  = H(S)
+	M1 = H(H(N) xor H(g) | H(I) | salt | A | B | K)
+	M2 = H(A | M1 | K)
+
+A Client is provided for tests and for Go clients.
+
+Importing the package also registers an encoder with mcf.RegisterVerifier, so that mcf.Verify checks
+passwords against verifiers offline and mcf.IsCurrent judges them. It does not create verifiers, since
+mcf.Create has no username to bind them to; NewVerifier is the only way to make them.
+*/
+package srp
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/gyepisam/mcf"
+	"github.com/gyepisam/mcf/bridge"
+	"github.com/gyepisam/mcf/encoder"
+	"github.com/gyepisam/mcf/password"
+)
+
+// Default values.
+// These are exported to show default values.
+// See GetConfig and SetConfig(...) to change them.
+const (
+	DefaultGroup   = 2048
+	DefaultHash    = "sha256"
+	DefaultSaltLen = 16
+)
+
+// MaxSaltLen is the maximum salt length in bytes.
+var MaxSaltLen = 1024
+
+// The hash functions, from weakest to strongest.
+var hashes = []struct {
+	name string
+	fn   func() hash.Hash
+}{
+	{"sha1", sha1.New},
+	{"sha256", sha256.New},
+	{"sha512", sha512.New},
+}
+
+// hashRank returns the position of the named hash function in hashes, or -1 if it is unknown.
+func hashRank(name string) int {
+	for i, h := range hashes {
+		if h.name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// Config contains the parameters used to create new verifiers.
+type Config struct {
+	Group   int    // Size of the group in bits.
+	Hash    string // Name of the hash function: sha1, sha256 or sha512.
+	SaltLen int    // Length of salt in bytes. At least mcf.MinSaltLen and at most MaxSaltLen.
+
+	SaltMine mcf.SaltMiner // Source of salt; see mcf.SaltMiner.
+
+	identity string // The identity of a verifier, which is not part of a configuration.
+}
+
+// ErrInvalidParameter is returned when a parameter is invalid.
+// The error message contains the name and value of the faulty parameter.
+type ErrInvalidParameter struct {
+	Name  string
+	Value string
+}
+
+func (e ErrInvalidParameter) Error() string {
+	return fmt.Sprintf("srp: parameter %s has invalid value: %s", e.Name, e.Value)
+}
+
+// Unwrap returns encoder.ErrInvalidParams.
+func (e ErrInvalidParameter) Unwrap() error { return encoder.ErrInvalidParams }
+
+// GetConfig returns the default configuration used to create new verifiers.
+// The return value can be modified and used as a parameter to SetConfig.
+func GetConfig() Config {
+	return Config{Group: DefaultGroup, Hash: DefaultHash, SaltLen: DefaultSaltLen}
+}
+
+// SetConfig sets the configuration used to create new verifiers.
+func SetConfig(config Config) error {
+	if err := config.validate(); err != nil {
+		return err
+	}
+	return register(config)
+}
+
+func (c *Config) validate() error {
+	switch {
+	case groups[c.Group] == nil:
+		return ErrInvalidParameter{"Group", strconv.Itoa(c.Group)}
+	case hashRank(c.Hash) < 0:
+		return ErrInvalidParameter{"Hash", c.Hash}
+	case c.SaltLen < mcf.MinSaltLen || c.SaltLen > MaxSaltLen:
+		return ErrInvalidParameter{"SaltLen", strconv.Itoa(c.SaltLen)}
+	}
+	return nil
+}
+
+func newEncoder(config Config) encoder.Encoder {
+	// Constructor function. Provide fresh copy each time.
+	fn := func() bridge.Implementer {
+		c := config
+		return &c
+	}
+	return bridge.New([]byte("srp"), fn)
+}
+
+// ErrCreateDisabled is returned by the Create method of the registered encoder, which only verifies.
+// Use NewVerifier to create verifiers.
+var ErrCreateDisabled = fmt.Errorf("%w: srp: use srp.NewVerifier", encoder.ErrCreateDisabled)
+
+// A verifier is the encoder registered with mcf. It is that of newEncoder, without Create.
+type verifier struct {
+	encoder.Encoder
+}
+
+func newVerifier(config Config) encoder.Encoder {
+	return verifier{newEncoder(config)}
+}
+
+// Create returns ErrCreateDisabled.
+func (verifier) Create(plaintext []byte) (encoded []byte, err error) {
+	return nil, ErrCreateDisabled
+}
+
+// ParseParams implements encoder.ParamsParser. See bridge.Encoder.ParseParams.
+func (v verifier) ParseParams(encoded []byte) (map[string]string, error) {
+	return v.Encoder.(encoder.ParamsParser).ParseParams(encoded)
+}
+
+func register(config Config) error {
+	return mcf.RegisterVerifier(mcf.SRP, newVerifier(config))
+}
+
+func init() {
+	if err := register(GetConfig()); err != nil {
+		panic(err)
+	}
+	mcf.RegisterFactory(mcf.SRP, mcf.NewFactory((*Config).validate, newVerifier))
+}
+
+// Params encodes the group, hash function and identity, if any.
+func (c *Config) Params() string {
+	s := fmt.Sprintf("g=%d,h=%s", c.Group, c.Hash)
+	if c.identity != "" {
+		s += ",i=" + base64.StdEncoding.EncodeToString([]byte(c.identity))
+	}
+	return s
+}
+
+// SetParams extracts the parameters from the output of Params().
+func (c *Config) SetParams(params string) error {
+	fields := strings.Split(params, ",")
+	if len(fields) < 2 || len(fields) > 3 {
+		return fmt.Errorf("%w: srp: expected g=group,h=hash[,i=identity], got %q", encoder.ErrInvalidParams, params)
+	}
+
+	c.identity = ""
+	for i, field := range fields {
+		name, value, _ := strings.Cut(field, "=")
+		switch {
+		case i == 0 && name == "g":
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return ErrInvalidParameter{"Group", value}
+			}
+			c.Group = n
+		case i == 1 && name == "h":
+			c.Hash = value
+		case i == 2 && name == "i":
+			b, err := base64.StdEncoding.DecodeString(value)
+			if err != nil || len(b) == 0 {
+				return ErrInvalidParameter{"Identity", value}
+			}
+			c.identity = string(b)
+		default:
+			return fmt.Errorf("%w: srp: unexpected parameter %q", encoder.ErrInvalidParams, field)
+		}
+	}
+
+	if groups[c.Group] == nil {
+		return ErrInvalidParameter{"Group", strconv.Itoa(c.Group)}
+	}
+	if hashRank(c.Hash) < 0 {
+		return ErrInvalidParameter{"Hash", c.Hash}
+	}
+	return nil
+}
+
+// Salt produces SaltLen bytes from SaltMine, or of random data if it is not set.
+func (c *Config) Salt() ([]byte, error) {
+	salt, err := mcf.Salt(c.SaltLen, c.SaltMine)
+	if err != nil {
+		return nil, fmt.Errorf("srp: salt: %w", err)
+	}
+	return salt, nil
+}
+
+// Key returns the verifier of the identity, password and salt.
+// The verifier is padded to the length of the group.
+func (c *Config) Key(plaintext, salt []byte) ([]byte, error) {
+	if len(salt) > MaxSaltLen {
+		return nil, fmt.Errorf("%w: srp: salt is longer than %d bytes", encoder.ErrMalformedHash, MaxSaltLen)
+	}
+	s := c.suite()
+	return s.pad(s.verifier([]byte(c.identity), plaintext, salt)), nil
+}
+
+// AtLeast returns true if the group and hash function of the encoded verifier
+// are at least as strong as those currently in use.
+func (c *Config) AtLeast(current_imp bridge.Implementer) bool {
+	current, ok := current_imp.(*Config)
+	if !ok {
+		return false
+	}
+	return !(c.Group < current.Group || hashRank(c.Hash) < hashRank(current.Hash))
+}
+
+// NewVerifier produces an encoded verifier, which includes the username, from a password
+// using the given configuration. Its verifiers can be used by a Server.
+func NewVerifier(config Config, username, password string) (encoded string, err error) {
+	if username == "" {
+		return "", fmt.Errorf("srp: empty username")
+	}
+	if err := config.validate(); err != nil {
+		return "", err
+	}
+	config.identity = username
+
+	b, err := newEncoder(config).Create([]byte(password))
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// A record is a parsed verifier.
+type record struct {
+	config   Config
+	salt     []byte
+	verifier *big.Int
+}
+
+func parseRecord(encoded []byte) (*record, error) {
+	passwd := password.New([]byte("srp"))
+	if err := passwd.Parse(encoded); err != nil {
+		return nil, err
+	}
+
+	r := &record{salt: passwd.Salt}
+	if err := r.config.SetParams(string(passwd.Params)); err != nil {
+		return nil, err
+	}
+	if len(r.salt) == 0 || len(r.salt) > MaxSaltLen {
+		return nil, fmt.Errorf("%w: srp: salt must be between 1 and %d bytes", encoder.ErrMalformedHash, MaxSaltLen)
+	}
+
+	g := groups[r.config.Group]
+	r.verifier = new(big.Int).SetBytes(passwd.Key)
+	if len(passwd.Key) > g.size || r.verifier.Sign() == 0 || r.verifier.Cmp(g.N) >= 0 {
+		return nil, fmt.Errorf("%w: srp: verifier is out of range", encoder.ErrMalformedHash)
+	}
+	return r, nil
+}
+
+// A suite is a group and a hash function, with which the values of SRP are computed.
+type suite struct {
+	*group
+	hash func() hash.Hash
+}
+
+func (c *Config) suite() *suite {
+	return &suite{group: groups[c.Group], hash: hashes[hashRank(c.Hash)].fn}
+}
+
+// digest returns the hash of the concatenation of parts.
+func (s *suite) digest(parts ...[]byte) []byte {
+	h := s.hash()
+	for _, p := range parts {
+		h.Write(p)
+	}
+	return h.Sum(nil)
+}
+
+// pad returns the bytes of n, padded with zeros to the length of N.
+func (s *suite) pad(n *big.Int) []byte {
+	return n.FillBytes(make([]byte, s.size))
+}
+
+// x returns H(salt | H(identity | ":" | password)).
+func (s *suite) x(identity, password, salt []byte) *big.Int {
+	inner := s.digest(identity, []byte(":"), password)
+	return new(big.Int).SetBytes(s.digest(salt, inner))
+}
+
+// verifier returns g^x % N.
+func (s *suite) verifier(identity, password, salt []byte) *big.Int {
+	return new(big.Int).Exp(s.g, s.x(identity, password, salt), s.N)
+}
+
+// k returns the multiplier H(N | PAD(g)).
+func (s *suite) k() *big.Int {
+	return new(big.Int).SetBytes(s.digest(s.N.Bytes(), s.pad(s.g)))
+}
+
+// u returns the scrambling parameter H(PAD(A) | PAD(B)).
+func (s *suite) u(A, B *big.Int) *big.Int {
+	return new(big.Int).SetBytes(s.digest(s.pad(A), s.pad(B)))
+}
+
+// proofs returns the session key K and the proofs M1 and M2.
+func (s *suite) proofs(identity, salt []byte, A, B, S *big.Int) (K, M1, M2 []byte) {
+	K = s.digest(S.Bytes())
+
+	hN, hg := s.digest(s.N.Bytes()), s.digest(s.g.Bytes())
+	for i := range hN {
+		hN[i] ^= hg[i]
+	}
+	M1 = s.digest(hN, s.digest(identity), salt, A.Bytes(), B.Bytes(), K)
+	M2 = s.digest(A.Bytes(), M1, K)
+	return K, M1, M2
+}
@@ -0,0 +1,387 @@
+// Copyright 2014 Gyepi Sam. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package srp
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/gyepisam/mcf"
+	"github.com/gyepisam/mcf/encoder"
+	"github.com/gyepisam/mcf/mcftest"
+)
+
+func unhex(s string) []byte {
+	b, err := hex.DecodeString(strings.Join(strings.Fields(s), ""))
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// The test vector of RFC 5054, appendix B, for the 1024 bit group and SHA-1.
+// K, M1 and M2, which the RFC does not give, were computed with Python's hashlib.
+var rfc5054 = struct {
+	I, P       string
+	s, v, a, b []byte
+	A, B, u, S []byte
+	encoded    string
+	K, M1, M2  []byte
+}{
+	I: "alice",
+	P: "password123",
+	s: unhex("BEB25379 D1A8581E B5A72767 3A2441EE"),
+	v: unhex(`
+		7E273DE8 696FFC4F 4E337D05 B4B375BE B0DDE156 9E8FA00A 9886D812
+		9BADA1F1 822223CA 1A605B53 0E379BA4 729FDC59 F105B478 7E5186F5
+		C671085A 1447B52A 48CF1970 B4FB6F84 00BBF4CE BFBB1681 52E08AB5
+		EA53D15C 1AFF87B2 B9DA6E04 E058AD51 CC72BFC9 033B564E 26480D78
+		E955A5E2 9E7AB245 DB2BE315 E2099AFB`),
+	a: unhex("60975527 035CF2AD 1989806F 0407210B C81EDC04 E2762A56 AFD529DD DA2D4393"),
+	b: unhex("E487CB59 D31AC550 471E81F0 0F6928E0 1DDA08E9 74A004F4 9E61F5D1 05284D20"),
+	A: unhex(`
+		61D5E490 F6F1B795 47B0704C 436F523D D0E560F0 C64115BB 72557EC4
+		4352E890 3211C046 92272D8B 2D1A5358 A2CF1B6E 0BFCF99F 921530EC
+		8E393561 79EAE45E 42BA92AE ACED8251 71E1E8B9 AF6D9C03 E1327F44
+		BE087EF0 6530E69F 66615261 EEF54073 CA11CF58 58F0EDFD FE15EFEA
+		B349EF5D 76988A36 72FAC47B 0769447B`),
+	B: unhex(`
+		BD0C6151 2C692C0C B6D041FA 01BB152D 4916A1E7 7AF46AE1 05393011
+		BAF38964 DC46A067 0DD125B9 5A981652 236F99D9 B681CBF8 7837EC99
+		6C6DA044 53728610 D0C6DDB5 8B318885 D7D82C7F 8DEB75CE 7BD4FBAA
+		37089E6F 9C6059F3 88838E7A 00030B33 1EB76840 910440B1 B27AAEAE
+		EB4012B7 D7665238 A8E3FB00 4B117B58`),
+	u: unhex("CE38B959 3487DA98 554ED47D 70A7AE5F 462EF019"),
+	S: unhex(`
+		B0DC82BA BCF30674 AE450C02 87745E79 90A3381F 63B387AA F271A10D
+		233861E3 59B48220 F7C4693C 9AE12B0A 6F67809F 0876E2D0 13800D6C
+		41BB59B6 D5979B5C 00A172B4 A2A5903A 0BDCAF8A 709585EB 2AFAFA8F
+		3499B200 210DCC1F 10EB3394 3CD67FC8 8A2F39A4 BE5BEC4E C0A3212D
+		C346D7E4 74B29EDE 8A469FFE CA686E5A`),
+	encoded: "$srp$g=1024,h=sha1,i=YWxpY2U=$vrJTedGoWB61pydnOiRB7g==$fic96Glv/E9OM30FtLN1vrDd4Vaej6AKmIbYEputofGCIiPKGmBbUw43m6Ryn9xZ8QW0eH5RhvXGcQhaFEe1KkjPGXC0+2+EALv0zr+7FoFS4Iq16lPRXBr/h7K52m4E4FitUcxyv8kDO1ZOJkgNeOlVpeKeerJF2yvjFeIJmvs=",
+	K:       unhex("017EEFA1 CEFC5C2E 626E2159 8987F31E 0F1B11BB"),
+	M1:      unhex("3F3BC671 69EA7130 2599CF1B 0F5D408B 7B65D347"),
+	M2:      unhex("9CAB3C57 5A11DE37 D3AC1421 A9F00923 6A48EB55"),
+}
+
+func TestRFC5054(t *testing.T) {
+	v := rfc5054
+	config := Config{Group: 1024, Hash: "sha1", SaltLen: len(v.s), SaltMine: mcftest.FixedSalt(v.s)}
+
+	encoded, err := NewVerifier(config, v.I, v.P)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if encoded != v.encoded {
+		t.Errorf("NewVerifier: got %s, expected %s", encoded, v.encoded)
+	}
+
+	s := config.suite()
+	if got := s.u(new(big.Int).SetBytes(v.A), new(big.Int).SetBytes(v.B)); !bytes.Equal(got.Bytes(), v.u) {
+		t.Errorf("u: got %X, expected %X", got, v.u)
+	}
+
+	server := NewServer(func(username string) (string, error) { return encoded, nil })
+	server.Rand = bytes.NewReader(v.b)
+	challenge, err := server.Challenge(v.I)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(challenge.B, v.B) || !bytes.Equal(challenge.Salt, v.s) || challenge.Group != 1024 || challenge.Hash != "sha1" {
+		t.Errorf("Challenge: got %+v", challenge)
+	}
+
+	client := NewClient(v.I, v.P)
+	client.Rand = bytes.NewReader(v.a)
+	A, M1, err := client.Respond(challenge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(A, v.A) {
+		t.Errorf("A: got %X, expected %X", A, v.A)
+	}
+	if !bytes.Equal(M1, v.M1) {
+		t.Errorf("M1: got %X, expected %X", M1, v.M1)
+	}
+
+	M2, err := server.Verify(A, M1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(M2, v.M2) {
+		t.Errorf("M2: got %X, expected %X", M2, v.M2)
+	}
+	if err := client.Verify(M2); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(server.Key(), v.K) || !bytes.Equal(client.Key(), v.K) {
+		t.Errorf("Key: got %X and %X, expected %X", server.Key(), client.Key(), v.K)
+	}
+
+	x := func(b []byte) *big.Int { return new(big.Int).SetBytes(b) }
+	if got := s.verifier([]byte(v.I), []byte(v.P), v.s); !bytes.Equal(got.Bytes(), v.v) {
+		t.Errorf("v: got %X, expected %X", got, v.v)
+	}
+	// S = (A * v^u) ^ b % N
+	S := new(big.Int).Exp(x(v.v), x(v.u), s.N)
+	S.Exp(S.Mul(S, x(v.A)), x(v.b), s.N)
+	if !bytes.Equal(S.Bytes(), v.S) {
+		t.Errorf("S: got %X, expected %X", S, v.S)
+	}
+}
+
+func lookup(users map[string]string) func(string) (string, error) {
+	return func(username string) (string, error) {
+		encoded, ok := users[username]
+		if !ok {
+			return "", fmt.Errorf("unknown user %q", username)
+		}
+		return encoded, nil
+	}
+}
+
+func TestExchange(t *testing.T) {
+	users := make(map[string]string)
+	for _, group := range []int{1024, 2048, 3072} {
+		for _, h := range []string{"sha1", "sha256", "sha512"} {
+			config := GetConfig()
+			config.Group, config.Hash = group, h
+
+			username := fmt.Sprintf("user-%d-%s", group, h)
+			encoded, err := NewVerifier(config, username, "secret")
+			if err != nil {
+				t.Fatal(err)
+			}
+			users[username] = encoded
+
+			for _, password := range []string{"secret", "wrong"} {
+				server := NewServer(lookup(users))
+				challenge, err := server.Challenge(username)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(challenge.B) != group/8 {
+					t.Errorf("%s: B has %d bytes, expected %d", username, len(challenge.B), group/8)
+				}
+
+				client := NewClient(username, password)
+				A, M1, err := client.Respond(challenge)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				M2, err := server.Verify(A, M1)
+				if password != "secret" {
+					if !errors.Is(err, ErrClientProof) || server.Key() != nil {
+						t.Errorf("%s: Verify with wrong password: got %v, expected %v", username, err, ErrClientProof)
+					}
+					continue
+				}
+				if err != nil {
+					t.Fatalf("%s: Verify: %s", username, err)
+				}
+				if err := client.Verify(M2); err != nil {
+					t.Fatalf("%s: client Verify: %s", username, err)
+				}
+				if server.Key() == nil || !bytes.Equal(server.Key(), client.Key()) {
+					t.Errorf("%s: keys differ: %X and %X", username, server.Key(), client.Key())
+				}
+			}
+		}
+	}
+}
+
+func TestExchangeErrors(t *testing.T) {
+	encoded, err := NewVerifier(GetConfig(), "alice", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	anonymous, err := newEncoder(GetConfig()).Create([]byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	users := map[string]string{"alice": encoded, "anonymous": string(anonymous)}
+
+	if _, err := NewServer(lookup(users)).Challenge("bob"); err == nil {
+		t.Error("Challenge unknown user: expected an error")
+	}
+	if _, err := NewServer(lookup(users)).Challenge("anonymous"); !errors.Is(err, ErrIdentity) {
+		t.Errorf("Challenge with verifier without identity: got %v, expected %v", err, ErrIdentity)
+	}
+	users["bob"] = encoded
+	if _, err := NewServer(lookup(users)).Challenge("bob"); !errors.Is(err, ErrIdentity) {
+		t.Errorf("Challenge with verifier of another user: got %v, expected %v", err, ErrIdentity)
+	}
+
+	N := groups[DefaultGroup].N
+	for _, bad := range []*big.Int{big.NewInt(0), N, new(big.Int).Lsh(N, 1)} {
+		server := NewServer(lookup(users))
+		if _, err := server.Challenge("alice"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := server.Verify(bad.Bytes(), make([]byte, 32)); !errors.Is(err, ErrIllegalParameter) {
+			t.Errorf("Verify A=%X: got %v, expected %v", bad, err, ErrIllegalParameter)
+		}
+
+		client := NewClient("alice", "secret")
+		challenge := &Challenge{Group: DefaultGroup, Hash: DefaultHash, Salt: []byte("salt"), B: bad.Bytes()}
+		if _, _, err := client.Respond(challenge); !errors.Is(err, ErrIllegalParameter) {
+			t.Errorf("Respond B=%X: got %v, expected %v", bad, err, ErrIllegalParameter)
+		}
+	}
+
+	// A server that does not know the verifier.
+	server := NewServer(lookup(users))
+	challenge, err := server.Challenge("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := NewClient("alice", "secret")
+	A, M1, err := client.Respond(challenge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	M2, err := server.Verify(A, M1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	M2[0] ^= 1
+	if err := client.Verify(M2); !errors.Is(err, ErrServerProof) || client.Key() != nil {
+		t.Errorf("client Verify with wrong proof: got %v, expected %v", err, ErrServerProof)
+	}
+
+	// Messages out of order.
+	if _, err := server.Verify(A, M1); !errors.Is(err, ErrUnexpectedMessage) {
+		t.Errorf("Verify twice: got %v, expected %v", err, ErrUnexpectedMessage)
+	}
+	if _, err := server.Challenge("alice"); !errors.Is(err, ErrUnexpectedMessage) {
+		t.Errorf("Challenge after Verify: got %v, expected %v", err, ErrUnexpectedMessage)
+	}
+	if _, err := NewServer(lookup(users)).Verify(A, M1); !errors.Is(err, ErrUnexpectedMessage) {
+		t.Errorf("Verify before Challenge: got %v, expected %v", err, ErrUnexpectedMessage)
+	}
+	if err := NewClient("alice", "secret").Verify(M2); !errors.Is(err, ErrUnexpectedMessage) {
+		t.Errorf("client Verify before Respond: got %v, expected %v", err, ErrUnexpectedMessage)
+	}
+}
+
+func TestEncoder(t *testing.T) {
+	enc := mcf.Registered(mcf.SRP)
+
+	for _, tt := range []struct {
+		plaintext, encoded string
+	}{
+		{rfc5054.P, rfc5054.encoded},
+	} {
+		isValid, err := enc.Verify([]byte(tt.plaintext), []byte(tt.encoded))
+		if err != nil || !isValid {
+			t.Errorf("Verify %s: got (%t, %v), expected (true, nil)", tt.encoded, isValid, err)
+		}
+		isValid, err = enc.Verify([]byte(tt.plaintext+"x"), []byte(tt.encoded))
+		if err != nil || isValid {
+			t.Errorf("Verify wrong password %s: got (%t, %v), expected (false, nil)", tt.encoded, isValid, err)
+		}
+		if isCurrent, err := enc.IsCurrent([]byte(tt.encoded)); err != nil || isCurrent {
+			t.Errorf("IsCurrent %s: got (%t, %v), expected (false, nil)", tt.encoded, isCurrent, err)
+		}
+	}
+
+	if _, err := enc.Create([]byte("secret")); !errors.Is(err, mcf.ErrCreateDisabled) {
+		t.Errorf("Create: got %v, expected %v", err, mcf.ErrCreateDisabled)
+	}
+	if mcf.Default() == mcf.SRP {
+		t.Errorf("srp is the default encoding")
+	}
+
+	encoded, err := NewVerifier(GetConfig(), "alice", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(encoded, "$srp$g=2048,h=sha256,i=YWxpY2U=$") {
+		t.Errorf("NewVerifier: got %s", encoded)
+	}
+	if isValid, err := mcf.Verify("secret", encoded); err != nil || !isValid {
+		t.Errorf("mcf.Verify %s: got (%t, %v), expected (true, nil)", encoded, isValid, err)
+	}
+	if isCurrent, err := enc.IsCurrent([]byte(encoded)); err != nil || !isCurrent {
+		t.Errorf("IsCurrent %s: got (%t, %v), expected (true, nil)", encoded, isCurrent, err)
+	}
+
+	params, err := enc.(encoder.ParamsParser).ParseParams([]byte(rfc5054.encoded))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if params["g"] != "1024" || params["h"] != "sha1" || params["i"] != "YWxpY2U=" {
+		t.Errorf("ParseParams: got %v", params)
+	}
+}
+
+func TestConfig(t *testing.T) {
+	mcftest.Restore(t, mcf.SRP)
+
+	for _, config := range []Config{
+		{Group: 1536, Hash: "sha256", SaltLen: 16},
+		{Group: 2048, Hash: "md5", SaltLen: 16},
+		{Group: 2048, Hash: "sha256", SaltLen: mcf.MinSaltLen - 1},
+	} {
+		if err := SetConfig(config); !errors.Is(err, encoder.ErrInvalidParams) {
+			t.Errorf("SetConfig %+v: got %v, expected %v", config, err, encoder.ErrInvalidParams)
+		}
+	}
+
+	config := GetConfig()
+	config.Group = 4096
+	if err := SetConfig(config); err != nil {
+		t.Fatal(err)
+	}
+	enc := mcf.Registered(mcf.SRP)
+	encoded, err := NewVerifier(GetConfig(), "alice", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if isCurrent, err := enc.IsCurrent([]byte(encoded)); err != nil || isCurrent {
+		t.Errorf("IsCurrent %s: got (%t, %v), expected (false, nil)", encoded, isCurrent, err)
+	}
+	if _, err := NewVerifier(GetConfig(), "", "secret"); err == nil {
+		t.Error("NewVerifier with empty username: expected an error")
+	}
+}
+
+func TestMalformed(t *testing.T) {
+	enc := mcf.Registered(mcf.SRP)
+	salt, key := "$vrJTedGoWB61pydnOiRB7g==", "$fic96Glv/E9OM30FtLN1vrDd4Vaej6AKmIbYEputofGCIiPKGmBbUw43m6Ryn9xZ8QW0eH5RhvXGcQhaFEe1KkjPGXC0+2+EALv0zr+7FoFS4Iq16lPRXBr/h7K52m4E4FitUcxyv8kDO1ZOJkgNeOlVpeKeerJF2yvjFeIJmvs="
+
+	for _, tt := range []struct {
+		encoded string
+		want    error
+	}{
+		{"$srp$g=1536,h=sha1" + salt + key, encoder.ErrInvalidParams},
+		{"$srp$g=1024,h=md5" + salt + key, encoder.ErrInvalidParams},
+		{"$srp$h=sha1,g=1024" + salt + key, encoder.ErrInvalidParams},
+		{"$srp$g=1024" + salt + key, encoder.ErrInvalidParams},
+		{"$srp$g=1024,h=sha1,i=!" + salt + key, encoder.ErrInvalidParams},
+		{"$srp$g=1024,h=sha1,i=YWxpY2U=,x=1" + salt + key, encoder.ErrInvalidParams},
+	} {
+		if isValid, err := enc.Verify([]byte(rfc5054.P), []byte(tt.encoded)); isValid || !errors.Is(err, tt.want) {
+			t.Errorf("Verify %s: got (%t, %v), expected (false, %v)", tt.encoded, isValid, err, tt.want)
+		}
+	}
+
+	for _, encoded := range []string{
+		"$srp$g=1024,h=sha1,i=YWxpY2U=$$" + key[1:],
+		"$srp$g=1024,h=sha1,i=YWxpY2U=" + salt + "$AA==",
+		"$srp$g=1024,h=sha1,i=YWxpY2U=" + salt + "$" + strings.Repeat("/", 172),
+	} {
+		server := NewServer(func(string) (string, error) { return encoded, nil })
+		if _, err := server.Challenge("alice"); !errors.Is(err, encoder.ErrMalformedHash) {
+			t.Errorf("Challenge %s: got %v, expected %v", encoded, err, encoder.ErrMalformedHash)
+		}
+	}
+}
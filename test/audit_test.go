@@ -0,0 +1,96 @@
+package test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/gyepisam/mcf"
+	"github.com/gyepisam/mcf/mcftest"
+	"github.com/gyepisam/mcf/scrypt"
+)
+
+func TestAudit(t *testing.T) {
+	mcftest.Restore(t, mcf.SCRYPT)
+
+	config := scrypt.GetConfig()
+	config.N, config.R, config.P = 16, 1, 1
+	if err := scrypt.SetConfig(config); err != nil {
+		t.Fatal(err)
+	}
+	mcftest.SetDefault(t, mcf.SCRYPT)
+
+	var records []string
+	create := func(n int) {
+		for i := 0; i < n; i++ {
+			encoded, err := mcf.Create(fmt.Sprint("password", i))
+			if err != nil {
+				t.Fatal(err)
+			}
+			records = append(records, encoded)
+		}
+	}
+
+	create(3)
+	config.N = 32
+	if err := scrypt.SetConfig(config); err != nil {
+		t.Fatal(err)
+	}
+	create(2)
+
+	records = append(records,
+		"$2a$06$DCq7YPn5Rq63x1Lad4cll.TV4S6ytwfsfvkgY8jIucDrjc8deX1s.",
+		"$scrypt$KeyLen=32,N=7,R=1,P=1$c2FsdA==$c2FsdA==",
+		"$unknown$1$c2FsdA==$c2FsdA==",
+		"",
+	)
+
+	i := 0
+	r := mcf.Audit(func() (id, encoded string, ok bool) {
+		if i == len(records) {
+			return "", "", false
+		}
+		i++
+		return fmt.Sprint("user", i), records[i-1], true
+	})
+
+	if r.Total != len(records) || r.Current != 2 || r.Stale != 4 {
+		t.Errorf("got total %d, current %d, stale %d; expected %d, 2, 4", r.Total, r.Current, r.Stale, len(records))
+	}
+	if got := strings.Join(r.Malformed, ","); got != "user7" {
+		t.Errorf("malformed: got %q, expected user7", got)
+	}
+	if got := strings.Join(r.Unrecognized, ","); got != "user8,user9" {
+		t.Errorf("unrecognized: got %q, expected user8,user9", got)
+	}
+
+	s := r.Schemes[mcf.SCRYPT]
+	if s == nil {
+		t.Fatal("no scrypt report")
+	}
+	if s.Count != 6 || s.Current != 2 || s.Stale != 3 || s.Malformed != 1 {
+		t.Errorf("scrypt: got %+v", *s)
+	}
+	if n16, n32 := s.Params["N"]["16"], s.Params["N"]["32"]; n16 != 3 || n32 != 2 {
+		t.Errorf("scrypt N histogram: got 16:%d, 32:%d, expected 16:3, 32:2", n16, n32)
+	}
+
+	if b := r.Schemes[mcf.BCRYPT]; b == nil || b.Stale != 1 || b.Params["cost"]["6"] != 1 {
+		t.Errorf("bcrypt: got %+v", b)
+	}
+
+	var out strings.Builder
+	if _, err := r.WriteTo(&out); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"total 9, current 2, stale 4, malformed 1, unrecognized 2", "\tN=16\t3\n", "\tcost=6\t1\n"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("summary does not contain %q:\n%s", want, out.String())
+		}
+	}
+	for _, encoded := range records {
+		if encoded != "" && strings.Contains(out.String(), encoded) {
+			t.Errorf("summary contains an encoded password")
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/gyepisam/mcf"
+	"github.com/gyepisam/mcf/bcrypt"
+	"github.com/gyepisam/mcf/encoder"
+	"github.com/gyepisam/mcf/mcftest"
+	"github.com/gyepisam/mcf/pbkdf2"
+	"github.com/gyepisam/mcf/scrypt"
+	"github.com/gyepisam/mcf/yescrypt"
+)
+
+// configure calls set twice, with a weak and a stronger configuration,
+// and returns the encoders registered for encoding after each call.
+func configure(t *testing.T, encoding mcf.Encoding, set func(stronger bool) error) (enc, weaker encoder.Encoder) {
+	mcftest.Restore(t, encoding)
+
+	if err := set(false); err != nil {
+		t.Fatal(err)
+	}
+	weaker = mcf.Registered(encoding)
+
+	if err := set(true); err != nil {
+		t.Fatal(err)
+	}
+	return mcf.Registered(encoding), weaker
+}
+
+func TestConformance(t *testing.T) {
+	t.Run("bcrypt", func(t *testing.T) {
+		enc, weaker := configure(t, mcf.BCRYPT, func(stronger bool) error {
+			if stronger {
+				return bcrypt.SetCost(5)
+			}
+			return bcrypt.SetCost(4)
+		})
+		mcftest.Conformance(t, enc, weaker)
+	})
+
+	t.Run("scrypt", func(t *testing.T) {
+		enc, weaker := configure(t, mcf.SCRYPT, func(stronger bool) error {
+			config := scrypt.GetConfig()
+			config.N, config.R, config.P = 16, 1, 1
+			if stronger {
+				config.N = 32
+			}
+			return scrypt.SetConfig(config)
+		})
+		mcftest.Conformance(t, enc, weaker)
+	})
+
+	t.Run("pbkdf2", func(t *testing.T) {
+		enc, weaker := configure(t, mcf.PBKDF2, func(stronger bool) error {
+			config := pbkdf2.GetConfig()
+			config.Iterations = 10
+			if stronger {
+				config.Iterations = 20
+			}
+			return pbkdf2.SetConfig(config)
+		})
+		mcftest.Conformance(t, enc, weaker)
+	})
+
+	t.Run("yescrypt", func(t *testing.T) {
+		enc, weaker := configure(t, mcf.YESCRYPT, func(stronger bool) error {
+			config := yescrypt.GetConfig()
+			config.N, config.R = 16, 1
+			if stronger {
+				config.N = 32
+			}
+			return yescrypt.SetConfig(config)
+		})
+		mcftest.Conformance(t, enc, weaker)
+	})
+}
@@ -0,0 +1,74 @@
+package test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/gyepisam/mcf"
+	"github.com/gyepisam/mcf/scrypt"
+)
+
+func TestDeriveKey(t *testing.T) {
+	// RFC 6070
+	record := "$pbkdf2$keylen=20,iterations=2,hmac=SHA1$c2FsdA=="
+	key, out, err := mcf.DeriveKey(record, []byte("password"), nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, got := "ea6c014dc72d6f8ccd1ed92ace1d41f0d8de8957", hex.EncodeToString(key); want != got {
+		t.Errorf("pbkdf2 key: want %s, got %s", want, got)
+	}
+	if out != record {
+		t.Errorf("pbkdf2 record: want %s, got %s", record, out)
+	}
+
+	// The salt may be given separately.
+	key2, _, err := mcf.DeriveKey("$pbkdf2$keylen=20,iterations=2,hmac=SHA1", []byte("password"), []byte("salt"), 0)
+	if err != nil || !bytes.Equal(key, key2) {
+		t.Errorf("pbkdf2 with salt: got (%x, %v), expected (%x, nil)", key2, err, key)
+	}
+
+	config := scrypt.GetConfig()
+	config.N, config.R, config.P = 16, 8, 1
+	key, out, err = mcf.DeriveKey(mcf.Profile{Encoding: mcf.SCRYPT, Config: config}, []byte(plain), nil, 48)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(key) != 48 || !strings.HasPrefix(out, "$scrypt$KeyLen=48,N=16,R=8,P=1$") || strings.Count(out, "$") != 3 {
+		t.Errorf("scrypt: got a %d byte key and record %s", len(key), out)
+	}
+
+	again, _, err := mcf.DeriveKey(out, []byte(plain), nil, 0)
+	if err != nil || !bytes.Equal(key, again) {
+		t.Errorf("scrypt rederived: got (%x, %v), expected (%x, nil)", again, err, key)
+	}
+	other, _, err := mcf.DeriveKey(out, []byte("x"+plain), nil, 0)
+	if err != nil || bytes.Equal(key, other) {
+		t.Errorf("scrypt with another passphrase: got (%x, %v), expected a different key", other, err)
+	}
+
+	if key, _, err := mcf.DeriveKey(mcf.PBKDF2, []byte(plain), nil, 0); err != nil || len(key) == 0 {
+		t.Errorf("pbkdf2 default configuration: got (%x, %v)", key, err)
+	}
+
+	for _, tt := range []struct {
+		params interface{}
+		length int
+		want   error
+	}{
+		{mcf.SCRYPT, scrypt.MaxKeyLen + 1, mcf.ErrInvalidParams},
+		{mcf.SCRYPT, -1, mcf.ErrInvalidParams},
+		{"$scrypt$KeyLen=32,N=7,R=8,P=1$c2FsdA==", 0, mcf.ErrInvalidParams},
+		{"$scrypt$KeyLen=32,N=16,R=8,P=1$c2FsdA==$c2FsdA==", 0, mcf.ErrMalformedHash},
+		{"$scrypt", 0, mcf.ErrMalformedHash},
+		{"$2a$06$DCq7YPn5Rq63x1Lad4cll.TV4S6ytwfsfvkgY8jIucDrjc8deX1s.", 0, mcf.ErrUnknownScheme}, // bcrypt cannot derive keys
+		{"$nope$params$c2FsdA==", 0, mcf.ErrUnknownScheme},
+	} {
+		if _, _, err := mcf.DeriveKey(tt.params, []byte(plain), nil, tt.length); !errors.Is(err, tt.want) {
+			t.Errorf("DeriveKey(%v, %d): got %v, expected %v", tt.params, tt.length, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+// Copyright 2014 Gyepi Sam. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package test
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/gyepisam/mcf"
+	"github.com/gyepisam/mcf/encoder"
+)
+
+// A stub is an unregistered encoder that accepts its own id as the password.
+type stub string
+
+func (s stub) Id() []byte { return []byte(s) }
+
+func (s stub) Create(plaintext []byte) ([]byte, error) { return nil, errors.New("stub: Create") }
+
+func (s stub) Verify(plaintext, encoded []byte) (bool, error) {
+	return string(plaintext) == string(s), nil
+}
+
+func (s stub) IsCurrent(encoded []byte) (bool, error) { return false, nil }
+
+// prefixDetector returns a Detector that recognizes passwords that begin with prefix as belonging to enc.
+func prefixDetector(prefix string, enc encoder.Encoder) mcf.Detector {
+	return func(encoded []byte) (encoder.Encoder, bool) {
+		return enc, bytes.HasPrefix(encoded, []byte(prefix))
+	}
+}
+
+func TestDetectors(t *testing.T) {
+	// Detectors cannot be removed, so they only recognize passwords that begin with "detect:".
+	for _, d := range []struct {
+		precedence int
+		detect     mcf.Detector
+	}{
+		{mcf.PrecedenceContent, prefixDetector("detect:", stub("content"))},
+		{mcf.PrecedenceContent, prefixDetector("detect:both", stub("other"))},
+		{mcf.PrecedenceContent, prefixDetector("detect:same", stub("content"))},
+		{mcf.PrecedencePrefix, prefixDetector("detect:prefix", stub("prefix"))},
+		{mcf.PrecedencePrefix, prefixDetector("detect:none", nil)},
+	} {
+		if err := mcf.RegisterDetectorPrecedence(d.precedence, d.detect); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := mcf.RegisterDetector(nil); err == nil {
+		t.Error("RegisterDetector(nil): expected an error")
+	}
+
+	for _, tt := range []struct {
+		encoded string
+		want    string // The id of the encoder that should verify the password.
+	}{
+		{"detect:", "content"},
+		{"detect:same", "content"},
+		{"detect:prefix", "prefix"},
+		{"detect:prefix:both", "prefix"},
+		{"detect:none", "content"},
+	} {
+		isValid, err := mcf.Verify(tt.want, tt.encoded)
+		if err != nil || !isValid {
+			t.Errorf("Verify %q: got (%t, %v), expected (true, nil) from %s", tt.encoded, isValid, err, tt.want)
+		}
+	}
+
+	_, err := mcf.Verify("content", "detect:both")
+	var ambiguous *mcf.ErrAmbiguousScheme
+	if !errors.As(err, &ambiguous) || !errors.Is(err, mcf.ErrUnknownScheme) {
+		t.Fatalf("Verify ambiguous password: got %v, expected an *ErrAmbiguousScheme", err)
+	}
+	if want := []string{"content", "other"}; !reflect.DeepEqual(ambiguous.Ids, want) {
+		t.Errorf("ErrAmbiguousScheme.Ids: got %q, expected %q", ambiguous.Ids, want)
+	}
+	if _, err := mcf.IsCurrent("detect:both"); !errors.As(err, &ambiguous) {
+		t.Errorf("IsCurrent ambiguous password: got %v, expected an *ErrAmbiguousScheme", err)
+	}
+
+	// Ids take precedence over detectors.
+	encoded, err := mcf.Create("password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	detector := prefixDetector(encoded, stub("id"))
+	if err := mcf.RegisterDetectorPrecedence(mcf.PrecedencePrefix+1, detector); err != nil {
+		t.Fatal(err)
+	}
+	if isValid, err := mcf.Verify("password", encoded); err != nil || !isValid {
+		t.Errorf("Verify %q: got (%t, %v), expected (true, nil)", encoded, isValid, err)
+	}
+}
@@ -0,0 +1,101 @@
+package test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/gyepisam/mcf"
+)
+
+// corrupt replaces field i of an encoded password, counting from the id as field 0.
+func corrupt(encoded string, i int, value string) string {
+	parts := strings.Split(encoded, "$")
+	parts[i+1] = value
+	return strings.Join(parts, "$")
+}
+
+func TestErrorClasses(t *testing.T) {
+	secret := "sekrit-plaintext"
+
+	for _, r := range encodings {
+		if err := mcf.SetDefault(r.encoding); err != nil {
+			t.Fatal(err)
+		}
+
+		encoded, err := mcf.Create(secret)
+		if err != nil {
+			t.Fatalf("%s: Create: %s", r.encoding, err)
+		}
+
+		isValid, err := mcf.Verify("wrong", encoded)
+		if isValid || err != nil {
+			t.Errorf("%s: mismatch: got (%t, %v), expected (false, nil)", r.encoding, isValid, err)
+		}
+
+		tests := []struct {
+			name    string
+			encoded string
+			want    error
+		}{
+			{"too few fields", encoded[:strings.LastIndex(encoded, "$")], mcf.ErrMalformedHash},
+			{"bad salt", corrupt(encoded, 2, "!!!"), mcf.ErrMalformedHash},
+			{"bad params", corrupt(encoded, 1, "99"), mcf.ErrInvalidParams},
+		}
+
+		for _, tt := range tests {
+			_, err := mcf.Verify(secret, tt.encoded)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("%s: Verify: %s: got %v, expected %v", r.encoding, tt.name, err, tt.want)
+			}
+
+			_, err = mcf.IsCurrent(tt.encoded)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("%s: IsCurrent: %s: got %v, expected %v", r.encoding, tt.name, err, tt.want)
+			}
+		}
+	}
+
+	for _, encoded := range []string{"", "password", "$unknown$1$c2FsdA==$a2V5", "$scryptx$1$c2FsdA==$a2V5", "$7x$1$c2FsdA==$a2V5"} {
+		_, err := mcf.Verify(secret, encoded)
+		if !errors.Is(err, mcf.ErrUnknownScheme) {
+			t.Errorf("Verify %q: got %v, expected %v", encoded, err, mcf.ErrUnknownScheme)
+		}
+	}
+}
+
+func TestErrorNoLeaks(t *testing.T) {
+	secret := "sekrit-plaintext"
+
+	for _, r := range encodings {
+		if err := mcf.SetDefault(r.encoding); err != nil {
+			t.Fatal(err)
+		}
+
+		encoded, err := mcf.Create(secret)
+		if err != nil {
+			t.Fatalf("%s: Create: %s", r.encoding, err)
+		}
+
+		inputs := []string{
+			corrupt(encoded, 1, "99"),
+			corrupt(encoded, 2, "!!!"),
+			"$unknown$" + secret,
+			secret,
+		}
+
+		for _, in := range inputs {
+			if _, err := mcf.Verify(secret, in); err != nil && strings.Contains(err.Error(), secret) {
+				t.Errorf("%s: Verify error discloses plaintext: %s", r.encoding, err)
+			}
+		}
+
+		// Arguments in the wrong order.
+		_, err = mcf.Verify(encoded, secret)
+		if err == nil {
+			t.Errorf("%s: swapped arguments: expected an error", r.encoding)
+		} else if strings.Contains(err.Error(), secret) || strings.Contains(err.Error(), encoded) {
+			t.Errorf("%s: swapped arguments: error discloses a password: %s", r.encoding, err)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package test
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/gyepisam/mcf"
+	"github.com/gyepisam/mcf/bcrypt"
+	"github.com/gyepisam/mcf/ldap"
+	"github.com/gyepisam/mcf/mcftest"
+	"github.com/gyepisam/mcf/pbkdf2"
+	"github.com/gyepisam/mcf/phpass"
+	"github.com/gyepisam/mcf/scrypt"
+	"github.com/gyepisam/mcf/yescrypt"
+)
+
+// fuzzSetup adds the test vectors as seeds and lowers the parameter limits,
+// so that the fuzzer spends its time on parsing rather than hashing.
+func fuzzSetup(f *testing.F) {
+	files, err := filepath.Glob("vectors/*.json")
+	if err != nil {
+		f.Fatal(err)
+	}
+	for _, file := range files {
+		vectors, err := mcftest.LoadVectors(file)
+		if err != nil {
+			f.Fatal(err)
+		}
+		for _, v := range vectors {
+			f.Add(v.Password, v.Hash)
+		}
+	}
+	f.Add("password", "$2a$31$DCq7YPn5Rq63x1Lad4cll.TV4S6ytwfsfvkgY8jIucDrjc8deX1s.")
+	f.Add("password", "$pbkdf2$keylen=-1,iterations=1,hmac=SHA1$c2FsdA==$c2FsdA==")
+	f.Add("password", "$scrypt$KeyLen=32,N=1073741824,R=8,P=1$c2FsdA==$c2FsdA==")
+	f.Add("password", "$y$jUT$F5Jx5fExrKuPp53xLKQ..1$tnSYvahCwPBHKZUspmcxMfb0.WiB9W.zEaKlOBL35rC")
+	f.Add("password", "$P$ZIQRaTwmfeRo7ud9Fh4E2PdI0S3r.L0")
+	f.Add("password", "{ARGON2}$argon2id$v=19$m=1048576,t=64,p=1$c29tZXNhbHQ$wWKIMhR9lyDFvRz9YTZweHKfbftvj+qf+YFY4NeBbtA")
+
+	memory, work, keyLen := scrypt.MaxMemory, scrypt.MaxWork, scrypt.MaxKeyLen
+	iterations, pbkdf2KeyLen := pbkdf2.MaxIterations, pbkdf2.MaxKeyLen
+	cost := bcrypt.MaxCost
+	yesMemory, yesWork := yescrypt.MaxMemory, yescrypt.MaxWork
+	phpassCost := phpass.MaxCost
+	passes, ldapMemory, ldapWork, ldapKeyLen := ldap.MaxPasses, ldap.MaxMemory, ldap.MaxWork, ldap.MaxKeyLen
+
+	scrypt.MaxMemory, scrypt.MaxWork, scrypt.MaxKeyLen = 1<<24, 1<<26, 256
+	pbkdf2.MaxIterations, pbkdf2.MaxKeyLen = 1<<13, 256
+	bcrypt.MaxCost = 6
+	yescrypt.MaxMemory, yescrypt.MaxWork = 1<<24, 1<<26
+	phpass.MaxCost = 11
+	ldap.MaxPasses, ldap.MaxMemory, ldap.MaxWork, ldap.MaxKeyLen = 16, 1<<12, 1<<14, 256
+
+	f.Cleanup(func() {
+		scrypt.MaxMemory, scrypt.MaxWork, scrypt.MaxKeyLen = memory, work, keyLen
+		pbkdf2.MaxIterations, pbkdf2.MaxKeyLen = iterations, pbkdf2KeyLen
+		bcrypt.MaxCost = cost
+		yescrypt.MaxMemory, yescrypt.MaxWork = yesMemory, yesWork
+		phpass.MaxCost = phpassCost
+		ldap.MaxPasses, ldap.MaxMemory, ldap.MaxWork, ldap.MaxKeyLen = passes, ldapMemory, ldapWork, ldapKeyLen
+	})
+}
+
+// checkError fails unless err is nil or one of the documented errors.
+func checkError(t *testing.T, op, encoded string, err error) {
+	if err == nil {
+		return
+	}
+	for _, e := range []error{mcf.ErrMalformedHash, mcf.ErrInvalidParams, mcf.ErrUnsupportedVersion, mcf.ErrUnknownScheme} {
+		if errors.Is(err, e) {
+			return
+		}
+	}
+	t.Errorf("%s %q: unclassified error: %s", op, encoded, err)
+}
+
+func FuzzVerify(f *testing.F) {
+	fuzzSetup(f)
+
+	f.Fuzz(func(t *testing.T, plaintext, encoded string) {
+		isValid, err := mcf.Verify(plaintext, encoded)
+		if isValid && err != nil {
+			t.Errorf("Verify %q: valid with error: %s", encoded, err)
+		}
+		checkError(t, "Verify", encoded, err)
+	})
+}
+
+func FuzzIsCurrent(f *testing.F) {
+	fuzzSetup(f)
+
+	f.Fuzz(func(t *testing.T, _, encoded string) {
+		_, err := mcf.IsCurrent(encoded)
+		checkError(t, "IsCurrent", encoded, err)
+	})
+}
@@ -0,0 +1,163 @@
+package test
+
+import (
+	"bytes"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/gyepisam/mcf"
+)
+
+// Hash must drop into database models.
+var (
+	_ sql.Scanner    = (*mcf.Hash)(nil)
+	_ driver.Valuer  = mcf.Hash("")
+	_ slog.LogValuer = mcf.Hash("")
+)
+
+func TestHashRedaction(t *testing.T) {
+	for i, v := range []struct {
+		encoded, redacted string
+	}{
+		{"", ""},
+		{"$pbkdf2$keylen=20,iterations=2000,hmac=SHA1$c2FsdA==$DGDID5YfDnHzqbUkr2ASBi/gN6Y=",
+			"$pbkdf2$keylen=20,iterations=2000,hmac=SHA1$[REDACTED]"},
+		{"$2a$06$DCq7YPn5Rq63x1Lad4cll.TV4S6ytwfsfvkgY8jIucDrjc8deX1s.", "$2a$06$[REDACTED]"},
+		{"$x$secret", "$x$[REDACTED]"},
+		{"$secret", "[REDACTED]"},
+		{"5f4dcc3b5aa765d61d8327deb882cf99", "[REDACTED]"},
+	} {
+		h := mcf.Hash(v.encoded)
+
+		if got := h.String(); got != v.redacted {
+			t.Errorf("%d: String: expected %q, got %q", i, v.redacted, got)
+		}
+
+		b, err := h.MarshalText()
+		if err != nil || string(b) != v.redacted {
+			t.Errorf("%d: MarshalText: expected %q, got %q, %v", i, v.redacted, b, err)
+		}
+
+		if got := h.LogValue().String(); got != v.redacted {
+			t.Errorf("%d: LogValue: expected %q, got %q", i, v.redacted, got)
+		}
+	}
+}
+
+func TestHashNoLeaks(t *testing.T) {
+	err := mcf.SetDefault(mcf.PBKDF2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h, err := mcf.NewHash(plain)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	secret := string(h)[strings.LastIndex(string(h), "$")+1:]
+
+	user := struct {
+		Name     string
+		Password mcf.Hash
+	}{"alibaba", h}
+
+	b, err := json.Marshal(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	slog.New(slog.NewTextHandler(&buf, nil)).Info("login", "user", user.Name, "password", user.Password)
+
+	for name, s := range map[string]string{
+		"json":   string(b),
+		"slog":   buf.String(),
+		"%v":     fmt.Sprintf("%v", user),
+		"%+v":    fmt.Sprintf("%+v", user),
+		"%#v":    fmt.Sprintf("%#v", user),
+		"%s":     fmt.Sprintf("%s", h),
+		"String": h.String(),
+	} {
+		if strings.Contains(s, secret) {
+			t.Errorf("%s: output contains the hash: %s", name, s)
+		}
+		if !strings.Contains(s, "$pbkdf2$") {
+			t.Errorf("%s: output does not identify the scheme: %s", name, s)
+		}
+	}
+}
+
+func TestHashMethods(t *testing.T) {
+	err := mcf.SetDefault(mcf.PBKDF2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h, err := mcf.NewHash(plain)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	isValid, err := h.Verify(plain)
+	if err != nil || !isValid {
+		t.Errorf("Verify: expected true, nil; got %t, %v", isValid, err)
+	}
+
+	isValid, err = h.Verify(plain + "x")
+	if err != nil || isValid {
+		t.Errorf("Verify: expected false, nil; got %t, %v", isValid, err)
+	}
+
+	isCurrent, err := h.IsCurrent()
+	if err != nil || !isCurrent {
+		t.Errorf("IsCurrent: expected true, nil; got %t, %v", isCurrent, err)
+	}
+
+	err = mcf.SetDefault(mcf.SCRYPT)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	isCurrent, err = h.IsCurrent()
+	if err != nil || isCurrent {
+		t.Errorf("IsCurrent: expected false, nil; got %t, %v", isCurrent, err)
+	}
+}
+
+func TestHashStorage(t *testing.T) {
+	const encoded = "$2a$06$DCq7YPn5Rq63x1Lad4cll.TV4S6ytwfsfvkgY8jIucDrjc8deX1s."
+
+	for i, src := range []interface{}{encoded, []byte(encoded)} {
+		var h mcf.Hash
+		if err := h.Scan(src); err != nil {
+			t.Errorf("%d: Scan: unexpected error: %s", i, err)
+			continue
+		}
+		if string(h) != encoded {
+			t.Errorf("%d: Scan: expected %q, got %q", i, encoded, string(h))
+		}
+
+		v, err := h.Value()
+		if err != nil {
+			t.Errorf("%d: Value: unexpected error: %s", i, err)
+		}
+		if v != encoded {
+			t.Errorf("%d: Value: expected %q, got %v", i, encoded, v)
+		}
+	}
+
+	h := mcf.Hash(encoded)
+	if err := h.Scan(nil); err != nil || h != "" {
+		t.Errorf("Scan(nil): expected empty hash, got %q, %v", string(h), err)
+	}
+
+	if err := h.Scan(42); err == nil {
+		t.Errorf("Scan(42): expected error, got nil")
+	}
+}
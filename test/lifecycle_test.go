@@ -0,0 +1,108 @@
+package test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gyepisam/mcf"
+	"github.com/gyepisam/mcf/mcftest"
+	"github.com/gyepisam/mcf/pbkdf2"
+)
+
+func TestLifecycle(t *testing.T) {
+	mcftest.Restore(t, mcf.PBKDF2)
+	mcftest.SetDefault(t, mcf.PBKDF2)
+
+	create := func(h pbkdf2.Hash) string {
+		config := pbkdf2.GetConfig()
+		config.Iterations, config.Hash, config.KeyLen = 10, h, h.Size()
+		if err := pbkdf2.SetConfig(config); err != nil {
+			t.Fatal(err)
+		}
+		encoded, err := mcf.Create(plain)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return encoded
+	}
+	sha1, sha256 := create(pbkdf2.SHA1), create(pbkdf2.SHA256)
+
+	now := time.Now()
+	later := now.Add(48 * time.Hour)
+
+	checkState := func(encoded string, at time.Time, want mcf.State) {
+		t.Helper()
+		if got, err := mcf.StateOf(encoded, at); err != nil || got != want {
+			t.Errorf("StateOf: got (%s, %v), expected (%s, nil)", got, err, want)
+		}
+	}
+
+	checkState(sha1, now, mcf.StatePreferred)
+
+	err := mcf.SetLifecycle(mcf.Lifecycle{
+		Encoding: mcf.PBKDF2,
+		Params:   map[string]string{"hmac": "SHA1"},
+		Stages: []mcf.Stage{
+			{State: mcf.StateRejected, From: now.Add(24 * time.Hour)},
+			{State: mcf.StateDeprecated},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkState(sha1, now, mcf.StateDeprecated)
+	checkState(sha1, later, mcf.StateRejected)
+	checkState(sha256, later, mcf.StatePreferred)
+
+	if isValid, err := mcf.Verify(plain, sha1); err != nil || !isValid {
+		t.Errorf("Verify deprecated: got (%t, %v), expected (true, nil)", isValid, err)
+	}
+	if isCurrent, err := mcf.IsCurrent(sha1); err != nil || isCurrent {
+		t.Errorf("IsCurrent deprecated: got (%t, %v), expected (false, nil)", isCurrent, err)
+	}
+	if isCurrent, err := mcf.IsCurrent(sha256); err != nil || !isCurrent {
+		t.Errorf("IsCurrent preferred: got (%t, %v), expected (true, nil)", isCurrent, err)
+	}
+
+	// A general lifecycle applies while the specific one has not taken effect.
+	since := now.Add(-time.Hour)
+	mcf.SetLifecycle(mcf.Lifecycle{Encoding: mcf.PBKDF2, Params: map[string]string{"hmac": "SHA1"},
+		Stages: []mcf.Stage{{State: mcf.StateDeprecated, From: later}}})
+	mcf.SetLifecycle(mcf.Lifecycle{Encoding: mcf.PBKDF2,
+		Stages: []mcf.Stage{{State: mcf.StateRejected, From: since}}})
+
+	checkState(sha1, now, mcf.StateRejected)
+	checkState(sha1, later, mcf.StateDeprecated)
+
+	isValid, err := mcf.Verify(plain, sha256)
+	var rejected *mcf.ErrSchemeRejected
+	if isValid || !errors.As(err, &rejected) {
+		t.Fatalf("Verify rejected: got (%t, %v), expected an *ErrSchemeRejected", isValid, err)
+	}
+	if rejected.Encoding != mcf.PBKDF2 || !rejected.Since.Equal(since) {
+		t.Errorf("got %+v", rejected)
+	}
+
+	records := []string{sha1, sha256, sha256, "$2a$06$DCq7YPn5Rq63x1Lad4cll.TV4S6ytwfsfvkgY8jIucDrjc8deX1s."}
+	i := 0
+	r := mcf.Audit(func() (id, encoded string, ok bool) {
+		if i == len(records) {
+			return "", "", false
+		}
+		i++
+		return "", records[i-1], true
+	})
+	if r.States[mcf.StateRejected] != 3 || r.States[mcf.StateAccepted] != 1 {
+		t.Errorf("Audit states: got %v", r.States)
+	}
+
+	// Lifecycles without stages are removed.
+	mcf.SetLifecycle(mcf.Lifecycle{Encoding: mcf.PBKDF2})
+	mcf.SetLifecycle(mcf.Lifecycle{Encoding: mcf.PBKDF2, Params: map[string]string{"hmac": "SHA1"}})
+	if n := len(mcf.Lifecycles()); n != 0 {
+		t.Errorf("got %d lifecycles, expected 0", n)
+	}
+	checkState(sha1, later, mcf.StatePreferred)
+}
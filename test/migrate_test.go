@@ -0,0 +1,48 @@
+// Copyright 2014 Gyepi Sam. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package test
+
+import (
+	"testing"
+
+	"github.com/gyepisam/mcf"
+	_ "github.com/gyepisam/mcf/aspnet"
+	"github.com/gyepisam/mcf/mcftest"
+	_ "github.com/gyepisam/mcf/phpass"
+)
+
+// TestMigration logs in users with legacy passwords and replaces them with the default encoder.
+func TestMigration(t *testing.T) {
+	if d := mcf.Default(); d == mcf.PHPASS || d == mcf.ASPNET {
+		t.Fatalf("%s became the default", d)
+	}
+	mcftest.Use(t)
+
+	for _, tt := range []struct {
+		plaintext, legacy string
+	}{
+		{"test12345", "$P$9IQRaTwmfeRo7ud9Fh4E2PdI0S3r.L0"},
+		{"password", "$S$DSALTsaltFgEr81fWJhb.HFLqtnf7urI7e/ikkdOZ16I9twDADNS"},
+		{"password", "AQAAAAEAACcQAAAAEAABAgMEBQYHCAkKCwwNDg/rbIFTVZIgPAkrFY+NOQlnI2Km9dvQDZgoBEy6qLJS6Q=="},
+	} {
+		plaintext, legacy := tt.plaintext, tt.legacy
+
+		isValid, err := mcf.Verify(plaintext, legacy)
+		if err != nil || !isValid {
+			t.Fatalf("Verify %s: got (%t, %v), expected (true, nil)", legacy, isValid, err)
+		}
+		if isCurrent, err := mcf.IsCurrent(legacy); err != nil || isCurrent {
+			t.Fatalf("IsCurrent %s: got (%t, %v), expected (false, nil)", legacy, isCurrent, err)
+		}
+
+		encoded, err := mcf.Create(plaintext)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if isCurrent, err := mcf.IsCurrent(encoded); err != nil || !isCurrent {
+			t.Errorf("IsCurrent %s: got (%t, %v), expected (true, nil)", encoded, isCurrent, err)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gyepisam/mcf"
+)
+
+type recorder struct {
+	events []mcf.Event
+	active bool
+}
+
+func (r *recorder) Observe(e mcf.Event) {
+	if r.active {
+		r.events = append(r.events, e)
+	}
+}
+
+var events = new(recorder)
+
+func init() {
+	mcf.AddObserver(events)
+}
+
+func record(t *testing.T, fn func()) []mcf.Event {
+	events.events, events.active = nil, true
+	defer func() { events.active = false }()
+	fn()
+	return events.events
+}
+
+func TestObserver(t *testing.T) {
+	if err := mcf.SetDefault(mcf.PBKDF2); err != nil {
+		t.Fatal(err)
+	}
+
+	var encoded string
+	got := record(t, func() {
+		var err error
+		encoded, err = mcf.Create(plain)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mcf.Verify(plain, encoded)
+		mcf.Verify(plain+"x", encoded)
+		mcf.Verify(plain, "$nosuch$")
+		mcf.Verify(plain, "$pbkdf2$garbage")
+		mcf.IsCurrent(encoded)
+	})
+
+	if err := mcf.SetDefault(mcf.SCRYPT); err != nil {
+		t.Fatal(err)
+	}
+	got = append(got, record(t, func() { mcf.IsCurrent(encoded) })...)
+
+	want := []struct {
+		op       mcf.Op
+		encoding mcf.Encoding
+		outcome  mcf.Outcome
+		upgrade  bool
+	}{
+		{mcf.OpCreate, mcf.PBKDF2, mcf.OutcomeOK, false},
+		{mcf.OpVerify, mcf.PBKDF2, mcf.OutcomeMatch, false},
+		{mcf.OpVerify, mcf.PBKDF2, mcf.OutcomeMismatch, false},
+		{mcf.OpVerify, mcf.Encoding(255), mcf.OutcomeUnknownScheme, false},
+		{mcf.OpVerify, mcf.PBKDF2, mcf.OutcomeMalformed, false},
+		{mcf.OpIsCurrent, mcf.PBKDF2, mcf.OutcomeOK, false},
+		{mcf.OpIsCurrent, mcf.PBKDF2, mcf.OutcomeOK, true},
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("expected %d events, got %d: %+v", len(want), len(got), got)
+	}
+
+	for i, w := range want {
+		e := got[i]
+		if e.Op != w.op || e.Outcome != w.outcome || e.Upgrade != w.upgrade {
+			t.Errorf("%d: expected %s/%s/%t, got %s/%s/%t", i, w.op, w.outcome, w.upgrade, e.Op, e.Outcome, e.Upgrade)
+		}
+		if w.encoding.IsValid() {
+			if e.Encoding != w.encoding || e.Id != "pbkdf2" {
+				t.Errorf("%d: expected encoding %s, got %s (%q)", i, w.encoding, e.Encoding, e.Id)
+			}
+		} else if e.Encoding.IsValid() || e.Id != "" {
+			t.Errorf("%d: expected no encoding, got %s (%q)", i, e.Encoding, e.Id)
+		}
+		if e.Duration <= 0 {
+			t.Errorf("%d: expected positive duration, got %s", i, e.Duration)
+		}
+	}
+
+	labels := got[6].Labels()
+	for k, v := range map[string]string{"op": "iscurrent", "encoding": "pbkdf2", "id": "pbkdf2", "outcome": "ok", "upgrade": "true"} {
+		if labels[k] != v {
+			t.Errorf("label %s: expected %q, got %q", k, v, labels[k])
+		}
+	}
+
+	for _, e := range got {
+		for _, v := range e.Labels() {
+			if strings.Contains(v, plain) {
+				t.Errorf("label contains plaintext: %v", e.Labels())
+			}
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/gyepisam/mcf"
+	"github.com/gyepisam/mcf/bcrypt"
+	"github.com/gyepisam/mcf/mcftest"
+	"github.com/gyepisam/mcf/pbkdf2"
+	"github.com/gyepisam/mcf/scrypt"
+)
+
+func TestProfiles(t *testing.T) {
+	mcftest.SetDefault(t, mcf.MCFTEST)
+
+	config := scrypt.GetConfig()
+	config.N, config.R, config.P = 16, 1, 1
+	user := config
+	config.N = 64
+	admin := &config
+
+	service := pbkdf2.GetConfig()
+	service.Iterations = 10
+
+	for name, p := range map[string]mcf.Profile{
+		"user":    {Encoding: mcf.SCRYPT, Config: user},
+		"admin":   {Encoding: mcf.SCRYPT, Config: admin},
+		"service": {Encoding: mcf.PBKDF2, Config: service},
+		"legacy":  {Encoding: mcf.BCRYPT, Config: bcrypt.Config{Cost: 4}},
+		"test":    {Encoding: mcf.MCFTEST},
+	} {
+		if err := mcf.DefineProfile(name, p); err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+	}
+
+	encoded := map[string]string{}
+	for _, name := range []string{"user", "admin", "service", "legacy", "test"} {
+		e, err := mcf.CreateWith(name, plain)
+		if err != nil {
+			t.Fatalf("CreateWith %s: %s", name, err)
+		}
+		encoded[name] = e
+
+		if isValid, err := mcf.Verify(plain, e); err != nil || !isValid {
+			t.Errorf("Verify %s: got (%t, %v), expected (true, nil)", name, isValid, err)
+		}
+	}
+
+	if !strings.HasPrefix(encoded["admin"], "$scrypt$KeyLen=32,N=64,") {
+		t.Errorf("admin: unexpected parameters: %s", mcf.Hash(encoded["admin"]))
+	}
+	if !strings.HasPrefix(encoded["legacy"], "$2a$04$") {
+		t.Errorf("legacy: unexpected parameters: %s", mcf.Hash(encoded["legacy"]))
+	}
+
+	tests := []struct {
+		profile, password string
+		want              bool
+	}{
+		{"admin", "admin", true},
+		{"admin", "user", false},
+		{"user", "user", true},
+		{"user", "admin", true},
+		{"service", "service", true},
+		{"service", "user", false},
+		{"admin", "service", false},
+		{"legacy", "legacy", true},
+		{"test", "test", true},
+		{"test", "user", false},
+	}
+	for _, tt := range tests {
+		isCurrent, err := mcf.IsCurrentFor(tt.profile, encoded[tt.password])
+		if err != nil || isCurrent != tt.want {
+			t.Errorf("IsCurrentFor(%s, %s password): got (%t, %v), expected (%t, nil)",
+				tt.profile, tt.password, isCurrent, err, tt.want)
+		}
+	}
+
+	// The default is unaffected by profiles.
+	if isCurrent, err := mcf.IsCurrent(encoded["admin"]); err != nil || isCurrent {
+		t.Errorf("IsCurrent: got (%t, %v), expected (false, nil)", isCurrent, err)
+	}
+
+	if _, err := mcf.CreateWith("nobody", plain); !errors.Is(err, mcf.ErrNoProfile) {
+		t.Errorf("CreateWith unknown profile: got %v, expected %v", err, mcf.ErrNoProfile)
+	}
+	if _, err := mcf.IsCurrentFor("nobody", encoded["user"]); !errors.Is(err, mcf.ErrNoProfile) {
+		t.Errorf("IsCurrentFor unknown profile: got %v, expected %v", err, mcf.ErrNoProfile)
+	}
+
+	bad := config
+	bad.N = 7
+	for _, p := range []mcf.Profile{
+		{Encoding: mcf.SCRYPT, Config: service},
+		{Encoding: mcf.SCRYPT, Config: bad},
+		{Encoding: mcf.SCRYPT, Config: &bad},
+		{Encoding: mcf.SCRYPT, Config: (*scrypt.Config)(nil)},
+		{Encoding: mcf.BCRYPT, Config: bcrypt.Config{Cost: 99}},
+	} {
+		if err := mcf.DefineProfile("bad", p); err == nil {
+			t.Errorf("DefineProfile(%+v): expected an error", p)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/gyepisam/mcf"
+	"github.com/gyepisam/mcf/bcrypt"
+	"github.com/gyepisam/mcf/mcftest"
+	"github.com/gyepisam/mcf/pbkdf2"
+	"github.com/gyepisam/mcf/scrypt"
+	"github.com/gyepisam/mcf/yescrypt"
+)
+
+func TestConfigSaltMine(t *testing.T) {
+	mcftest.Restore(t, mcf.SCRYPT, mcf.PBKDF2, mcf.BCRYPT)
+
+	salt := bytes.Repeat([]byte{0x5a}, 64)
+
+	sc := scrypt.GetConfig()
+	sc.N, sc.SaltMine = 16, mcf.ReaderMiner(bytes.NewReader(salt[:sc.SaltLen+1]))
+	pc := pbkdf2.GetConfig()
+	pc.Iterations, pc.SaltMine = 10, mcftest.FixedSalt(salt[:pc.SaltLen])
+
+	for _, set := range []func() error{
+		func() error { return scrypt.SetConfig(sc) },
+		func() error { return pbkdf2.SetConfig(pc) },
+		func() error { return bcrypt.SetConfig(bcrypt.Config{Cost: 4, SaltMine: mcftest.FixedSalt(salt[:16])}) },
+	} {
+		if err := set(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, e := range []mcf.Encoding{mcf.SCRYPT, mcf.PBKDF2, mcf.BCRYPT} {
+		mcftest.SetDefault(t, e)
+		a, err := mcf.Create(plain)
+		if err != nil {
+			t.Fatalf("%s: %s", e, err)
+		}
+		if e == mcf.SCRYPT {
+			// The reader has been drained, and the next salt is short.
+			if _, err := mcf.Create(plain); err == nil || !strings.Contains(err.Error(), "scrypt") {
+				t.Errorf("%s: Create with a drained reader: got %v, expected an scrypt error", e, err)
+			}
+			continue
+		}
+		b, err := mcf.Create(plain)
+		if err != nil {
+			t.Fatalf("%s: %s", e, err)
+		}
+		if a != b {
+			t.Errorf("%s: a fixed salt should produce identical passwords: %s, %s", e, a, b)
+		}
+		if isValid, err := mcf.Verify(plain, a); err != nil || !isValid {
+			t.Errorf("%s: Verify: got (%t, %v), expected (true, nil)", e, isValid, err)
+		}
+	}
+}
+
+func TestSaltLenLimits(t *testing.T) {
+	mcftest.Restore(t, mcf.SCRYPT, mcf.PBKDF2, mcf.YESCRYPT)
+
+	for _, n := range []int{mcf.MinSaltLen - 1, scrypt.MaxSaltLen + 1} {
+		sc := scrypt.GetConfig()
+		sc.SaltLen = n
+		if err := scrypt.SetConfig(sc); !errors.Is(err, mcf.ErrInvalidParams) {
+			t.Errorf("scrypt: SaltLen %d: got %v, expected %v", n, err, mcf.ErrInvalidParams)
+		}
+	}
+
+	for _, n := range []int{mcf.MinSaltLen - 1, pbkdf2.MaxSaltLen + 1} {
+		pc := pbkdf2.GetConfig()
+		pc.SaltLen = n
+		if err := pbkdf2.SetConfig(pc); !errors.Is(err, mcf.ErrInvalidParams) {
+			t.Errorf("pbkdf2: SaltLen %d: got %v, expected %v", n, err, mcf.ErrInvalidParams)
+		}
+	}
+
+	for _, n := range []int{mcf.MinSaltLen - 1, yescrypt.MaxSaltLen + 1} {
+		yc := yescrypt.GetConfig()
+		yc.SaltLen = n
+		if err := yescrypt.SetConfig(yc); !errors.Is(err, mcf.ErrInvalidParams) {
+			t.Errorf("yescrypt: SaltLen %d: got %v, expected %v", n, err, mcf.ErrInvalidParams)
+		}
+	}
+
+	mc := mcftest.GetConfig()
+	mc.SaltLen = 65
+	if err := mcftest.SetConfig(mc); !errors.Is(err, mcf.ErrInvalidParams) {
+		t.Errorf("mcftest: got %v, expected %v", err, mcf.ErrInvalidParams)
+	}
+}
@@ -0,0 +1,16 @@
+package test
+
+import (
+	"testing"
+
+	_ "github.com/gyepisam/mcf/ldap"
+	"github.com/gyepisam/mcf/legacy"
+	"github.com/gyepisam/mcf/mcftest"
+	_ "github.com/gyepisam/mcf/scram"
+	_ "github.com/gyepisam/mcf/srp"
+)
+
+func TestVectorFiles(t *testing.T) {
+	legacy.Enable()
+	mcftest.RunVectorFiles(t, "vectors/*.json")
+}
@@ -0,0 +1,81 @@
+// Copyright 2014 Gyepi Sam. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package yescrypt
+
+import "github.com/gyepisam/mcf/internal/crypt64"
+
+// Translated from yescrypt-common.c in libxcrypt.
+
+// encodeUint32 appends the variable length encoding of x, which must be at least min, to dst.
+func encodeUint32(dst []byte, x, min uint32) []byte {
+	if x < min {
+		return nil
+	}
+	x -= min
+
+	start, end, chars, bits := uint32(0), uint32(47), 1, uint32(0)
+	for {
+		count := (end + 1 - start) << bits
+		if x < count {
+			break
+		}
+		if start >= 63 {
+			return nil
+		}
+		start = end + 1
+		end = start + (62-end)/2
+		x -= count
+		chars++
+		bits += 6
+	}
+
+	dst = append(dst, crypt64.Alphabet[start+(x>>bits)])
+	for chars--; chars > 0; chars-- {
+		bits -= 6
+		dst = append(dst, crypt64.Alphabet[(x>>bits)&0x3f])
+	}
+	return dst
+}
+
+// decodeUint32 decodes a value encoded by encodeUint32 at the start of src
+// and returns it with the rest of src. It returns false if src is invalid.
+func decodeUint32(src []byte, min uint32) (uint32, []byte, bool) {
+	if len(src) == 0 {
+		return 0, nil, false
+	}
+	v := crypt64.Index(src[0])
+	src = src[1:]
+	if v < 0 {
+		return 0, nil, false
+	}
+	c := uint32(v)
+
+	start, end, chars, bits := uint32(0), uint32(47), 1, uint32(0)
+	x := min
+	for c > end {
+		x += (end + 1 - start) << bits
+		start = end + 1
+		end = start + (62-end)/2
+		chars++
+		bits += 6
+	}
+	x += (c - start) << bits
+
+	for chars--; chars > 0; chars-- {
+		if len(src) == 0 {
+			return 0, nil, false
+		}
+		v = crypt64.Index(src[0])
+		src = src[1:]
+		if v < 0 {
+			return 0, nil, false
+		}
+		c = uint32(v)
+		bits -= 6
+		x += c << bits
+	}
+
+	return x, src, true
+}
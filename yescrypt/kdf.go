@@ -0,0 +1,428 @@
+// Copyright 2014 Gyepi Sam. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package yescrypt
+
+// This is a straightforward translation of yescrypt-ref.c from libxcrypt,
+// by Colin Percival and Alexander Peslyak, without ROM support.
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"math/bits"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// Flags.
+const (
+	flagWORM    = 0x001
+	flagRW      = 0x002
+	flagPrehash = 0x10000000
+
+	// The only RW flavor supported, as in libxcrypt: 6 rounds, gather 4, simple 2, 12K S-boxes.
+	flagsDefault = flagRW | 0x004 | 0x010 | 0x020 | 0x080
+
+	modeMask   = 0x003
+	flavorMask = 0x3fc
+)
+
+// pwxform settings of the default flavor.
+const (
+	pwxSimple = 2
+	pwxGather = 4
+	pwxRounds = 6
+	sWidth    = 8
+
+	pwxBytes = pwxGather * pwxSimple * 8
+	pwxWords = pwxBytes / 4
+	sBytes   = 3 * (1 << sWidth) * pwxSimple * 8
+	sWords   = sBytes / 4
+	sMask    = ((1 << sWidth) - 1) * pwxSimple * 8
+	sElems   = (1 << sWidth) * pwxSimple // 8 byte elements in each of S0, S1 and S2.
+)
+
+var errParams = errors.New("unsupported parameters")
+
+// pwxform holds the S-boxes of one thread. S0, S1 and S2 are offsets, in words, into s.
+type pwxform struct {
+	s          []uint32
+	s0, s1, s2 int
+	w          int
+}
+
+// kdf is yescrypt_kdf with no shared ROM.
+func kdf(passwd, salt []byte, flags int, N uint64, r, p, t uint32, keyLen int) ([]byte, error) {
+	if flags&(flagRW) != 0 && p >= 1 && N/uint64(p) >= 0x100 && N/uint64(p)*uint64(r) >= 0x20000 {
+		dk, err := kdfBody(passwd, salt, flags|flagPrehash, N>>6, r, p, 0, 32)
+		if err != nil {
+			return nil, err
+		}
+		passwd = dk
+	}
+	return kdfBody(passwd, salt, flags, N, r, p, t, keyLen)
+}
+
+func kdfBody(passwd, salt []byte, flags int, N uint64, r, p, t uint32, keyLen int) ([]byte, error) {
+	switch flags & modeMask {
+	case 0:
+		if flags != 0 || t != 0 {
+			return nil, errParams
+		}
+	case flagWORM:
+		if flags != flagWORM {
+			return nil, errParams
+		}
+	case flagRW:
+		if flags&^flagPrehash != flagsDefault {
+			return nil, errParams
+		}
+	default:
+		return nil, errParams
+	}
+
+	if uint64(r)*uint64(p) >= 1<<30 || N > 1<<32-1 || N&(N-1) != 0 || N <= 1 || r < 1 || p < 1 {
+		return nil, errParams
+	}
+	if flags&flagRW != 0 && N/uint64(p) <= 1 {
+		return nil, errParams
+	}
+
+	s := 32 * int(r)
+	V := make([]uint32, uint64(s)*N)
+	XY := make([]uint32, 2*s)
+	var S []uint32
+	if flags&flagRW != 0 {
+		S = make([]uint32, sWords*int(p))
+	}
+
+	var sha []byte
+	if flags != 0 {
+		key := "yescrypt"
+		if flags&flagPrehash != 0 {
+			key = "yescrypt-prehash"
+		}
+		sha = hmacSHA256([]byte(key), passwd)
+		passwd = sha
+	}
+
+	B := pbkdf2.Key(passwd, salt, 1, 128*int(r)*int(p), sha256.New)
+
+	if flags != 0 {
+		// passwd aliases sha, as in the C code.
+		copy(sha, B[:32])
+	}
+
+	if p == 1 || flags&flagRW != 0 {
+		smix(B, int(r), N, p, t, flags, V, XY, S, sha)
+	} else {
+		for i := 0; i < int(p); i++ {
+			smix(B[128*int(r)*i:128*int(r)*(i+1)], int(r), N, 1, t, flags, V, XY, nil, nil)
+		}
+	}
+
+	dk := pbkdf2.Key(passwd, B, 1, keyLen, sha256.New)
+
+	if flags != 0 && flags&flagPrehash == 0 {
+		// Compute ClientKey and StoredKey, as in SCRAM (RFC 5802).
+		k := dk
+		if keyLen < 32 {
+			k = pbkdf2.Key(passwd, B, 1, 32, sha256.New)
+		}
+		storedKey := sha256.Sum256(hmacSHA256(k[:32], []byte("Client Key")))
+		copy(dk, storedKey[:])
+	}
+
+	return dk, nil
+}
+
+func hmacSHA256(key, msg []byte) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write(msg)
+	return h.Sum(nil)
+}
+
+// smix computes the second part of the KDF on B, which holds p blocks of 128r bytes.
+func smix(B []byte, r int, N uint64, p, t uint32, flags int, V, XY, S []uint32, passwd []byte) {
+	s := 32 * r
+
+	nChunk := N / uint64(p)
+	nLoopAll := nChunk
+	if flags&flagRW != 0 {
+		if t <= 1 {
+			if t != 0 {
+				nLoopAll *= 2
+			}
+			nLoopAll = (nLoopAll + 2) / 3
+		} else {
+			nLoopAll *= uint64(t) - 1
+		}
+	} else if t != 0 {
+		if t == 1 {
+			nLoopAll += (nLoopAll + 1) / 2
+		}
+		nLoopAll *= uint64(t)
+	}
+
+	var nLoopRW uint64
+	if flags&flagRW != 0 {
+		nLoopRW = nLoopAll / uint64(p)
+	}
+
+	nChunk &^= 1
+	nLoopAll = (nLoopAll + 1) &^ 1
+	nLoopRW = (nLoopRW + 1) &^ 1
+
+	ctx := make([]*pwxform, p)
+
+	vChunk := uint64(0)
+	for i := 0; i < int(p); i++ {
+		np := nChunk
+		if i == int(p)-1 {
+			np = N - vChunk
+		}
+		bp := B[128*r*i : 128*r*(i+1)]
+		vp := V[uint64(s)*vChunk:]
+
+		if flags&flagRW != 0 {
+			si := S[sWords*i : sWords*(i+1)]
+			smix1(bp, 1, sBytes/128, 0, si, XY, nil)
+			ctx[i] = &pwxform{s: si, s2: 0, s1: sElems * 2, s0: sElems * 4}
+			if i == 0 {
+				copy(passwd, hmacSHA256(bp[128*r-64:], passwd))
+			}
+		}
+
+		smix1(bp, r, np, flags, vp, XY, ctx[i])
+		smix2(bp, r, p2floor(np), nLoopRW, flags, vp, XY, ctx[i])
+		vChunk += nChunk
+	}
+
+	for i := 0; i < int(p); i++ {
+		bp := B[128*r*i : 128*r*(i+1)]
+		smix2(bp, r, N, nLoopAll-nLoopRW, flags&^flagRW, V, XY, ctx[i])
+	}
+}
+
+// load copies B into X, in the SIMD shuffled order that the reference implementation uses.
+func load(X []uint32, B []byte, r int) {
+	for k := 0; k < 2*r; k++ {
+		for i := 0; i < 16; i++ {
+			X[k*16+i] = binary.LittleEndian.Uint32(B[4*(k*16+(i*5%16)):])
+		}
+	}
+}
+
+// store reverses load.
+func store(B []byte, X []uint32, r int) {
+	for k := 0; k < 2*r; k++ {
+		for i := 0; i < 16; i++ {
+			binary.LittleEndian.PutUint32(B[4*(k*16+(i*5%16)):], X[k*16+i])
+		}
+	}
+}
+
+func smix1(B []byte, r int, N uint64, flags int, V, XY []uint32, ctx *pwxform) {
+	s := 32 * r
+	X, Y := XY[:s], XY[s:2*s]
+
+	load(X, B, r)
+
+	for i := uint64(0); i < N; i++ {
+		copy(V[i*uint64(s):], X)
+		if flags&flagRW != 0 && i > 1 {
+			j := wrap(integerify(X, r), i)
+			xor(X, V[j*uint64(s):(j+1)*uint64(s)])
+		}
+		if ctx != nil {
+			blockmixPwxform(X, r, ctx)
+		} else {
+			blockmixSalsa8(X, Y, r)
+		}
+	}
+
+	store(B, X, r)
+}
+
+func smix2(B []byte, r int, N, nLoop uint64, flags int, V, XY []uint32, ctx *pwxform) {
+	if nLoop == 0 {
+		return
+	}
+
+	s := 32 * r
+	X, Y := XY[:s], XY[s:2*s]
+
+	load(X, B, r)
+
+	for i := uint64(0); i < nLoop; i++ {
+		j := integerify(X, r) & (N - 1)
+		v := V[j*uint64(s) : (j+1)*uint64(s)]
+		xor(X, v)
+		if flags&flagRW != 0 {
+			copy(v, X)
+		}
+		if ctx != nil {
+			blockmixPwxform(X, r, ctx)
+		} else {
+			blockmixSalsa8(X, Y, r)
+		}
+	}
+
+	store(B, X, r)
+}
+
+// integerify returns the first 64 bits of the last 64 byte block of X.
+// Word 13 is the second word due to the SIMD shuffling.
+func integerify(X []uint32, r int) uint64 {
+	x := X[(2*r-1)*16:]
+	return uint64(x[13])<<32 | uint64(x[0])
+}
+
+func p2floor(x uint64) uint64 {
+	return 1 << (63 - bits.LeadingZeros64(x))
+}
+
+func wrap(x, i uint64) uint64 {
+	n := p2floor(i)
+	return x&(n-1) + (i - n)
+}
+
+func xor(dst, src []uint32) {
+	for i := range dst {
+		dst[i] ^= src[i]
+	}
+}
+
+func blockmixSalsa8(B, Y []uint32, r int) {
+	var X [16]uint32
+	copy(X[:], B[(2*r-1)*16:])
+
+	for i := 0; i < 2*r; i++ {
+		xor(X[:], B[i*16:(i+1)*16])
+		salsa20(&X, 8)
+		copy(Y[i*16:], X[:])
+	}
+
+	for i := 0; i < r; i++ {
+		copy(B[i*16:(i+1)*16], Y[i*2*16:])
+		copy(B[(i+r)*16:(i+r+1)*16], Y[(i*2+1)*16:])
+	}
+}
+
+func blockmixPwxform(B []uint32, r int, ctx *pwxform) {
+	var X [pwxWords]uint32
+
+	r1 := 128 * r / pwxBytes
+	copy(X[:], B[(r1-1)*pwxWords:])
+
+	for i := 0; i < r1; i++ {
+		if r1 > 1 {
+			xor(X[:], B[i*pwxWords:(i+1)*pwxWords])
+		}
+		ctx.transform(&X)
+		copy(B[i*pwxWords:], X[:])
+	}
+
+	i := (r1 - 1) * pwxBytes / 64
+	salsa20((*[16]uint32)(B[i*16:(i+1)*16]), 2)
+
+	for i++; i < 2*r; i++ {
+		xor(B[i*16:(i+1)*16], B[(i-1)*16:i*16])
+		salsa20((*[16]uint32)(B[i*16:(i+1)*16]), 2)
+	}
+}
+
+// transform is pwxform. S0, S1 and S2 hold pairs of words, addressed in 8 byte units.
+func (ctx *pwxform) transform(X *[pwxWords]uint32) {
+	S := ctx.s
+	s0, s1, s2 := ctx.s0, ctx.s1, ctx.s2
+	w := ctx.w
+
+	for i := 0; i < pwxRounds; i++ {
+		for j := 0; j < pwxGather; j++ {
+			xl := X[j*pwxSimple*2]
+			xh := X[j*pwxSimple*2+1]
+
+			p0 := s0 + int(xl&sMask)/8*2
+			p1 := s1 + int(xh&sMask)/8*2
+
+			for k := 0; k < pwxSimple; k++ {
+				lo, hi := j*pwxSimple*2+k*2, j*pwxSimple*2+k*2+1
+
+				v0 := uint64(S[p0+k*2+1])<<32 | uint64(S[p0+k*2])
+				v1 := uint64(S[p1+k*2+1])<<32 | uint64(S[p1+k*2])
+
+				x := uint64(X[hi]) * uint64(X[lo])
+				x += v0
+				x ^= v1
+
+				X[lo], X[hi] = uint32(x), uint32(x>>32)
+
+				if i != 0 && i != pwxRounds-1 {
+					S[s2+w*2], S[s2+w*2+1] = uint32(x), uint32(x>>32)
+					w++
+				}
+			}
+		}
+	}
+
+	ctx.s0, ctx.s1, ctx.s2 = s2, s0, s1
+	ctx.w = w & ((1<<sWidth)*pwxSimple - 1)
+}
+
+// salsa20 applies the Salsa20 core with the given number of rounds to B, which is SIMD shuffled.
+func salsa20(B *[16]uint32, rounds int) {
+	var x [16]uint32
+	for i := 0; i < 16; i++ {
+		x[i*5%16] = B[i]
+	}
+
+	for i := 0; i < rounds; i += 2 {
+		x[4] ^= bits.RotateLeft32(x[0]+x[12], 7)
+		x[8] ^= bits.RotateLeft32(x[4]+x[0], 9)
+		x[12] ^= bits.RotateLeft32(x[8]+x[4], 13)
+		x[0] ^= bits.RotateLeft32(x[12]+x[8], 18)
+
+		x[9] ^= bits.RotateLeft32(x[5]+x[1], 7)
+		x[13] ^= bits.RotateLeft32(x[9]+x[5], 9)
+		x[1] ^= bits.RotateLeft32(x[13]+x[9], 13)
+		x[5] ^= bits.RotateLeft32(x[1]+x[13], 18)
+
+		x[14] ^= bits.RotateLeft32(x[10]+x[6], 7)
+		x[2] ^= bits.RotateLeft32(x[14]+x[10], 9)
+		x[6] ^= bits.RotateLeft32(x[2]+x[14], 13)
+		x[10] ^= bits.RotateLeft32(x[6]+x[2], 18)
+
+		x[3] ^= bits.RotateLeft32(x[15]+x[11], 7)
+		x[7] ^= bits.RotateLeft32(x[3]+x[15], 9)
+		x[11] ^= bits.RotateLeft32(x[7]+x[3], 13)
+		x[15] ^= bits.RotateLeft32(x[11]+x[7], 18)
+
+		x[1] ^= bits.RotateLeft32(x[0]+x[3], 7)
+		x[2] ^= bits.RotateLeft32(x[1]+x[0], 9)
+		x[3] ^= bits.RotateLeft32(x[2]+x[1], 13)
+		x[0] ^= bits.RotateLeft32(x[3]+x[2], 18)
+
+		x[6] ^= bits.RotateLeft32(x[5]+x[4], 7)
+		x[7] ^= bits.RotateLeft32(x[6]+x[5], 9)
+		x[4] ^= bits.RotateLeft32(x[7]+x[6], 13)
+		x[5] ^= bits.RotateLeft32(x[4]+x[7], 18)
+
+		x[11] ^= bits.RotateLeft32(x[10]+x[9], 7)
+		x[8] ^= bits.RotateLeft32(x[11]+x[10], 9)
+		x[9] ^= bits.RotateLeft32(x[8]+x[11], 13)
+		x[10] ^= bits.RotateLeft32(x[9]+x[8], 18)
+
+		x[12] ^= bits.RotateLeft32(x[15]+x[14], 7)
+		x[13] ^= bits.RotateLeft32(x[12]+x[15], 9)
+		x[14] ^= bits.RotateLeft32(x[13]+x[12], 13)
+		x[15] ^= bits.RotateLeft32(x[14]+x[13], 18)
+	}
+
+	for i := 0; i < 16; i++ {
+		B[i] += x[i*5%16]
+	}
+}
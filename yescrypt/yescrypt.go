@@ -0,0 +1,366 @@
+// Copyright 2014 Gyepi Sam. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Package yescrypt implements the yescrypt password encoding mechanism for the mcf framework.
+
+Yescrypt is the default password hashing scheme of many Linux distributions, including
+Debian, Fedora and Arch, and produces /etc/shadow entries such as
+
+	$y$j9T$F5Jx5fExrKuPp53xLKQ..1$tnSYvahCwPBHKZUspmcxMfb0.WiB9W.zEaKlOBL35rC
+
+This is a pure Go implementation of yescrypt as found in libxcrypt, with its compact parameter
+encoding and its base64 alphabet. It verifies the default yescrypt flavor, which libxcrypt
+produces, as well as the classic scrypt and WORM flavors. It does not support shared ROMs.
+New passwords always use the default flavor.
+*/
+package yescrypt
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"fmt"
+	"math/bits"
+	"strconv"
+
+	"github.com/gyepisam/mcf"
+	"github.com/gyepisam/mcf/encoder"
+	"github.com/gyepisam/mcf/internal/crypt64"
+)
+
+// Default values, which match those of libxcrypt.
+// These are exported to show default values.
+// See GetConfig and SetConfig(...) to change them.
+const (
+	DefaultN       = 1 << 12
+	DefaultR       = 32
+	DefaultP       = 1
+	DefaultSaltLen = 16
+)
+
+// hashLen is the length of the hash in bytes.
+const hashLen = 32
+
+// Config contains the yescrypt algorithm parameters and other associated values.
+// Use the GetConfig() and SetConfig() combination to change any desired parameters.
+type Config struct {
+	N int // CPU/Memory cost. Must be a power of two.
+	R int // Block size parameter.
+	P int // Parallelization parameter.
+
+	SaltLen int // Length of salt in bytes. At least mcf.MinSaltLen and at most MaxSaltLen.
+
+	SaltMine mcf.SaltMiner // Source of salt; see mcf.SaltMiner.
+}
+
+// Limits, which apply to both the configuration and encoded passwords.
+// They guard against the exhaustion of memory or time by a corrupt or malicious encoded password,
+// and may be changed if necessary.
+var (
+	MaxMemory  int64 = 1 << 30 // Maximum memory used by the algorithm, which is 128 * N * R bytes.
+	MaxWork    int64 = 1 << 34 // Maximum value of 128 * N * R * P * (T + 1), which is proportional to running time.
+	MaxSaltLen       = 64      // Maximum salt length in bytes.
+)
+
+// ErrInvalidParameter is returned by SetConfig if any of the provided parameters
+// fail validation. The error message contains the name and value of the faulty
+// parameter to aid in resolving the problem.
+type ErrInvalidParameter struct {
+	Name  string
+	Value int
+}
+
+func (e ErrInvalidParameter) Error() string {
+	return fmt.Sprintf("yescrypt: parameter %s has invalid value: %d", e.Name, e.Value)
+}
+
+// Unwrap returns encoder.ErrInvalidParams.
+func (e ErrInvalidParameter) Unwrap() error { return encoder.ErrInvalidParams }
+
+// GetConfig returns the default configuration used to create new yescrypt password hashes.
+// The return value can be modified and used as a parameter to SetConfig.
+func GetConfig() Config {
+	return Config{
+		N:       DefaultN,
+		R:       DefaultR,
+		P:       DefaultP,
+		SaltLen: DefaultSaltLen,
+	}
+}
+
+// SetConfig sets the encoding parameters and salt length.
+// It is best to modify a copy of the default configuration unless all parameters are changed.
+func SetConfig(config Config) error {
+	if err := config.validate(); err != nil {
+		return err
+	}
+	return register(config)
+}
+
+func (c *Config) validate() error {
+	switch {
+	case c.N <= 1 || c.N&(c.N-1) != 0:
+		return ErrInvalidParameter{"N", c.N}
+	case c.R < 1:
+		return ErrInvalidParameter{"R", c.R}
+	case c.P < 1 || c.N/c.P <= 1:
+		return ErrInvalidParameter{"P", c.P}
+	case c.SaltLen < mcf.MinSaltLen || c.SaltLen > MaxSaltLen:
+		return ErrInvalidParameter{"SaltLen", c.SaltLen}
+	}
+	p := params{flags: flagsDefault, n: uint64(c.N), r: uint32(c.R), p: uint32(c.P)}
+	return p.check()
+}
+
+type yescrypt struct {
+	config Config
+}
+
+func register(config Config) error {
+	return mcf.Register(mcf.YESCRYPT, &yescrypt{config})
+}
+
+func init() {
+	if err := register(GetConfig()); err != nil {
+		panic(err)
+	}
+	mcf.RegisterFactory(mcf.YESCRYPT, mcf.NewFactory((*Config).validate, func(c Config) encoder.Encoder { return &yescrypt{c} }))
+}
+
+// params are the parameters of an encoded password.
+type params struct {
+	flags   int
+	n       uint64
+	r, p, t uint32
+}
+
+// check enforces the limits.
+func (p params) check() error {
+	if uint64(p.r)*uint64(p.p) >= 1<<30 {
+		return fmt.Errorf("%w: yescrypt: r * p is too large", encoder.ErrInvalidParams)
+	}
+	mem := float64(128) * float64(p.n) * float64(p.r)
+	if mem > float64(MaxMemory) {
+		return fmt.Errorf("%w: yescrypt: memory %.0f exceeds MaxMemory %d", encoder.ErrInvalidParams, mem, MaxMemory)
+	}
+	if work := mem * float64(p.p) * (float64(p.t) + 1); work > float64(MaxWork) {
+		return fmt.Errorf("%w: yescrypt: work %.0f exceeds MaxWork %d", encoder.ErrInvalidParams, work, MaxWork)
+	}
+	return nil
+}
+
+// encode appends the compact encoding of the parameters to dst.
+func (p params) encode(dst []byte) []byte {
+	flavor := uint32(p.flags)
+	if p.flags >= flagRW {
+		flavor = flagRW + uint32(p.flags>>2)
+	}
+
+	dst = encodeUint32(dst, flavor, 0)
+	dst = encodeUint32(dst, uint32(bits.Len64(p.n)-1), 1)
+	dst = encodeUint32(dst, p.r, 1)
+
+	have := uint32(0)
+	if p.p != 1 {
+		have |= 1
+	}
+	if p.t != 0 {
+		have |= 2
+	}
+	if have != 0 {
+		dst = encodeUint32(dst, have, 1)
+	}
+	if p.p != 1 {
+		dst = encodeUint32(dst, p.p, 2)
+	}
+	if p.t != 0 {
+		dst = encodeUint32(dst, p.t, 1)
+	}
+	return dst
+}
+
+var prefix = []byte("$y$")
+
+func malformed(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: yescrypt: "+format, append([]interface{}{encoder.ErrMalformedHash}, args...)...)
+}
+
+// parse splits an encoded password into its parameters, salt and hash, the latter two still encoded.
+func parse(encoded []byte) (p params, salt, hash []byte, err error) {
+	if !bytes.HasPrefix(encoded, prefix) {
+		err = malformed("missing %s prefix", prefix)
+		return
+	}
+	src := encoded[len(prefix):]
+
+	var flavor, nLog2 uint32
+	var ok bool
+	if flavor, src, ok = decodeUint32(src, 0); !ok {
+		err = malformed("invalid flavor")
+		return
+	}
+	switch {
+	case flavor < flagRW:
+		p.flags = int(flavor)
+	case flavor <= flagRW+(flavorMask>>2):
+		p.flags = flagRW + int(flavor-flagRW)<<2
+	default:
+		err = fmt.Errorf("%w: yescrypt: flavor %d", encoder.ErrUnsupportedVersion, flavor)
+		return
+	}
+	if p.flags != 0 && p.flags != flagWORM && p.flags != flagsDefault {
+		err = fmt.Errorf("%w: yescrypt: flavor %d", encoder.ErrUnsupportedVersion, flavor)
+		return
+	}
+
+	if nLog2, src, ok = decodeUint32(src, 1); !ok || nLog2 > 63 {
+		err = malformed("invalid N")
+		return
+	}
+	p.n = 1 << nLog2
+
+	if p.r, src, ok = decodeUint32(src, 1); !ok {
+		err = malformed("invalid r")
+		return
+	}
+
+	p.p = 1
+	if len(src) > 0 && src[0] != '$' {
+		var have uint32
+		if have, src, ok = decodeUint32(src, 1); !ok {
+			err = malformed("invalid parameters")
+			return
+		}
+		if have&^3 != 0 {
+			// Hash upgrades and ROMs are not supported.
+			err = fmt.Errorf("%w: yescrypt: unsupported parameters", encoder.ErrUnsupportedVersion)
+			return
+		}
+		if have&1 != 0 {
+			if p.p, src, ok = decodeUint32(src, 2); !ok {
+				err = malformed("invalid p")
+				return
+			}
+		}
+		if have&2 != 0 {
+			if p.t, src, ok = decodeUint32(src, 1); !ok {
+				err = malformed("invalid t")
+				return
+			}
+		}
+	}
+
+	if len(src) == 0 || src[0] != '$' {
+		err = malformed("invalid parameters")
+		return
+	}
+	src = src[1:]
+
+	i := bytes.IndexByte(src, '$')
+	if i < 0 {
+		err = malformed("missing hash")
+		return
+	}
+	salt, hash = src[:i], src[i+1:]
+
+	if _, ok := crypt64.Decode(hash); !ok || len(hash) != (hashLen*8+5)/6 {
+		err = malformed("hash must be %d characters", (hashLen*8+5)/6)
+		return
+	}
+
+	if p.flags == 0 && p.t != 0 || p.flags&flagRW != 0 && p.n/uint64(p.p) <= 1 {
+		err = fmt.Errorf("%w: yescrypt: inconsistent parameters", encoder.ErrInvalidParams)
+		return
+	}
+
+	err = p.check()
+	return
+}
+
+// Id returns the identifier of yescrypt passwords.
+func (y *yescrypt) Id() []byte {
+	return []byte("y")
+}
+
+// Create produces an encoded password from a plaintext password using the current configuration.
+func (y *yescrypt) Create(plaintext []byte) (encoded []byte, err error) {
+	salt, err := mcf.Salt(y.config.SaltLen, y.config.SaltMine)
+	if err != nil {
+		return nil, fmt.Errorf("yescrypt: salt: %w", err)
+	}
+
+	p := params{flags: flagsDefault, n: uint64(y.config.N), r: uint32(y.config.R), p: uint32(y.config.P)}
+
+	hash, err := kdf(plaintext, salt, p.flags, p.n, p.r, p.p, p.t, hashLen)
+	if err != nil {
+		return nil, fmt.Errorf("%w: yescrypt: %s", encoder.ErrInvalidParams, err)
+	}
+
+	encoded = append(encoded, prefix...)
+	encoded = p.encode(encoded)
+	encoded = append(encoded, '$')
+	encoded = append(encoded, crypt64.Encode(salt)...)
+	encoded = append(encoded, '$')
+	encoded = append(encoded, crypt64.Encode(hash)...)
+	return encoded, nil
+}
+
+// Verify returns true if the proffered plaintext password,
+// when encoded using the same parameters, matches the encoded password.
+func (y *yescrypt) Verify(plaintext, encoded []byte) (isValid bool, err error) {
+	p, saltStr, hash, err := parse(encoded)
+	if err != nil {
+		return false, err
+	}
+
+	salt, ok := crypt64.Decode(saltStr)
+	if !ok {
+		return false, malformed("invalid salt")
+	}
+	if len(salt) > MaxSaltLen {
+		return false, malformed("salt is longer than %d bytes", MaxSaltLen)
+	}
+
+	key, err := kdf(plaintext, salt, p.flags, p.n, p.r, p.p, p.t, hashLen)
+	if err != nil {
+		return false, fmt.Errorf("%w: yescrypt: %s", encoder.ErrInvalidParams, err)
+	}
+
+	return subtle.ConstantTimeCompare(crypt64.Encode(key), hash) == 1, nil
+}
+
+// IsCurrent returns true if the encoded password uses the default flavor and its parameters
+// are at least as large as those of the current configuration.
+func (y *yescrypt) IsCurrent(encoded []byte) (isCurrent bool, err error) {
+	p, _, _, err := parse(encoded)
+	if err != nil {
+		return false, err
+	}
+	c := y.config
+	return p.flags == flagsDefault && p.n >= uint64(c.N) && p.r >= uint32(c.R) && p.p >= uint32(c.P), nil
+}
+
+// ParseParams implements encoder.ParamsParser. The parameters are "flavor", "N", "r", "p" and "t".
+func (y *yescrypt) ParseParams(encoded []byte) (map[string]string, error) {
+	p, _, _, err := parse(encoded)
+	if err != nil {
+		return nil, err
+	}
+
+	flavor := "yescrypt"
+	switch p.flags {
+	case 0:
+		flavor = "scrypt"
+	case flagWORM:
+		flavor = "worm"
+	}
+
+	return map[string]string{
+		"flavor": flavor,
+		"N":      strconv.FormatUint(p.n, 10),
+		"r":      strconv.FormatUint(uint64(p.r), 10),
+		"p":      strconv.FormatUint(uint64(p.p), 10),
+		"t":      strconv.FormatUint(uint64(p.t), 10),
+	}, nil
+}
@@ -0,0 +1,186 @@
+// Copyright 2014 Gyepi Sam. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package yescrypt
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/gyepisam/mcf"
+	"github.com/gyepisam/mcf/encoder"
+	"github.com/gyepisam/mcf/internal/crypt64"
+)
+
+// Test vectors produced by crypt(3) from libxcrypt 4.4.33.
+var testVectors = []struct {
+	plain  string
+	passwd string
+}{
+	// Default flavor, as produced by libxcrypt for new passwords.
+	{"password", "$y$j9T$F5Jx5fExrKuPp53xLKQ..1$tnSYvahCwPBHKZUspmcxMfb0.WiB9W.zEaKlOBL35rC"},
+	{"", "$y$j9T$F5Jx5fExrKuPp53xLKQ..1$5P1uc1zvKhieqEtKttbwCQrTPXpY1cK9wEnTDKAqLD8"},
+	{"x", "$y$j9T$F5Jx5fExrKuPp53xLKQ..1$6foLM1JhGupouWKMU70wxK61Kw9ZnecbACjJwRadqM2"},
+	{"password", "$y$j75$abcdefgh$hycRI05iyPwUdPZDaw/SJaABdj2h8B1Bgxi1Z/4Xke0"},
+	{"", "$y$j75$abcdefgh$dnXG1KuMBmwYrnuKh9nriQRakI62k6mwFrGgxhxYD/2"},
+	{"password", "$y$jC5$saltsalt$lqnlUGUVNHcP4c86dvjKdcnK8wNAOPBD0xotnK.AIN7"},
+	{"x", "$y$jC5$saltsalt$q4x3xUcQU5pts52.jPPuooi/ubQELIc0YaiTrd3/.gC"},
+
+	// p > 1
+	{"password", "$y$j75..$saltsalt$QJ24DZLU/Ldz6QPMlndL.DbKmm8TVlJ0ADwknLJP9k4"},
+	{"", "$y$j75.3$x.$EjShmUwBze8OIYg436mgC5kvOFCKft1n/vK2n6BHrfC"},
+	{"x", "$y$jA..7$0123456789ab$ZxD6MeyvaudIZfbeWRmaJOnB.sRqctwuL4X8uYjmOm5"},
+
+	// t > 0
+	{"password", "$y$j75/.$saltsalt$dRUkxYqiEaIxOM/NN.1lYWa2NHf.d5Q8HSdm8mJCy14"},
+
+	// Classic scrypt flavor.
+	{"password", "$y$./5$saltsalt$VrXPVIEiVxlEqoxLw.yn2diVjpR7V7LvXs37NUrWsX8"},
+	{"", "$y$./5$saltsalt$AUCBrM0T.5HsMycNWbXF61/NFgxmHw14fAasuTZuLc1"},
+	{"password", "$y$./5..$saltsalt$Z/EodDdjfO4Y67SQMJ7dksnqexsVG9/T0iMmLP0Vw91"},
+
+	// WORM flavor.
+	{"password", "$y$/75$saltsalt$i9C/uEIknZKuig0OCaXusin5JnBuDBxX0wU16.qdzn1"},
+	{"password", "$y$/7T$saltsalt$yDo8XUne1D26JnnwSPn4h7UhIW87SDCWV0l4n4snb58"},
+	{"password", "$y$/75/0$saltsalt$mG5vYQbpus9ENV4akp9J7e/fXT5LWscr7ANJvrNyzj8"},
+}
+
+func TestVectors(t *testing.T) {
+	for i, v := range testVectors {
+		isValid, err := mcf.Verify(v.plain, v.passwd)
+		if err != nil || !isValid {
+			t.Errorf("%d: Verify(%q, %s): got (%t, %v), expected (true, nil)", i, v.plain, v.passwd, isValid, err)
+		}
+		isValid, err = mcf.Verify(v.plain+"x", v.passwd)
+		if err != nil || isValid {
+			t.Errorf("%d: Verify wrong password: got (%t, %v), expected (false, nil)", i, isValid, err)
+		}
+	}
+}
+
+func TestCreate(t *testing.T) {
+	defer SetConfig(GetConfig())
+
+	salt, ok := crypt64.Decode([]byte("F5Jx5fExrKuPp53xLKQ..1"))
+	if !ok {
+		t.Fatal("crypt64.Decode failed")
+	}
+	config := GetConfig()
+	config.SaltMine = func(n int) ([]byte, error) { return salt, nil }
+	if err := SetConfig(config); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := mcf.SetDefault(mcf.YESCRYPT); err != nil {
+		t.Fatal(err)
+	}
+
+	encoded, err := mcf.Create(testVectors[0].plain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := testVectors[0].passwd; encoded != want {
+		t.Errorf("Create: want %s, got %s", want, encoded)
+	}
+
+	config.N, config.P = 1<<10, 3
+	config.SaltMine = nil
+	if err := SetConfig(config); err != nil {
+		t.Fatal(err)
+	}
+	encoded, err = mcf.Create(testVectors[0].plain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "$y$j7T./$"; !bytes.HasPrefix([]byte(encoded), []byte(want)) || len(encoded) != len(want)+22+1+43 {
+		t.Errorf("Create: got %s, expected %s followed by 22 characters of salt and 43 of hash", encoded, want)
+	}
+	if isValid, err := mcf.Verify(testVectors[0].plain, encoded); err != nil || !isValid {
+		t.Errorf("Verify: got (%t, %v), expected (true, nil)", isValid, err)
+	}
+}
+
+func TestIsCurrent(t *testing.T) {
+	defer SetConfig(GetConfig())
+
+	enc := mcf.Registered(mcf.YESCRYPT)
+	tests := []struct {
+		passwd string
+		want   bool
+	}{
+		{testVectors[0].passwd, true},
+		{"$y$j75$abcdefgh$hycRI05iyPwUdPZDaw/SJaABdj2h8B1Bgxi1Z/4Xke0", false},
+		{"$y$jC5$saltsalt$lqnlUGUVNHcP4c86dvjKdcnK8wNAOPBD0xotnK.AIN7", false},
+		{"$y$/7T$saltsalt$yDo8XUne1D26JnnwSPn4h7UhIW87SDCWV0l4n4snb58", false},
+	}
+	for i, tt := range tests {
+		if isCurrent, err := enc.IsCurrent([]byte(tt.passwd)); err != nil || isCurrent != tt.want {
+			t.Errorf("%d: IsCurrent(%s): got (%t, %v), expected (%t, nil)", i, tt.passwd, isCurrent, err, tt.want)
+		}
+	}
+
+	config := GetConfig()
+	config.N *= 2
+	if err := SetConfig(config); err != nil {
+		t.Fatal(err)
+	}
+	if isCurrent, err := mcf.Registered(mcf.YESCRYPT).IsCurrent([]byte(testVectors[0].passwd)); err != nil || isCurrent {
+		t.Errorf("IsCurrent after doubling N: got (%t, %v), expected (false, nil)", isCurrent, err)
+	}
+}
+
+func TestParams(t *testing.T) {
+	for _, min := range []uint32{0, 1, 2} {
+		for _, x := range []uint32{0, 1, 46, 47, 48, 63, 64, 500, 1 << 12, 1 << 20, 1<<30 - 1, 1 << 30} {
+			x += min
+			b := encodeUint32(nil, x, min)
+			if b == nil {
+				t.Errorf("encodeUint32(%d, %d) failed", x, min)
+				continue
+			}
+			y, rest, ok := decodeUint32(b, min)
+			if !ok || y != x || len(rest) != 0 {
+				t.Errorf("decodeUint32(%s, %d): got (%d, %q, %t), expected %d", b, min, y, rest, ok, x)
+			}
+		}
+	}
+
+	params, err := mcf.Registered(mcf.YESCRYPT).(encoder.ParamsParser).ParseParams([]byte("$y$j75..$saltsalt$QJ24DZLU/Ldz6QPMlndL.DbKmm8TVlJ0ADwknLJP9k4"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range map[string]string{"flavor": "yescrypt", "N": "1024", "r": "8", "p": "2", "t": "0"} {
+		if params[k] != v {
+			t.Errorf("ParseParams: %s: want %s, got %s", k, v, params[k])
+		}
+	}
+}
+
+func TestMalformed(t *testing.T) {
+	hash := "$tnSYvahCwPBHKZUspmcxMfb0.WiB9W.zEaKlOBL35rC"
+	tests := []struct {
+		passwd string
+		want   error
+	}{
+		{"$y$j9T$F5Jx5fExrKuPp53xLKQ..1$tnSYvahCwPBHKZUspmcxMfb0.WiB9W.zEaKlOBL35r", encoder.ErrMalformedHash},
+		{"$y$j9T$F5Jx5fExrKuPp53xLKQ..1", encoder.ErrMalformedHash},
+		{"$y$j9T$Ab" + hash, encoder.ErrMalformedHash},
+		{"$y$j9T$F5Jx5fExrKuPp53xLKQ..1$tnSYvahCwPBHKZUspmcxMfb0.WiB9W.zEaKlOBL3*rC", encoder.ErrMalformedHash},
+		{"$y$j9" + hash, encoder.ErrMalformedHash},
+		{"$y$j" + hash, encoder.ErrMalformedHash},
+		{"$y$j9T." + hash, encoder.ErrMalformedHash},
+		{"$y$jbT$salt" + hash, encoder.ErrInvalidParams},                                                   // 128 * N * r > MaxMemory
+		{"$y$j9T" + string(encodeUint32([]byte("/"), 2000, 1)) + "$salt" + hash, encoder.ErrInvalidParams}, // t
+		{"$y$.75/.$saltsalt" + hash, encoder.ErrInvalidParams},                                             // t with classic scrypt
+		{"$y$k9T$salt" + hash, encoder.ErrUnsupportedVersion},                                              // flavor
+		{"$y$j9T3.$salt" + hash, encoder.ErrUnsupportedVersion},                                            // ROM
+	}
+	for i, tt := range tests {
+		isValid, err := mcf.Verify("password", tt.passwd)
+		if isValid || !errors.Is(err, tt.want) {
+			t.Errorf("%d: Verify(%s): got (%t, %v), expected %v", i, tt.passwd, isValid, err, tt.want)
+		}
+	}
+}